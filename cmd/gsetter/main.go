@@ -43,7 +43,7 @@ func Process(a any) (result []Field, packageName string, structName string, err
 		//name := val.Name()
 		//name = cases.Title(language.English, cases.Compact).String(val.Name())
 		typeString := field.Type.String()
-		typeString = strings.TrimPrefix(typeString, packageName+".")
+		typeString = strings.ReplaceAll(typeString, packageName+".", "")
 		result = append(result,
 			Field{
 				StructName: structName,
@@ -139,4 +139,28 @@ func main() {
 	if err := Generate(config.Proxy{}, "../../pkg/config"); err != nil {
 		panic(err)
 	}
+	if err := Generate(config.Mock{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Timeouts{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Retry{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Workers{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Archives{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.FileTypes{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Limits{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
+	if err := Generate(config.Watch{}, "../../pkg/config"); err != nil {
+		panic(err)
+	}
 }
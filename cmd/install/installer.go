@@ -20,8 +20,8 @@ import (
 
 	"sandboxer/pkg/config"
 	"sandboxer/pkg/extract"
-	"sandboxer/pkg/fifo"
 	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/xplatform"
 )
@@ -208,12 +208,14 @@ func (i *Installer) StageStopProgram() error {
 func (i *Installer) StageWaitServiceToStop() error {
 	logging.Debugf("Install: WaitServiceToStop")
 	for i := 0; i < 10; i++ {
-		fifoWriter, err := fifo.NewWriter()
-		if err != nil && fifo.IsDown(err) {
+		client, err := ipc.DialDefault()
+		if err != nil && ipc.IsDown(err) {
 			return nil
 		}
-		fifoWriter.Close()
-		logging.Debugf("Wait for FIFO")
+		if err == nil {
+			client.Close()
+		}
+		logging.Debugf("Wait for %s to stop", globals.AppName)
 		time.Sleep(1 * time.Second)
 	}
 	return fmt.Errorf("stop Submissions and run setup again")
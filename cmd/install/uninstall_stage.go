@@ -8,6 +8,8 @@ import (
 	"sandboxer/pkg/globals"
 	"sandboxer/pkg/logging"
 	"strconv"
+
+	"github.com/mpkondrashin/ddan"
 )
 
 type UninstallStageDelete struct {
@@ -45,11 +47,22 @@ func (*UninstallStageStopProcess) Name() string {
 	return "Stop Program"
 }
 
+// Execute - stop Sandboxer and Sandboxer daemon
 func (*UninstallStageStopProcess) Execute() error {
-	pidFilePath, err := globals.PidFilePath()
-	if err != nil {
-		return err
+	for _, pidFilePath := range []func() (string, error){globals.PidFilePath, globals.DaemonPidFilePath} {
+		path, err := pidFilePath()
+		if err != nil {
+			return err
+		}
+		if err := stopProcess(path); err != nil {
+			return err
+		}
 	}
+	return nil
+}
+
+// stopProcess - kill process which PID is stored in given file
+func stopProcess(pidFilePath string) error {
 	data, err := os.ReadFile(pidFilePath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
@@ -76,7 +89,8 @@ func (*UninstallStageStopProcess) Execute() error {
 }
 
 type UninstallStageUnregister struct {
-	conf *config.DDAn
+	conf     *config.DDAn
+	analyzer func() (ddan.ClientInterface, error)
 }
 
 var _ UninstallStage = &UninstallStageUnregister{}
@@ -84,6 +98,13 @@ var _ UninstallStage = &UninstallStageUnregister{}
 func NewUninstallStageUnregister(conf *config.DDAn) *UninstallStageUnregister {
 	return &UninstallStageUnregister{
 		conf: conf,
+		analyzer: func() (ddan.ClientInterface, error) {
+			analyzer, err := conf.Analyzer()
+			if err != nil {
+				return nil, err
+			}
+			return analyzer, nil
+		},
 	}
 }
 
@@ -99,7 +120,7 @@ func (u *UninstallStageUnregister) Execute() error {
 		}
 		return err
 	}
-	analyzer, err := u.conf.Analyzer()
+	analyzer, err := u.analyzer()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mpkondrashin/ddan"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/ddansim"
+)
+
+func TestUninstallStageUnregister(t *testing.T) {
+	sim := ddansim.New()
+	conf := config.NewDefaultDDAn(nil)
+	conf.SetClientUUID("11111111-2222-3333-4444-555555555555")
+	sim.Register(conf.GetClientUUID())
+	stage := NewUninstallStageUnregister(conf)
+	stage.analyzer = func() (ddan.ClientInterface, error) {
+		return sim.Client(conf.GetClientUUID()), nil
+	}
+	if err := stage.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Registered(conf.GetClientUUID()) {
+		t.Error("client is still registered")
+	}
+	err := stage.Execute()
+	var apiErr *ddan.APIError
+	if !errors.As(err, &apiErr) || apiErr.Response != ddan.ResponseNotRegistered {
+		t.Errorf("expected not registered error, got %v", err)
+	}
+}
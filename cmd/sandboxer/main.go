@@ -25,6 +25,7 @@ import (
 	"sandboxer/pkg/dispatchers"
 	"sandboxer/pkg/fatal"
 	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/task"
 	"sandboxer/pkg/update"
@@ -42,7 +43,7 @@ type SandboxerApp struct {
 	updateWindow      *ModalWindow
 }
 
-func NewSandboxingApp(conf *config.Configuration, channels *task.Channels, list *task.TaskList) *SandboxerApp {
+func NewSandboxingApp(conf *config.Configuration, channels *task.Channels, list *task.TaskList, launcher *dispatchers.Launcher) *SandboxerApp {
 	fyneApp := app.New()
 	deskApp, ok := fyneApp.(desktop.App)
 	if !ok {
@@ -81,7 +82,7 @@ func NewSandboxingApp(conf *config.Configuration, channels *task.Channels, list
 		}, a.optionsWindow.win)
 	})
 	*/
-	a.optionsWindow = NewModalWindow(NewOptionsWindow(conf), &a.TrayApp)
+	a.optionsWindow = NewModalWindow(NewOptionsWindow(conf, launcher), &a.TrayApp)
 
 	quitItem := fyne.NewMenuItem("Quit", a.Quit)
 	quitItem.Icon = theme.CancelIcon()
@@ -208,6 +209,13 @@ func main() {
 	//	logging.Debugf("FONT: %s", conf.Resource(fontFileName))
 	logging.Infof("%s Version %s Build %s Start", globals.AppName, globals.Version, globals.Build)
 	logging.Debugf("Configuration file: %s", configFilePath)
+	if ipc.RunningDefault() {
+		msg := fmt.Sprintf("%s or its daemon is already running", globals.AppName)
+		logging.Errorf(msg)
+		fmt.Fprintln(os.Stderr, msg)
+		fatal.Warning("Start Error", msg)
+		os.Exit(globals.ExitAlreadyRunning)
+	}
 	removePid, err := SavePid()
 	if err != nil {
 		msg := fmt.Sprintf("Save pid: %v", err)
@@ -222,8 +230,19 @@ func main() {
 	channels := task.NewChannels()
 	list := task.NewList()
 	launcher := dispatchers.NewLauncher(conf, channels, list)
-	launcher.Run()
+	if err := launcher.Run(); err != nil {
+		msg := fmt.Sprintf("%s or its daemon is already running: %v", globals.AppName, err)
+		logging.Errorf(msg)
+		fmt.Fprintln(os.Stderr, msg)
+		fatal.Warning("Start Error", msg)
+		removePid()
+		os.Exit(globals.ExitAlreadyRunning)
+	}
 	defer launcher.Stop()
-	app := NewSandboxingApp(conf, channels, list)
+	app := NewSandboxingApp(conf, channels, list, launcher)
+	go func() {
+		<-launcher.ShutdownRequested()
+		app.Quit()
+	}()
 	app.Run()
 }
@@ -11,6 +11,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"unicode"
@@ -22,12 +23,15 @@ import (
 	"fyne.io/fyne/v2/widget"
 
 	"sandboxer/pkg/config"
+	"sandboxer/pkg/dispatchers"
+	"sandboxer/pkg/ignore"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/settings"
 )
 
 type OptionsWindow struct {
-	conf *config.Configuration
+	conf     *config.Configuration
+	launcher *dispatchers.Launcher
 
 	voneCheck    *widget.Check
 	voneSettings *settings.VisionOne
@@ -38,13 +42,34 @@ type OptionsWindow struct {
 	proxySettings *settings.Proxy
 
 	ignoreEntry       *widget.Entry
+	checkEntry        *widget.Entry
 	tasksKeepDays     *widget.Entry
 	showNotifications *widget.Check
+
+	workers []workerEntry
+	limits  []limitsEntry
+}
+
+// workerEntry - number of workers for one processing stage
+type workerEntry struct {
+	entry *widget.Entry
+	set   func(value int)
 }
 
-func NewOptionsWindow(conf *config.Configuration) *OptionsWindow {
+// limitsEntry - constraints of one sandbox type
+type limitsEntry struct {
+	maxSize    *widget.Entry
+	extensions *widget.Entry
+	types      *widget.Entry
+	urls       *widget.Check
+	get        func() config.Constraints
+	set        func(value config.Constraints)
+}
+
+func NewOptionsWindow(conf *config.Configuration, launcher *dispatchers.Launcher) *OptionsWindow {
 	return &OptionsWindow{
 		conf:          conf,
+		launcher:      launcher,
 		voneSettings:  settings.NewVisionOne(conf.VisionOne),
 		ddanSettings:  settings.NewDDAnSettings(conf.DDAn),
 		proxySettings: settings.NewProxy(conf.Proxy),
@@ -73,10 +98,12 @@ func (s *OptionsWindow) Content(w *ModalWindow) fyne.CanvasObject {
 	voneTab := container.NewTabItem("Vision One", s.VisionOneSettings())
 	proxyTab := container.NewTabItem("Proxy", s.ProxySettings())
 	tabs := container.NewAppTabs(
-		container.NewTabItem("Settings", s.GeneralSettings()),
+		container.NewTabItem("Settings", s.GeneralSettings(w)),
 		voneTab,
 		ddanTab,
 		proxyTab,
+		container.NewTabItem("Workers", s.WorkersSettings()),
+		container.NewTabItem("Limits", s.LimitsSettings()),
 	)
 	tabs.OnSelected = func(tab *container.TabItem) {
 		switch tab {
@@ -136,26 +163,29 @@ func (s *OptionsWindow) ProxySettings() fyne.CanvasObject {
 	return container.NewVBox(labelTop, s.proxySettings.Widget())
 }
 
-func (s *OptionsWindow) GeneralSettings() fyne.CanvasObject {
+func (s *OptionsWindow) GeneralSettings(w *ModalWindow) fyne.CanvasObject {
 	settingsLabel := widget.NewLabel("General Options")
 	s.ignoreEntry = widget.NewEntry()
 	s.ignoreEntry.SetText(strings.Join(s.conf.Ignore, ", "))
 	ignoreFormItem := widget.NewFormItem("Ignore:", s.ignoreEntry)
 	ignoreFormItem.HintText = "Comma-separated list of file masks"
 
-	s.tasksKeepDays = widget.NewEntry()
-	s.tasksKeepDays.SetText(strconv.Itoa(s.conf.GetTasksKeepDays()))
-	s.tasksKeepDays.OnChanged = func(str string) {
-		n := ""
-		for _, ch := range str {
-			if unicode.IsDigit(ch) {
-				n += string(ch)
-			}
-		}
-		if n != str {
-			s.tasksKeepDays.SetText(n)
-		}
+	var rules []string
+	for _, rule := range s.conf.GetIgnoreRules() {
+		rules = append(rules, rule.String())
 	}
+	rulesLabel := widget.NewLabel(strings.Join(rules, "\n"))
+	rulesLabel.Wrapping = fyne.TextWrapWord
+	rulesFormItem := widget.NewFormItem("Ignore rules:", rulesLabel)
+	rulesFormItem.HintText = "Set in configuration file"
+
+	s.checkEntry = widget.NewEntry()
+	s.checkEntry.SetPlaceHolder("Path to file or folder")
+	checkButton := widget.NewButton("Check", func() { s.CheckIgnore(w) })
+	checkFormItem := widget.NewFormItem("Check:", container.NewBorder(nil, nil, nil, checkButton, s.checkEntry))
+	checkFormItem.HintText = "Show which mask or rule path matches"
+
+	s.tasksKeepDays = NumberEntry(s.conf.GetTasksKeepDays())
 	tasksKeepDaysFormItem := widget.NewFormItem("Delete tasks after: ", s.tasksKeepDays)
 	tasksKeepDaysFormItem.HintText = "Number of days"
 
@@ -163,23 +193,149 @@ func (s *OptionsWindow) GeneralSettings() fyne.CanvasObject {
 	s.showNotifications.Checked = s.conf.GetShowNotifications()
 	notificatonsFormItem := widget.NewFormItem("Notifications:", s.showNotifications)
 
-	settingsForm := widget.NewForm(ignoreFormItem, tasksKeepDaysFormItem, notificatonsFormItem)
+	settingsForm := widget.NewForm(ignoreFormItem, rulesFormItem, checkFormItem, tasksKeepDaysFormItem, notificatonsFormItem)
 	return container.NewVBox(settingsLabel, settingsForm)
 }
 
-func (s *OptionsWindow) Save(w *ModalWindow) {
-	s.conf.Ignore = nil
-	for _, ign := range strings.Split(s.ignoreEntry.Text, ",") {
-		ign := strings.TrimSpace(ign)
-		if len(ign) > 0 {
-			s.conf.Ignore = append(s.conf.Ignore, ign)
+// CheckIgnore - show which of ignore masks, including ones that are not
+// saved yet, or ignore rules given path matches
+func (s *OptionsWindow) CheckIgnore(w *ModalWindow) {
+	path := strings.TrimSpace(s.checkEntry.Text)
+	info, err := os.Stat(path)
+	if err != nil {
+		dialog.ShowError(err, w.win)
+		return
+	}
+	reason := ignore.Check(SplitList(s.ignoreEntry.Text), s.conf.GetIgnoreRules(), path, info)
+	if reason == "" {
+		reason = "Not ignored"
+	}
+	dialog.ShowInformation("Ignore", reason, w.win)
+}
+
+func (s *OptionsWindow) WorkersSettings() fyne.CanvasObject {
+	workersLabel := widget.NewLabel("Number of tasks processed in parallel on each stage")
+	workers := s.conf.Workers
+	stages := []struct {
+		name  string
+		value int
+		set   func(int)
+	}{
+		{"Prefilter:", workers.GetPrefilter(), workers.SetPrefilter},
+		{"Lookup:", workers.GetLookup(), workers.SetLookup},
+		{"Quota:", workers.GetQuota(), workers.SetQuota},
+		{"Upload:", workers.GetUpload(), workers.SetUpload},
+		{"Result:", workers.GetResult(), workers.SetResult},
+		{"Report:", workers.GetReport(), workers.SetReport},
+		{"Investigation:", workers.GetInvestigation(), workers.SetInvestigation},
+	}
+	s.workers = nil
+	var formItems []*widget.FormItem
+	for _, stage := range stages {
+		entry := NumberEntry(stage.value)
+		s.workers = append(s.workers, workerEntry{entry, stage.set})
+		formItems = append(formItems, widget.NewFormItem(stage.name, entry))
+	}
+	return container.NewVBox(workersLabel, widget.NewForm(formItems...))
+}
+
+// megabyte - unit of size limits in options window
+const megabyte = 1024 * 1024
+
+func (s *OptionsWindow) LimitsSettings() fyne.CanvasObject {
+	limitsLabel := widget.NewLabel("Objects accepted by sandboxes")
+	limits := s.conf.Limits
+	sandboxes := []struct {
+		name string
+		get  func() config.Constraints
+		set  func(config.Constraints)
+	}{
+		{"Vision One", limits.GetVisionOne, limits.SetVisionOne},
+		{"Analyzer", limits.GetAnalyzer, limits.SetAnalyzer},
+	}
+	s.limits = nil
+	objects := []fyne.CanvasObject{limitsLabel}
+	for _, sb := range sandboxes {
+		constraints := sb.get()
+		entry := limitsEntry{
+			maxSize:    NumberEntry(int(constraints.MaxSize / megabyte)),
+			extensions: widget.NewEntry(),
+			types:      widget.NewEntry(),
+			urls:       widget.NewCheck("Accept", nil),
+			get:        sb.get,
+			set:        sb.set,
+		}
+		entry.extensions.SetText(strings.Join(constraints.Extensions, ", "))
+		entry.types.SetText(strings.Join(constraints.Types, ", "))
+		entry.urls.SetChecked(!constraints.NoURLs)
+		s.limits = append(s.limits, entry)
+		maxSizeFormItem := widget.NewFormItem("Max size:", entry.maxSize)
+		maxSizeFormItem.HintText = "Megabytes, 0 for no limit"
+		extensionsFormItem := widget.NewFormItem("Extensions:", entry.extensions)
+		extensionsFormItem.HintText = "Comma-separated list, empty for any"
+		typesFormItem := widget.NewFormItem("File types:", entry.types)
+		typesFormItem.HintText = "Comma-separated list of types or groups, empty for any"
+		urlsFormItem := widget.NewFormItem("URLs:", entry.urls)
+		urlsFormItem.HintText = "Sandbox analyzes web pages"
+		objects = append(objects, widget.NewCard(sb.name, "", widget.NewForm(
+			maxSizeFormItem, extensionsFormItem, typesFormItem, urlsFormItem)))
+	}
+	return container.NewVBox(objects...)
+}
+
+// SplitList - non empty items of comma-separated list
+func SplitList(text string) (result []string) {
+	for _, item := range strings.Split(text, ",") {
+		item = strings.TrimSpace(item)
+		if len(item) > 0 {
+			result = append(result, item)
 		}
 	}
+	return
+}
+
+// NumberEntry - entry that accepts only digits
+func NumberEntry(value int) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(value))
+	entry.OnChanged = func(str string) {
+		n := ""
+		for _, ch := range str {
+			if unicode.IsDigit(ch) {
+				n += string(ch)
+			}
+		}
+		if n != str {
+			entry.SetText(n)
+		}
+	}
+	return entry
+}
+
+func (s *OptionsWindow) Save(w *ModalWindow) {
+	s.conf.SetIgnore(SplitList(s.ignoreEntry.Text))
 	days, err := strconv.Atoi(s.tasksKeepDays.Text)
 	if err == nil {
 		s.conf.SetTasksKeepDays(days)
 	}
 	s.conf.SetShowNotifications(s.showNotifications.Checked)
+	for _, worker := range s.workers {
+		count, err := strconv.Atoi(worker.entry.Text)
+		if err == nil && count > 0 {
+			worker.set(count)
+		}
+	}
+	for _, limits := range s.limits {
+		constraints := limits.get()
+		size, err := strconv.ParseInt(limits.maxSize.Text, 10, 64)
+		if err == nil && size != constraints.MaxSize/megabyte {
+			constraints.MaxSize = size * megabyte
+		}
+		constraints.NoURLs = !limits.urls.Checked
+		constraints.Extensions = SplitList(limits.extensions.Text)
+		constraints.Types = SplitList(limits.types.Text)
+		limits.set(constraints)
+	}
 
 	if s.ddanCheck.Checked {
 		s.conf.SandboxType = config.SandboxAnalyzer
@@ -220,5 +376,6 @@ func (s *OptionsWindow) Save(w *ModalWindow) {
 		dialog.ShowError(err, w.win)
 		return
 	}
+	s.launcher.Scale()
 	w.Hide()
 }
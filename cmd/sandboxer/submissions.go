@@ -27,9 +27,9 @@ import (
 	"github.com/mpkondrashin/fileicon"
 
 	"sandboxer/pkg/config"
+	"sandboxer/pkg/filetype"
 	"sandboxer/pkg/globals"
 	"sandboxer/pkg/logging"
-	"sandboxer/pkg/sandbox"
 	"sandboxer/pkg/task"
 	"sandboxer/pkg/xplatform"
 )
@@ -208,7 +208,7 @@ func (s *SubmissionsWindow) UpdateWidget(tsk *task.Task, object fyne.CanvasObjec
 	}
 	icon.Resource = iconResource
 	if err != nil {
-		label.Text, label.TextSize = ExtAndSize(tsk.Path)
+		label.Text, label.TextSize = TypeAndSize(tsk)
 	} else {
 		label.Text = ""
 	}
@@ -225,6 +225,16 @@ func (s *SubmissionsWindow) UpdateWidget(tsk *task.Task, object fyne.CanvasObjec
 
 	messageText.TextStyle = fyne.TextStyle{Italic: true}
 	messageText.TextSize = 10
+
+	verdictsBox := vbox.Objects[2].(*fyne.Container)
+	verdictsBox.Objects = nil
+	for _, v := range tsk.Verdicts {
+		verdictText := canvas.NewText(v.Sandbox+": "+v.RiskLevel.String(), v.RiskLevel.Color())
+		verdictText.TextSize = 10
+		verdictText.TextStyle = fyne.TextStyle{Bold: v.Final() && v.Sandbox == tsk.Sandbox && v.RiskLevel == tsk.RiskLevel}
+		verdictsBox.Objects = append(verdictsBox.Objects, verdictText)
+	}
+	verdictsBox.Refresh()
 }
 
 func (s *SubmissionsWindow) PopUpMenu(tsk *task.Task) *fyne.Menu {
@@ -262,14 +272,32 @@ func (s *SubmissionsWindow) PopUpMenu(tsk *task.Task) *fyne.Menu {
 	deleteFileItem.Icon = theme.DeleteIcon()
 
 	recheckAction := func() {
-		tsk.SetMessage("")
-		tsk.SetRiskLevel(sandbox.RiskLevelUnknown)
-		tsk.SetChannel(task.ChPrefilter)
-		s.channels.TaskChannel[task.ChPrefilter] <- tsk.Number
+		tsk.Recheck()
+		s.channels.Push(task.ChPrefilter, tsk.Number, tsk.Priority)
 	}
 	recheckItem := fyne.NewMenuItem("Recheck File", recheckAction)
 	recheckItem.Icon = theme.SearchReplaceIcon()
 
+	pauseItem := fyne.NewMenuItem("Pause", func() {
+		s.Control(s.list.Pause, tsk)
+	})
+	pauseItem.Icon = theme.MediaPauseIcon()
+	if tsk.Channel == task.ChPaused {
+		pauseItem = fyne.NewMenuItem("Resume", func() {
+			s.Control(func(id task.ID) error {
+				return s.list.Resume(id, s.channels)
+			}, tsk)
+		})
+		pauseItem.Icon = theme.MediaPlayIcon()
+	}
+	pauseItem.Disabled = !tsk.Channel.Queued() && tsk.Channel != task.ChPaused
+
+	cancelItem := fyne.NewMenuItem("Cancel", func() {
+		s.Control(s.list.Cancel, tsk)
+	})
+	cancelItem.Icon = theme.MediaStopIcon()
+	cancelItem.Disabled = !tsk.Channel.Queued() && tsk.Channel != task.ChPaused
+
 	deleteTaskItem := fyne.NewMenuItem("This Task", func() {
 		s.DeleteTask(tsk)
 	})
@@ -291,10 +319,20 @@ func (s *SubmissionsWindow) PopUpMenu(tsk *task.Task) *fyne.Menu {
 		reportItem,
 		investigationItem,
 		recheckItem,
+		pauseItem,
+		cancelItem,
 		deleteItem,
 		deleteFileItem)
 }
 
+// Control - cancel, pause or resume task
+func (s *SubmissionsWindow) Control(action func(id task.ID) error, tsk *task.Task) {
+	if err := action(tsk.Number); err != nil {
+		dialog.ShowError(err, s.win)
+		logging.LogError(err)
+	}
+}
+
 func (s *SubmissionsWindow) DeleteTask(tsk *task.Task) {
 	err := s.list.DeleteTask(tsk)
 	if err != nil {
@@ -377,9 +415,11 @@ func (s *SubmissionsWindow) CardWidget() fyne.CanvasObject {
 	messageText.TextSize = 10
 
 	stateVBox := container.NewHBox(stateText, messageText)
+	verdictsBox := container.NewHBox()
 	vbox := container.NewPadded(container.NewVBox(
 		fileNameText,
 		stateVBox,
+		verdictsBox,
 	))
 	menuIcon := newContextMenuIcon(
 		theme.DefaultTheme().Icon(theme.IconNameMoreVertical),
@@ -388,20 +428,25 @@ func (s *SubmissionsWindow) CardWidget() fyne.CanvasObject {
 	return container.NewHBox(menuIcon, container.NewPadded(icon), vbox)
 }
 
-func ExtAndSize(path string) (string, float32) {
-	ext := filepath.Ext(path)
-	if len(ext) > 0 {
-		ext = ext[1:]
+// TypeAndSize - label for file icon: type detected by content or extension
+// if type is not detected yet, and its font size
+func TypeAndSize(tsk *task.Task) (string, float32) {
+	label := filetype.Type(tsk.FileType).String()
+	if tsk.FileType == "" {
+		label = filepath.Ext(tsk.Path)
+		if len(label) > 0 {
+			label = label[1:]
+		}
 	}
 	var maxSize float32 = 10
 	var size float32
-	if len(ext) > 0 {
-		size = maxSize * 3 / float32(len(ext))
+	if len(label) > 0 {
+		size = maxSize * 3 / float32(len(label))
 	}
 	if size > maxSize {
 		size = maxSize
 	}
-	return ext, size
+	return label, size
 }
 
 func (s *SubmissionsWindow) Show() {
@@ -22,10 +22,11 @@ import (
 )
 
 type SubmitURLWindow struct {
-	list         *task.TaskList
-	channels     *task.Channels
-	urlEntry     *widget.Entry
-	submitButton *widget.Button
+	list           *task.TaskList
+	channels       *task.Channels
+	urlEntry       *widget.Entry
+	prioritySelect *widget.Select
+	submitButton   *widget.Button
 }
 
 func NewSubmitURLWindow(list *task.TaskList, channels *task.Channels) *SubmitURLWindow {
@@ -37,6 +38,7 @@ func NewSubmitURLWindow(list *task.TaskList, channels *task.Channels) *SubmitURL
 
 func (s *SubmitURLWindow) Show() {
 	s.urlEntry.SetText("")
+	s.prioritySelect.SetSelectedIndex(int(task.PriorityInteractive))
 }
 
 func (s *SubmitURLWindow) Hide() {}
@@ -55,8 +57,12 @@ func (s *SubmitURLWindow) Content(w *ModalWindow) fyne.CanvasObject {
 	s.urlEntry = widget.NewEntry()
 	s.urlEntry.OnChanged = s.Update
 	tokenFormItem := widget.NewFormItem("URL:", s.urlEntry)
+	s.prioritySelect = widget.NewSelect(task.PriorityString[:], nil)
+	s.prioritySelect.SetSelectedIndex(int(task.PriorityInteractive))
+	priorityFormItem := widget.NewFormItem("Priority:", s.prioritySelect)
 	optionsForm := widget.NewForm(
 		tokenFormItem,
+		priorityFormItem,
 	)
 	s.submitButton = widget.NewButton("Submit", func() {
 		s.Submit()
@@ -78,7 +84,12 @@ func (s *SubmitURLWindow) Submit() {
 		}
 		return
 	}
-	s.channels.TaskChannel[task.ChPrefilter] <- tsk
+	priority, err := task.ParsePriority(s.prioritySelect.Selected)
+	if err != nil {
+		logging.LogError(err)
+	}
+	s.list.Get(tsk).SetPriority(priority)
+	s.channels.Push(task.ChPrefilter, tsk, priority)
 }
 
 func (s *SubmitURLWindow) Update(str string) {
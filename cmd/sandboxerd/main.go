@@ -0,0 +1,112 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+main.go
+
+Sandboxer daemon: run dispatchers without GUI
+*/
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/dispatchers"
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+const daemonLog = "sandboxerd.log"
+
+func SavePid() (func(), error) {
+	pidFilePath, err := globals.DaemonPidFilePath()
+	if err != nil {
+		return nil, err
+	}
+	pid := strconv.Itoa(os.Getpid())
+	if err := os.WriteFile(pidFilePath, []byte(pid), 0644); err != nil {
+		return nil, err
+	}
+	return func() {
+		os.Remove(pidFilePath)
+	}, nil
+}
+
+func WaitForSignal() <-chan os.Signal {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	logging.Debugf("Run wait for signal")
+	return c
+}
+
+func main() {
+	configFilePath, err := globals.ConfigurationFilePath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "globals.ConfigurationFilePath: %v\n", err)
+		os.Exit(globals.ExitGetConfigurationFileathError)
+	}
+	flag.StringVar(&configFilePath, "config", configFilePath, "configuration file path")
+	flag.Parse()
+
+	closeLogging, err := globals.SetupLogging(daemonLog)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "SetupLogging: %v\n", err)
+		os.Exit(globals.ExitSetupLogging)
+	}
+	defer closeLogging()
+
+	conf := config.New(configFilePath)
+	if err := conf.Load(); err != nil {
+		logging.LogError(err)
+		fmt.Fprintf(os.Stderr, "conf.Load: %v\n", err)
+		if !errors.Is(err, os.ErrNotExist) {
+			os.Exit(globals.ExitLoadConfigError)
+		}
+	}
+	// Daemon never touches a display
+	conf.SetShowNotifications(false)
+
+	logging.Infof("%s Version %s Build %s Daemon Start", globals.AppName, globals.Version, globals.Build)
+	logging.Debugf("Configuration file: %s", configFilePath)
+	if ipc.RunningDefault() {
+		msg := fmt.Sprintf("%s or its daemon is already running", globals.AppName)
+		logging.Errorf(msg)
+		fmt.Fprintln(os.Stderr, msg)
+		os.Exit(globals.ExitAlreadyRunning)
+	}
+	removePid, err := SavePid()
+	if err != nil {
+		logging.Errorf("Save pid: %v", err)
+		fmt.Fprintf(os.Stderr, "Save pid: %v\n", err)
+		os.Exit(globals.ExitSavePidError)
+	}
+	defer removePid()
+
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := dispatchers.NewLauncher(conf, channels, list)
+	if err := launcher.Run(); err != nil {
+		msg := fmt.Sprintf("%s or its daemon is already running: %v", globals.AppName, err)
+		logging.Errorf(msg)
+		fmt.Fprintln(os.Stderr, msg)
+		removePid()
+		os.Exit(globals.ExitAlreadyRunning)
+	}
+
+	select {
+	case sig := <-WaitForSignal():
+		logging.Infof("Got signal: %v", sig)
+	case <-launcher.ShutdownRequested():
+	}
+	logging.LogError(launcher.Stop())
+	logging.Infof("%s Daemon Stop", globals.AppName)
+}
@@ -0,0 +1,320 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+cli.go
+
+Command line interface: submit several objects, wait for verdicts
+*/
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+// Exit codes of command line mode
+const (
+	ExitNoRisk      = 0
+	ExitLowRisk     = 1
+	ExitMediumRisk  = 2
+	ExitHighRisk    = 3
+	ExitUnsupported = 4
+	ExitError       = 10
+)
+
+const pollInterval = time.Second
+
+var ErrNoObjects = errors.New("nothing to submit")
+
+// Options - command line options and objects to submit
+type Options struct {
+	Wait     bool
+	JSON     bool
+	Timeout  time.Duration
+	Priority task.Priority
+	Objects  []string
+}
+
+// ParseArgs - parse command line arguments. Usage and errors are written to output
+func ParseArgs(name string, args []string, output io.Writer) (*Options, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	opts := &Options{}
+	fs.BoolVar(&opts.Wait, "wait", false, "wait for verdicts and exit with code of the most severe one")
+	fs.BoolVar(&opts.JSON, "json", false, "print tasks in JSON format")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "maximum time to wait for verdicts (0 - no limit)")
+	priorityName := fs.String("priority", "interactive", "priority of submitted objects: interactive, folder-scan, recheck or background")
+	fs.Usage = func() {
+		fmt.Fprintf(output, "Usage: %s [options] {path|url|-}...\n", name)
+		fmt.Fprintln(output, "  - - read list of paths and URLs from standard input")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	priority, err := task.ParsePriority(*priorityName)
+	if err != nil {
+		fmt.Fprintln(output, err)
+		return nil, err
+	}
+	opts.Priority = priority
+	opts.Objects = fs.Args()
+	if len(opts.Objects) == 0 {
+		fs.Usage()
+		return nil, ErrNoObjects
+	}
+	return opts, nil
+}
+
+// CLIMode - results are expected on standard output. Otherwise single file
+// is submitted silently, as done from file manager context menu
+func (o *Options) CLIMode() bool {
+	return len(o.Objects) != 1 || o.Objects[0] == "-" || o.Wait || o.JSON
+}
+
+// ExitCode - return exit code for given verdict
+func ExitCode(riskLevel sandbox.RiskLevel) int {
+	switch riskLevel {
+	case sandbox.RiskLevelNoRisk:
+		return ExitNoRisk
+	case sandbox.RiskLevelLow:
+		return ExitLowRisk
+	case sandbox.RiskLevelMedium:
+		return ExitMediumRisk
+	case sandbox.RiskLevelHigh:
+		return ExitHighRisk
+	case sandbox.RiskLevelUnsupported:
+		return ExitUnsupported
+	default:
+		return ExitError
+	}
+}
+
+// Worst - return most severe of two verdicts. Not analyzed object is more
+// severe than clean one. Unknown and not ready verdicts are considered as errors
+func Worst(a, b sandbox.RiskLevel) sandbox.RiskLevel {
+	if a == sandbox.RiskLevelUnknown || a == sandbox.RiskLevelNotReady {
+		a = sandbox.RiskLevelError
+	}
+	if b == sandbox.RiskLevelUnknown || b == sandbox.RiskLevelNotReady {
+		b = sandbox.RiskLevelError
+	}
+	if severity(a) > severity(b) {
+		return a
+	}
+	return b
+}
+
+// severity - order of verdicts for exit code
+func severity(riskLevel sandbox.RiskLevel) int {
+	switch riskLevel {
+	case sandbox.RiskLevelNoRisk:
+		return 0
+	case sandbox.RiskLevelUnsupported:
+		return 1
+	case sandbox.RiskLevelLow:
+		return 2
+	case sandbox.RiskLevelMedium:
+		return 3
+	case sandbox.RiskLevelHigh:
+		return 4
+	default:
+		return 5
+	}
+}
+
+func IsURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Objects - list of paths and URLs to submit. "-" argument is replaced with
+// list read from standard input, one object per line
+func Objects(args []string, stdin io.Reader) ([]string, error) {
+	var result []string
+	for _, arg := range args {
+		if arg != "-" {
+			result = append(result, arg)
+			continue
+		}
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			result = append(result, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+type CLI struct {
+	client   *ipc.Client
+	wait     bool
+	json     bool
+	timeout  time.Duration
+	priority task.Priority
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+func NewCLI(client *ipc.Client, opts *Options) *CLI {
+	return &CLI{
+		client:   client,
+		wait:     opts.Wait,
+		json:     opts.JSON,
+		timeout:  opts.Timeout,
+		priority: opts.Priority,
+		stdin:    os.Stdin,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+}
+
+func (c *CLI) Run(args []string) int {
+	objects, err := Objects(args, c.stdin)
+	if err != nil {
+		fmt.Fprintf(c.stderr, "read standard input: %v\n", err)
+		return ExitError
+	}
+	if len(objects) == 0 {
+		fmt.Fprintln(c.stderr, ErrNoObjects)
+		return ExitError
+	}
+	ctx := context.Background()
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	exitCode := ExitNoRisk
+	var tasks []*ipc.TaskInfo
+	for _, object := range objects {
+		tsk, err := c.Submit(object)
+		if err != nil {
+			logging.Errorf("Submit %s: %v", object, err)
+			fmt.Fprintf(c.stderr, "%s: %v\n", object, err)
+			exitCode = ExitError
+			continue
+		}
+		logging.Infof("Submitted #%d: %s", tsk.ID, object)
+		tasks = append(tasks, tsk)
+	}
+	if c.wait {
+		worst := sandbox.RiskLevelNoRisk
+		for i, tsk := range tasks {
+			result, err := c.Wait(ctx, tsk.ID)
+			if err != nil {
+				logging.Errorf("Wait for #%d: %v", tsk.ID, err)
+				fmt.Fprintf(c.stderr, "%s: %v\n", tsk.Path, err)
+				worst = sandbox.RiskLevelError
+				continue
+			}
+			tasks[i] = result
+			worst = Worst(worst, result.RiskLevel)
+		}
+		if exitCode == ExitNoRisk {
+			exitCode = ExitCode(worst)
+		}
+	}
+	if err := c.Print(tasks); err != nil {
+		fmt.Fprintln(c.stderr, err)
+		return ExitError
+	}
+	return exitCode
+}
+
+// Submit - submit file or URL. Already submitted object is not an error:
+// its existing task is returned
+func (c *CLI) Submit(object string) (*ipc.TaskInfo, error) {
+	var (
+		tsk *ipc.TaskInfo
+		err error
+	)
+	if IsURL(object) {
+		tsk, err = c.client.SubmitURL(object, c.priority)
+	} else {
+		var path string
+		path, err = filepath.Abs(object)
+		if err != nil {
+			return nil, err
+		}
+		tsk, err = c.client.SubmitFile(path, c.priority)
+	}
+	if errors.Is(err, ipc.ErrAlreadyExists) && tsk != nil {
+		return tsk, nil
+	}
+	return tsk, err
+}
+
+func (c *CLI) Wait(ctx context.Context, id task.ID) (*ipc.TaskInfo, error) {
+	for {
+		tsk, err := c.client.Status(id)
+		if err != nil {
+			return nil, err
+		}
+		if tsk.Channel == task.ChDone || tsk.Channel == task.ChCancelled {
+			return tsk, nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("timeout waiting for result: %v", tsk.GetChannel())
+			}
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+func (c *CLI) Print(tasks []*ipc.TaskInfo) error {
+	if c.json {
+		encoder := json.NewEncoder(c.stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(tasks)
+	}
+	for _, tsk := range tasks {
+		if tsk.Message == "" {
+			fmt.Fprintf(c.stdout, "%s\t%s\n", tsk.Path, tsk.GetChannel())
+		} else {
+			fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", tsk.Path, tsk.GetChannel(), tsk.Message)
+		}
+	}
+	return nil
+}
+
+func SetupCLILogging() (func(), error) {
+	folder, err := globals.LogsFolder()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return nil, err
+	}
+	return logging.NewFileLog(folder, submitLog)
+}
@@ -0,0 +1,257 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+cli_test.go
+
+Test command line interface
+*/
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/ipc"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func TestParseArgs(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected Options
+		cliMode  bool
+		err      error
+	}{
+		{"single file", []string{"file.exe"}, Options{Objects: []string{"file.exe"}}, false, nil},
+		{"several files", []string{"a.exe", "b.exe"}, Options{Objects: []string{"a.exe", "b.exe"}}, true, nil},
+		{"stdin", []string{"-"}, Options{Objects: []string{"-"}}, true, nil},
+		{"wait", []string{"--wait", "a.exe"}, Options{Wait: true, Objects: []string{"a.exe"}}, true, nil},
+		{"json", []string{"-json", "a.exe"}, Options{JSON: true, Objects: []string{"a.exe"}}, true, nil},
+		{"timeout", []string{"-wait", "-timeout", "5m", "a.exe"}, Options{Wait: true, Timeout: 5 * time.Minute, Objects: []string{"a.exe"}}, true, nil},
+		{"priority", []string{"-priority", "folder-scan", "a.exe"}, Options{Priority: task.PriorityFolderScan, Objects: []string{"a.exe"}}, false, nil},
+		{"unknown priority", []string{"-priority", "urgent", "a.exe"}, Options{}, false, task.ErrUnknownPriority},
+		{"no objects", []string{"-wait"}, Options{}, false, ErrNoObjects},
+		{"help", []string{"-h"}, Options{}, false, flag.ErrHelp},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var output bytes.Buffer
+			opts, err := ParseArgs("submit", tCase.args, &output)
+			if tCase.err != nil {
+				if !errors.Is(err, tCase.err) {
+					t.Errorf("expected %v, got %v", tCase.err, err)
+				}
+				if output.Len() == 0 {
+					t.Error("nothing is written to output")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if opts.Wait != tCase.expected.Wait ||
+				opts.JSON != tCase.expected.JSON ||
+				opts.Timeout != tCase.expected.Timeout ||
+				opts.Priority != tCase.expected.Priority ||
+				!slices.Equal(opts.Objects, tCase.expected.Objects) {
+				t.Errorf("expected %+v, got %+v", tCase.expected, *opts)
+			}
+			if opts.CLIMode() != tCase.cliMode {
+				t.Errorf("expected CLI mode %v", tCase.cliMode)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	testCases := []struct {
+		riskLevel sandbox.RiskLevel
+		expected  int
+	}{
+		{sandbox.RiskLevelNoRisk, ExitNoRisk},
+		{sandbox.RiskLevelLow, ExitLowRisk},
+		{sandbox.RiskLevelMedium, ExitMediumRisk},
+		{sandbox.RiskLevelHigh, ExitHighRisk},
+		{sandbox.RiskLevelUnsupported, ExitUnsupported},
+		{sandbox.RiskLevelError, ExitError},
+		{sandbox.RiskLevelUnknown, ExitError},
+		{sandbox.RiskLevelNotReady, ExitError},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.riskLevel.String(), func(t *testing.T) {
+			if actual := ExitCode(tCase.riskLevel); actual != tCase.expected {
+				t.Errorf("expected %d, got %d", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWorst(t *testing.T) {
+	testCases := []struct {
+		a, b     sandbox.RiskLevel
+		expected sandbox.RiskLevel
+	}{
+		{sandbox.RiskLevelNoRisk, sandbox.RiskLevelNoRisk, sandbox.RiskLevelNoRisk},
+		{sandbox.RiskLevelNoRisk, sandbox.RiskLevelLow, sandbox.RiskLevelLow},
+		{sandbox.RiskLevelHigh, sandbox.RiskLevelMedium, sandbox.RiskLevelHigh},
+		{sandbox.RiskLevelHigh, sandbox.RiskLevelError, sandbox.RiskLevelError},
+		{sandbox.RiskLevelLow, sandbox.RiskLevelUnknown, sandbox.RiskLevelError},
+		{sandbox.RiskLevelNotReady, sandbox.RiskLevelNoRisk, sandbox.RiskLevelError},
+		{sandbox.RiskLevelNoRisk, sandbox.RiskLevelUnsupported, sandbox.RiskLevelUnsupported},
+		{sandbox.RiskLevelUnsupported, sandbox.RiskLevelLow, sandbox.RiskLevelLow},
+	}
+	for _, tCase := range testCases {
+		name := tCase.a.String() + "/" + tCase.b.String()
+		t.Run(name, func(t *testing.T) {
+			if actual := Worst(tCase.a, tCase.b); actual != tCase.expected {
+				t.Errorf("expected %v, got %v", tCase.expected, actual)
+			}
+		})
+	}
+}
+
+// cliSandboxer - IPC handler that submits tasks and gives out verdict
+// named by base name of submitted object. Objects named "pending" never
+// get verdict, "fail" can not be submitted
+type cliSandboxer struct {
+	mx    sync.Mutex
+	tasks map[task.ID]*task.Task
+}
+
+var cliVerdicts = map[string]sandbox.RiskLevel{
+	"clean":       sandbox.RiskLevelNoRisk,
+	"low":         sandbox.RiskLevelLow,
+	"high":        sandbox.RiskLevelHigh,
+	"unsupported": sandbox.RiskLevelUnsupported,
+}
+
+func (s *cliSandboxer) Handle(request *ipc.Request) ipc.Response {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	switch request.Type {
+	case ipc.MessageSubmitFile, ipc.MessageSubmitURL:
+		path := request.Path
+		if request.Type == ipc.MessageSubmitURL {
+			path = request.URL
+		}
+		name := filepath.Base(path)
+		if name == "fail" {
+			return ipc.NewErrorResponse(fmt.Errorf("%w: %s", ipc.ErrBadRequest, path))
+		}
+		tsk := &task.Task{Path: path, Priority: request.Priority, Channel: task.ChPrefilter}
+		if riskLevel, ok := cliVerdicts[name]; ok {
+			tsk.Channel = task.ChDone
+			tsk.RiskLevel = riskLevel
+		}
+		id := task.ID(len(s.tasks) + 1)
+		s.tasks[id] = tsk
+		return ipc.Response{Task: &ipc.TaskInfo{ID: id, Task: tsk}}
+	case ipc.MessageQueryStatus:
+		tsk, ok := s.tasks[request.TaskID]
+		if !ok {
+			return ipc.NewErrorResponse(fmt.Errorf("task #%d: %w", request.TaskID, ipc.ErrNotFound))
+		}
+		return ipc.Response{Task: &ipc.TaskInfo{ID: request.TaskID, Task: tsk}}
+	}
+	return ipc.NewErrorResponse(fmt.Errorf("%w: %s", ipc.ErrBadRequest, request.Type))
+}
+
+func startCLI(t *testing.T, opts *Options, stdin string) (*CLI, *bytes.Buffer) {
+	t.Helper()
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	path := filepath.Join(t.TempDir(), "test.sock")
+	server := ipc.NewServer(path, &cliSandboxer{tasks: make(map[task.ID]*task.Task)})
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	client, err := ipc.Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		if err := server.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	cli := NewCLI(client, opts)
+	var stdout bytes.Buffer
+	cli.stdin = strings.NewReader(stdin)
+	cli.stdout = &stdout
+	cli.stderr = io.Discard
+	return cli, &stdout
+}
+
+func TestCLIRun(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     Options
+		stdin    string
+		objects  []string
+		exitCode int
+		lines    int
+	}{
+		{"submit", Options{Objects: []string{"high", "pending"}}, "", []string{"high", "pending"}, ExitNoRisk, 2},
+		{"wait clean", Options{Wait: true}, "", []string{"clean", "https://example.com/clean"}, ExitNoRisk, 2},
+		{"wait worst", Options{Wait: true}, "", []string{"clean", "low", "high"}, ExitHighRisk, 3},
+		{"wait unsupported", Options{Wait: true}, "", []string{"unsupported"}, ExitUnsupported, 1},
+		{"wait timeout", Options{Wait: true, Timeout: 10 * time.Millisecond}, "", []string{"low", "pending"}, ExitError, 2},
+		{"submit error", Options{Wait: true}, "", []string{"fail", "high"}, ExitError, 1},
+		{"stdin", Options{Wait: true}, "low\n\n# comment\nclean\n", []string{"-"}, ExitLowRisk, 2},
+		{"empty stdin", Options{}, "# nothing\n", []string{"-"}, ExitError, 0},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			cli, stdout := startCLI(t, &tCase.opts, tCase.stdin)
+			exitCode := cli.Run(tCase.objects)
+			if exitCode != tCase.exitCode {
+				t.Errorf("expected exit code %d, got %d", tCase.exitCode, exitCode)
+			}
+			lines := strings.Count(stdout.String(), "\n")
+			if lines != tCase.lines {
+				t.Errorf("expected %d lines, got %d: %s", tCase.lines, lines, stdout)
+			}
+		})
+	}
+}
+
+func TestCLIJSON(t *testing.T) {
+	cli, stdout := startCLI(t, &Options{Wait: true, JSON: true, Priority: task.PriorityBackground}, "")
+	if exitCode := cli.Run([]string{"low", "https://example.com/high"}); exitCode != ExitHighRisk {
+		t.Errorf("expected exit code %d, got %d", ExitHighRisk, exitCode)
+	}
+	var tasks []ipc.TaskInfo
+	if err := json.Unmarshal(stdout.Bytes(), &tasks); err != nil {
+		t.Fatalf("%v: %s", err, stdout)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks, got %d", len(tasks))
+	}
+	for i, expected := range []sandbox.RiskLevel{sandbox.RiskLevelLow, sandbox.RiskLevelHigh} {
+		tsk := tasks[i]
+		if tsk.ID != task.ID(i+1) || tsk.Channel != task.ChDone || tsk.RiskLevel != expected {
+			t.Errorf("wrong task #%d: %+v", i, tsk.Task)
+		}
+		if tsk.Priority != task.PriorityBackground {
+			t.Errorf("expected priority %v, got %v", task.PriorityBackground, tsk.Priority)
+		}
+	}
+	if tasks[1].Path != "https://example.com/high" || !filepath.IsAbs(tasks[0].Path) {
+		t.Errorf("wrong paths: %s, %s", tasks[0].Path, tasks[1].Path)
+	}
+}
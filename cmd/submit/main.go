@@ -10,17 +10,18 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
-	"strings"
 	"time"
 
 	"sandboxer/pkg/config"
 	"sandboxer/pkg/fatal"
-	"sandboxer/pkg/fifo"
 	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/xplatform"
 )
@@ -33,54 +34,62 @@ func SubmissionsExecutablePath(conf *config.Configuration) (string, error) {
 	return xplatform.ExecutablePath(conf.GetFolder(), globals.AppName, globals.Name)
 }
 
-func LaunchSandboxer(conf *config.Configuration) {
+// LaunchSandboxer - start Sandboxer in background
+func LaunchSandboxer(conf *config.Configuration) error {
 	logging.Infof("Launch " + globals.AppName)
 	executablePath, err := SubmissionsExecutablePath(conf)
 	if err != nil {
-		fatal.Warning("Config Error", err.Error())
-		panic(err)
+		return err
 	}
 	logging.Infof("Run " + executablePath)
 	cmd := exec.Command(executablePath, "--submissions")
 	if err := cmd.Start(); err != nil {
-		fatal.Warning("Execute Error", err.Error())
-		panic(err)
+		return err
 	}
-	//	if err := cmd.Wait(); err != nil {
-	//		logging.Debugf(globals.AppName+" exited with with error: %v", err)
-	//	}
 	logging.Infof("Launched " + globals.AppName)
+	return nil
 }
 
-func OpenFIFO(conf *config.Configuration) *fifo.Writer {
-	fifoWriter, err := fifo.NewWriter()
-	if err == nil {
-		return fifoWriter
+// Connect - connect to Sandboxer launching it if it is not running
+func Connect(conf *config.Configuration) (*ipc.Client, error) {
+	client, err := ipc.DialDefault()
+	if err == nil || !ipc.IsDown(err) {
+		return client, err
 	}
-	if !fifo.IsDown(err) {
-		fatal.Warning("FIFO Error", err.Error())
-		panic(err)
+	if err := LaunchSandboxer(conf); err != nil {
+		return nil, err
 	}
-	LaunchSandboxer(conf)
 	for i := 0; i < 10; i++ {
-		logging.Debugf("Wait for FIFO")
-		fifoWriter, err = fifo.NewWriter()
+		logging.Debugf("Wait for %s", globals.AppName)
+		client, err = ipc.DialDefault()
 		if err == nil {
-			break
+			return client, nil
 		}
 		time.Sleep(1 * time.Second)
 	}
-	return fifoWriter
+	return nil, err
 }
 
 func main() {
+	opts, err := ParseArgs(filepath.Base(os.Args[0]), os.Args[1:], os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(ExitNoRisk)
+	}
+	if err != nil {
+		os.Exit(ExitError)
+	}
 	configFilePath, err := globals.ConfigurationFilePath()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "ConfigurationFilePath: %v", err)
-		fatal.Warning("Configuration Error", err.Error())
+		if !opts.CLIMode() {
+			fatal.Warning("Configuration Error", err.Error())
+		}
 		os.Exit(10)
 	}
 	conf := config.New(configFilePath)
+	if opts.CLIMode() {
+		os.Exit(RunCLI(conf, opts))
+	}
 	if err := conf.Load(); err != nil {
 		if runtime.GOOS == "windows" { // After creating darwin installer this if should be removed
 			fmt.Fprintf(os.Stderr, "conf.Load: %v", err)
@@ -102,29 +111,48 @@ func main() {
 		}
 	}()
 	logging.Infof("%s Version %s Build %s Submit Started", globals.AppName, globals.Version, globals.Build)
-	if len(os.Args) != 2 {
-		msg := fmt.Sprintf("Missing or wrong number of parameters: %s", strings.Join(os.Args[1:], " "))
-		logging.Errorf(msg)
-		fatal.Warning("Arguments Error", msg)
-		os.Exit(40)
-	}
-	filePath := os.Args[1]
+	filePath := opts.Objects[0]
 	logging.Infof("Submit \"%s\"", filePath)
-	fifoWriter := OpenFIFO(conf)
-	if fifoWriter == nil {
-		msg := fmt.Sprintf(globals.AppName + " is not running and can not be launched")
+	client, err := Connect(conf)
+	if err != nil {
+		msg := fmt.Sprintf("%s is not running and can not be launched: %v", globals.AppName, err)
 		logging.Errorf(msg)
 		fatal.Warning("Configuration Error", msg)
 		os.Exit(50)
 	}
 	defer func() {
-		logging.LogError(fifoWriter.Close())
+		logging.LogError(client.Close())
 	}()
-	if err = fifoWriter.Write(filePath); err != nil {
-		msg := fmt.Sprintf("fifoWriter.Write: %v", err)
-		logging.Errorf(msg)
-		fatal.Warning("Configuration Error", msg)
-		os.Exit(60)
+	if _, err = client.SubmitFile(filePath, opts.Priority); err != nil {
+		if !errors.Is(err, ipc.ErrAlreadyExists) {
+			msg := fmt.Sprintf("Submit: %v", err)
+			logging.Errorf(msg)
+			fatal.Warning("Submit Error", msg)
+			os.Exit(60)
+		}
+		logging.Infof("Already submitted: %s", filePath)
 	}
 	logging.Infof("Submit finished")
 }
+
+// RunCLI - command line mode: no dialogs, results on stdout, errors on stderr.
+// Sandboxer is launched if it is not running
+func RunCLI(conf *config.Configuration, opts *Options) int {
+	closeLogging, err := SetupCLILogging()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "SetupLogging: %v\n", err)
+	} else {
+		defer closeLogging()
+	}
+	logging.Infof("%s Version %s Build %s Submit CLI Started", globals.AppName, globals.Version, globals.Build)
+	if err := conf.Load(); err != nil {
+		logging.Errorf("conf.Load: %v", err)
+	}
+	client, err := Connect(conf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return ExitError
+	}
+	defer client.Close()
+	return NewCLI(client, opts).Run(opts.Objects)
+}
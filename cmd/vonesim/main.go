@@ -0,0 +1,91 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+main.go
+
+Run Vision One API emulator
+*/
+package main
+
+import (
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/vonesim"
+)
+
+func LoadRules(filePath string) (sandbox.RiskLevel, []sandbox.MockRule, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, nil, err
+	}
+	mock := config.NewDefaultMock()
+	if err := yaml.Unmarshal(data, mock); err != nil {
+		return 0, nil, err
+	}
+	defaultRiskLevel, err := sandbox.ParseRiskLevel(mock.DefaultVerdict)
+	if err != nil {
+		return 0, nil, err
+	}
+	var rules []sandbox.MockRule
+	for i, r := range mock.Rules {
+		rule, err := sandbox.ParseMockRule(r.Hash, r.Pattern, r.EICAR, r.Verdict, r.Threat, r.Fail)
+		if err != nil {
+			return 0, nil, fmt.Errorf("rule #%d: %w", i+1, err)
+		}
+		rules = append(rules, rule)
+	}
+	return defaultRiskLevel, rules, nil
+}
+
+func main() {
+	address := flag.String("address", "127.0.0.1:8443", "address to listen on")
+	token := flag.String("token", "vonesim", "API token expected from clients")
+	analysisTime := flag.Duration("analysis-time", 10*time.Second, "time to report submission as running")
+	reserve := flag.Int("reserve", 10000, "daily submissions reserve")
+	rulesPath := flag.String("rules", "", "YAML file with verdict rules in format of mock section of sandboxer.yaml")
+	certOut := flag.String("cert-out", "", "save generated certificate to file (to be used as SSL_CERT_FILE by clients)")
+	flag.Parse()
+
+	sim := vonesim.New(*token).
+		SetAnalysisTime(*analysisTime).
+		SetDailyReserve(*reserve)
+	if *rulesPath != "" {
+		defaultRiskLevel, rules, err := LoadRules(*rulesPath)
+		if err != nil {
+			log.Fatalf("%s: %v", *rulesPath, err)
+		}
+		sim.SetRules(defaultRiskLevel, rules)
+	}
+	host, _, err := net.SplitHostPort(*address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cert, certPEM, err := vonesim.SelfSignedCertificate(host, "localhost", "127.0.0.1")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *certOut != "" {
+		if err := os.WriteFile(*certOut, certPEM, 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+	server := &http.Server{
+		Addr:      *address,
+		Handler:   sim,
+		TLSConfig: &tls.Config{Certificates: []tls.Certificate{cert}},
+	}
+	log.Printf("Vision One emulator is listening on %s (domain %s, token %s)", *address, *address, *token)
+	log.Fatal(server.ListenAndServeTLS("", ""))
+}
@@ -3,8 +3,8 @@ module sandboxer
 go 1.21.5
 
 require (
-	bitbucket.org/avd/go-ipc v0.6.1
 	fyne.io/fyne/v2 v2.4.4
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/go-ole/go-ole v1.3.0
 	github.com/go-toast/toast v0.0.0-20190211030409-01e6764cf0a4
 	github.com/google/uuid v1.6.0
@@ -14,6 +14,7 @@ require (
 	github.com/mpkondrashin/fileicon v0.0.7
 	github.com/mpkondrashin/vone v0.0.30
 	github.com/virtuald/go-paniclog v0.0.0-20190812204905-43a7fa316459
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/mod v0.16.0
 	golang.org/x/text v0.14.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -24,7 +25,6 @@ require (
 	fyne.io/systray v1.10.1-0.20240111184411-11c585fff98d // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/fredbi/uri v1.1.0 // indirect
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/fyne-io/gl-js v0.0.0-20230506162202-1fdaa286a934 // indirect
 	github.com/fyne-io/glfw-js v0.0.0-20240101223322-6e1efdc71b7a // indirect
 	github.com/fyne-io/image v0.0.0-20240121103648-c3c798e60e6b // indirect
@@ -0,0 +1,376 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+server.go
+
+Local HTTP/JSON API to submit and query tasks
+*/
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+const (
+	tasksPath           = "/tasks"
+	metricsPath         = "/metrics"
+	reportSuffix        = "report"
+	investigationSuffix = "investigation"
+	maxUploadSize       = 1 << 30
+	shutdownTimeout     = 5 * time.Second
+)
+
+// SubmitRequest - JSON body of POST /tasks
+type SubmitRequest struct {
+	Path     string        `json:"path,omitempty"`
+	URL      string        `json:"url,omitempty"`
+	Priority task.Priority `json:"priority,omitempty"`
+}
+
+// TaskResponse - task with its ID
+type TaskResponse struct {
+	ID task.ID `json:"ID"`
+	*task.Task
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+type Server struct {
+	token    string
+	list     *task.TaskList
+	channels *task.Channels
+	metrics  func() any
+	server   *http.Server
+}
+
+// NewServer - API server that accepts requests with given bearer token only
+func NewServer(address string, token string, list *task.TaskList, channels *task.Channels) *Server {
+	s := &Server{
+		token:    token,
+		list:     list,
+		channels: channels,
+	}
+	s.server = &http.Server{
+		Addr:              address,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// SetMetrics - set source of processing metrics returned by GET /metrics
+func (s *Server) SetMetrics(metrics func() any) *Server {
+	s.metrics = metrics
+	return s
+}
+
+// Start - listen on configured address and serve requests in background
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	logging.Infof("API listen on %s", listener.Addr())
+	go func() {
+		err := s.server.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			logging.Errorf("API server: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logging.Debugf("API %s %s", r.Method, r.URL.Path)
+	if err := s.CheckOrigin(r); err != nil {
+		s.Error(w, http.StatusForbidden, err)
+		return
+	}
+	if !s.Authorized(r) {
+		s.Error(w, http.StatusUnauthorized, errors.New("wrong API token"))
+		return
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	parts := strings.Split(path, "/")
+	if path == strings.Trim(metricsPath, "/") && s.metrics != nil {
+		if r.Method != http.MethodGet {
+			s.MethodNotAllowed(w, r)
+			return
+		}
+		s.JSON(w, http.StatusOK, s.metrics())
+		return
+	}
+	if parts[0] != strings.Trim(tasksPath, "/") {
+		s.Error(w, http.StatusNotFound, fmt.Errorf("%s: not found", r.URL.Path))
+		return
+	}
+	switch len(parts) {
+	case 1:
+		switch r.Method {
+		case http.MethodGet:
+			s.ListTasks(w, r)
+		case http.MethodPost:
+			s.SubmitTask(w, r)
+		default:
+			s.MethodNotAllowed(w, r)
+		}
+		return
+	case 2, 3:
+		if r.Method != http.MethodGet {
+			s.MethodNotAllowed(w, r)
+			return
+		}
+		id, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			s.Error(w, http.StatusBadRequest, fmt.Errorf("%s: wrong task ID", parts[1]))
+			return
+		}
+		tsk := s.list.Get(task.ID(id))
+		if tsk == nil {
+			s.Error(w, http.StatusNotFound, fmt.Errorf("missing task #%d", id))
+			return
+		}
+		if len(parts) == 2 {
+			s.JSON(w, http.StatusOK, TaskResponse{tsk.Number, tsk})
+			return
+		}
+		switch parts[2] {
+		case reportSuffix:
+			s.ServeTaskFile(w, r, tsk.Report, "application/pdf")
+		case investigationSuffix:
+			s.ServeTaskFile(w, r, tsk.Investigation, "application/zip")
+		default:
+			s.Error(w, http.StatusNotFound, fmt.Errorf("%s: not found", r.URL.Path))
+		}
+		return
+	}
+	s.Error(w, http.StatusNotFound, fmt.Errorf("%s: not found", r.URL.Path))
+}
+
+// CheckOrigin - reject requests for other host names and requests from web
+// pages of other origins, as browser can be made to send them to local
+// port by any page
+func (s *Server) CheckOrigin(r *http.Request) error {
+	if !s.LocalHost(r.Host) {
+		return fmt.Errorf("%s: host is not allowed", r.Host)
+	}
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || !s.LocalHost(u.Host) {
+			return fmt.Errorf("%s: origin is not allowed", origin)
+		}
+	}
+	return nil
+}
+
+// Authorized - request has bearer token of the server
+func (s *Server) Authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return s.token != "" && ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+// LocalHost - host name is loopback address, localhost or host of address
+// server listens on
+func (s *Server) LocalHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return true
+	}
+	listen, _, err := net.SplitHostPort(s.server.Addr)
+	return err == nil && listen != "" && strings.EqualFold(host, listen)
+}
+
+func (s *Server) ListTasks(w http.ResponseWriter, r *http.Request) {
+	result := make([]TaskResponse, 0)
+	s.list.Process(func(ids []task.ID) {
+		for _, id := range ids {
+			tsk := s.list.Get(id)
+			if tsk == nil {
+				continue
+			}
+			result = append(result, TaskResponse{id, tsk})
+		}
+	})
+	s.JSON(w, http.StatusOK, result)
+}
+
+func (s *Server) SubmitTask(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	priority, err := task.ParsePriority(r.URL.Query().Get("priority"))
+	if err != nil {
+		s.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	var (
+		taskType task.TaskType
+		path     string
+	)
+	switch mediaType {
+	case "application/json":
+		taskType, path, priority, err = s.DecodeSubmitRequest(r.Body, priority)
+	case "multipart/form-data":
+		taskType = task.FileTask
+		path, err = s.SaveMultipart(r)
+	case "application/octet-stream":
+		taskType = task.FileTask
+		path, err = s.SaveUpload(r.URL.Query().Get("name"), r.Body)
+	default:
+		s.Error(w, http.StatusUnsupportedMediaType, fmt.Errorf("%s: unsupported content type", r.Header.Get("Content-Type")))
+		return
+	}
+	if err != nil {
+		s.Error(w, http.StatusBadRequest, err)
+		return
+	}
+	id, err := s.list.NewTask(taskType, path)
+	if err != nil {
+		if !errors.Is(err, task.ErrAlreadyExists) {
+			s.Error(w, http.StatusInternalServerError, err)
+			return
+		}
+		tsk := s.list.FindTask(path)
+		if tsk == nil {
+			s.Error(w, http.StatusConflict, err)
+			return
+		}
+		s.JSON(w, http.StatusOK, TaskResponse{tsk.Number, tsk})
+		return
+	}
+	s.list.Get(id).SetPriority(priority)
+	s.channels.Push(task.ChPrefilter, id, priority)
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", tasksPath, id))
+	s.JSON(w, http.StatusCreated, TaskResponse{id, s.list.Get(id)})
+}
+
+// DecodeSubmitRequest - parse JSON body. Priority from body overrides
+// priority given as query parameter
+func (s *Server) DecodeSubmitRequest(body io.Reader, priority task.Priority) (task.TaskType, string, task.Priority, error) {
+	request := SubmitRequest{Priority: priority}
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		return 0, "", 0, err
+	}
+	if request.URL != "" && request.Path != "" {
+		return 0, "", 0, errors.New("both path and url are provided")
+	}
+	if request.URL != "" {
+		return task.URLTask, strings.TrimSpace(request.URL), request.Priority, nil
+	}
+	if request.Path == "" {
+		return 0, "", 0, errors.New("path or url should be provided")
+	}
+	path, err := filepath.Abs(request.Path)
+	if err != nil {
+		return 0, "", 0, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return 0, "", 0, err
+	}
+	return task.FileTask, path, request.Priority, nil
+}
+
+func (s *Server) SaveMultipart(r *http.Request) (string, error) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	return s.SaveUpload(header.Filename, file)
+}
+
+// SaveUpload - store uploaded body to its own folder to keep original file name
+func (s *Server) SaveUpload(fileName string, body io.Reader) (string, error) {
+	fileName = filepath.Base(filepath.Clean("/" + fileName))
+	if fileName == "/" || fileName == "." || fileName == `\` {
+		fileName = "upload.bin"
+	}
+	uploads, err := globals.UploadsFolder()
+	if err != nil {
+		return "", err
+	}
+	folder := filepath.Join(uploads, uuid.NewString())
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(folder, fileName)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, body); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (s *Server) ServeTaskFile(w http.ResponseWriter, r *http.Request, path string, contentType string) {
+	if path == "" {
+		s.Error(w, http.StatusNotFound, errors.New("not available yet"))
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		s.Error(w, http.StatusNotFound, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		s.Error(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filepath.Base(path)}))
+	http.ServeContent(w, r, filepath.Base(path), info.ModTime(), f)
+}
+
+func (s *Server) MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	s.Error(w, http.StatusMethodNotAllowed, fmt.Errorf("%s: method not allowed", r.Method))
+}
+
+func (s *Server) Error(w http.ResponseWriter, status int, err error) {
+	logging.Errorf("API: %v", err)
+	s.JSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+func (s *Server) JSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	logging.LogError(json.NewEncoder(w).Encode(data))
+}
@@ -0,0 +1,220 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+server_test.go
+
+Test API server
+*/
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+func TestServer(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	t.Setenv("HOME", folder)
+	t.Setenv("XDG_CONFIG_HOME", folder)
+	t.Setenv("APPDATA", folder)
+	filePath := filepath.Join(folder, "file.txt")
+	if err := os.WriteFile(filePath, []byte("Hello World!"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list := task.NewList()
+	channels := task.NewChannels()
+	const token = "secret"
+	server := NewServer("", token, list, channels).SetMetrics(func() any {
+		return map[string]int{"queued": channels.TaskChannel[task.ChPrefilter].Len()}
+	})
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	request := func(t *testing.T, method, url, contentType string, body io.Reader) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, url, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	submit := func(t *testing.T, contentType string, body string, expectedStatus int) TaskResponse {
+		t.Helper()
+		resp := request(t, http.MethodPost, ts.URL+tasksPath, contentType, strings.NewReader(body))
+		defer resp.Body.Close()
+		if resp.StatusCode != expectedStatus {
+			t.Fatalf("Expected %d, but got %d", expectedStatus, resp.StatusCode)
+		}
+		var result TaskResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	var fileTaskID, uploadTaskID task.ID
+	t.Run("submit path", func(t *testing.T) {
+		body, _ := json.Marshal(SubmitRequest{Path: filePath})
+		result := submit(t, "application/json", string(body), http.StatusCreated)
+		if result.Path != filePath {
+			t.Errorf("Expected %s, but got %s", filePath, result.Path)
+		}
+		fileTaskID = result.ID
+		if id, _ := channels.TaskChannel[task.ChPrefilter].TryPop(); id != fileTaskID {
+			t.Errorf("Expected #%d in prefilter channel, but got #%d", fileTaskID, id)
+		}
+	})
+	t.Run("submit same path", func(t *testing.T) {
+		body, _ := json.Marshal(SubmitRequest{Path: filePath})
+		result := submit(t, "application/json", string(body), http.StatusOK)
+		if result.ID != fileTaskID {
+			t.Errorf("Expected #%d, but got #%d", fileTaskID, result.ID)
+		}
+	})
+	t.Run("submit url", func(t *testing.T) {
+		result := submit(t, "application/json", `{"url": "http://www.example.com", "priority": "background"}`, http.StatusCreated)
+		if result.Type != task.URLTask {
+			t.Errorf("Expected %v, but got %v", task.URLTask, result.Type)
+		}
+		if result.Priority != task.PriorityBackground {
+			t.Errorf("Expected %v, but got %v", task.PriorityBackground, result.Priority)
+		}
+	})
+	t.Run("submit body", func(t *testing.T) {
+		resp := request(t, http.MethodPost, ts.URL+tasksPath+"?name=../sample.exe&priority=folder-scan", "application/octet-stream", bytes.NewReader([]byte("MZ")))
+		defer resp.Body.Close()
+		var result TaskResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if filepath.Base(result.Path) != "sample.exe" {
+			t.Errorf("Expected sample.exe, but got %s", result.Path)
+		}
+		if result.Priority != task.PriorityFolderScan {
+			t.Errorf("Expected %v, but got %v", task.PriorityFolderScan, result.Priority)
+		}
+		if !strings.HasPrefix(result.Path, folder) {
+			t.Errorf("Upload %s is stored outside of %s", result.Path, folder)
+		}
+		uploadTaskID = result.ID
+	})
+	t.Run("wrong request", func(t *testing.T) {
+		resp := request(t, http.MethodPost, ts.URL+tasksPath, "application/json", strings.NewReader(`{}`))
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected %d, but got %d", http.StatusBadRequest, resp.StatusCode)
+		}
+	})
+	t.Run("refused", func(t *testing.T) {
+		testCases := []struct {
+			name     string
+			header   string
+			value    string
+			host     string
+			expected int
+		}{
+			{"no token", "Authorization", "", "", http.StatusUnauthorized},
+			{"wrong token", "Authorization", "Bearer wrong", "", http.StatusUnauthorized},
+			{"foreign origin", "Origin", "http://www.example.com", "", http.StatusForbidden},
+			{"rebound host", "", "", "www.example.com", http.StatusForbidden},
+			{"plain text", "Content-Type", "text/plain", "", http.StatusUnsupportedMediaType},
+		}
+		for _, tCase := range testCases {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tasksPath, strings.NewReader("http://www.example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			req.Header.Set("Content-Type", "application/octet-stream")
+			if tCase.header != "" {
+				req.Header.Set(tCase.header, tCase.value)
+			}
+			if tCase.host != "" {
+				req.Host = tCase.host
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tCase.expected {
+				t.Errorf("%s: expected %d, but got %d", tCase.name, tCase.expected, resp.StatusCode)
+			}
+		}
+	})
+	t.Run("list", func(t *testing.T) {
+		resp := request(t, http.MethodGet, ts.URL+tasksPath, "", nil)
+		defer resp.Body.Close()
+		var result []TaskResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal(err)
+		}
+		if len(result) != 3 {
+			t.Errorf("Expected 3 tasks, but got %d", len(result))
+		}
+	})
+	t.Run("report", func(t *testing.T) {
+		reportPath := filepath.Join(folder, "report.pdf")
+		if err := os.WriteFile(reportPath, []byte("%PDF-1.4"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		list.Get(fileTaskID).SetReport(reportPath)
+		resp := request(t, http.MethodGet, ts.URL+tasksPath+"/0/"+reportSuffix, "", nil)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected %d, but got %d", http.StatusOK, resp.StatusCode)
+		}
+		if resp.Header.Get("Content-Type") != "application/pdf" {
+			t.Errorf("Wrong content type: %s", resp.Header.Get("Content-Type"))
+		}
+	})
+	t.Run("missing investigation", func(t *testing.T) {
+		resp := request(t, http.MethodGet, ts.URL+tasksPath+"/0/"+investigationSuffix, "", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected %d, but got %d", http.StatusNotFound, resp.StatusCode)
+		}
+	})
+	t.Run("metrics", func(t *testing.T) {
+		resp := request(t, http.MethodGet, ts.URL+metricsPath, "", nil)
+		defer resp.Body.Close()
+		var metrics map[string]int
+		if err := json.NewDecoder(resp.Body).Decode(&metrics); err != nil {
+			t.Fatal(err)
+		}
+		if queued := channels.TaskChannel[task.ChPrefilter].Len(); metrics["queued"] != queued {
+			t.Errorf("Expected %d, but got %v", queued, metrics)
+		}
+	})
+	t.Run("delete upload", func(t *testing.T) {
+		tsk := list.Get(uploadTaskID)
+		if err := tsk.Delete(); err != nil && !errors.Is(err, task.ErrMissingHash) {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Dir(tsk.Path)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Upload folder is not deleted: %v", err)
+		}
+	})
+}
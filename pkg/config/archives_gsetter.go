@@ -0,0 +1,62 @@
+package config
+
+func (s *Archives) GetExpand() bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Expand
+}
+
+func (s *Archives) SetExpand(value bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Expand = value
+}
+
+func (s *Archives) GetMaxDepth() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.MaxDepth
+}
+
+func (s *Archives) SetMaxDepth(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.MaxDepth = value
+}
+
+func (s *Archives) GetMaxSize() int64 {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.MaxSize
+}
+
+func (s *Archives) SetMaxSize(value int64 ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.MaxSize = value
+}
+
+func (s *Archives) GetMaxMembers() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.MaxMembers
+}
+
+func (s *Archives) SetMaxMembers(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.MaxMembers = value
+}
+
+func (s *Archives) GetPasswords() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Passwords
+}
+
+func (s *Archives) SetPasswords(value []string ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Passwords = value
+}
+
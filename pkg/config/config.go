@@ -17,6 +17,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ignore"
 	"sandboxer/pkg/xplatform"
 )
 
@@ -27,14 +28,34 @@ type Configuration struct {
 	SandboxType       SandboxType   `yaml:"sandbox_type"`
 	VisionOne         *VisionOne    `yaml:"vision_one" gsetter:"-"`
 	DDAn              *DDAn         `yaml:"analyzer" gsetter:"-"`
+	Mock              *Mock         `yaml:"mock" gsetter:"-"`
 	Proxy             *Proxy        `yaml:"proxy" gsetter:"-"`
+	Timeouts          *Timeouts     `yaml:"timeouts" gsetter:"-"`
+	Retry             *Retry        `yaml:"retry" gsetter:"-"`
+	Workers           *Workers      `yaml:"workers" gsetter:"-"`
+	Archives          *Archives     `yaml:"archives" gsetter:"-"`
+	FileTypes         *FileTypes    `yaml:"file_types" gsetter:"-"`
+	Limits            *Limits       `yaml:"limits" gsetter:"-"`
+	Watch             *Watch        `yaml:"watch" gsetter:"-"`
+	Sandboxes         []Sandbox     `yaml:"sandboxes,omitempty" gsetter:"-"`
+	Routing           []RoutingRule `yaml:"routing,omitempty" gsetter:"-"`
+	Consensus         Consensus     `yaml:"consensus"`
 	Folder            string        `yaml:"folder"`
 	Ignore            []string      `yaml:"ignore"`
+	IgnoreRules       []ignore.Rule `yaml:"ignore_rules"`
 	Sleep             time.Duration `yaml:"sleep"`
+	PollMaxInterval   time.Duration `yaml:"poll_max_interval"`
 	Periculosum       string        `yaml:"periculosum"`
 	ShowPasswordHint  bool          `yaml:"show_password_hint"`
 	TasksKeepDays     int           `yaml:"task_keep_days"`
 	ShowNotifications bool          `yaml:"notifications"`
+	APIEnabled        bool          `yaml:"api_enabled"`
+	APIAddress        string        `yaml:"api_address"`
+	KnownVerdictAge   time.Duration `yaml:"known_verdict_age"`
+	SandboxLookup     bool          `yaml:"sandbox_lookup"`
+	QuotaMargin       int           `yaml:"quota_margin"`
+	QuotaBulkMargin   int           `yaml:"quota_bulk_margin"`
+	QuotaInterval     time.Duration `yaml:"quota_interval"`
 }
 
 func New(filePath string) *Configuration {
@@ -43,16 +64,34 @@ func New(filePath string) *Configuration {
 		filePath:          filePath,
 		Version:           "",
 		SandboxType:       SandboxVisionOne,
+		Consensus:         ConsensusWorst,
 		Folder:            xplatform.InstallFolder(),
 		Ignore:            []string{".DS_Store", "Thumbs.db"},
+		IgnoreRules:       []ignore.Rule{{Name: "Git", Glob: "**/.git/**"}},
 		Periculosum:       "check",
 		ShowPasswordHint:  true,
 		TasksKeepDays:     60,
 		Sleep:             5 * time.Second,
+		PollMaxInterval:   2 * time.Minute,
 		VisionOne:         &VisionOne{Proxy: proxy},
 		DDAn:              NewDefaultDDAn(proxy),
+		Mock:              NewDefaultMock(),
 		Proxy:             proxy,
+		Timeouts:          NewDefaultTimeouts(),
+		Retry:             NewDefaultRetry(),
+		Workers:           NewDefaultWorkers(),
+		Archives:          NewDefaultArchives(),
+		FileTypes:         NewDefaultFileTypes(),
+		Limits:            NewDefaultLimits(),
+		Watch:             NewDefaultWatch(),
 		ShowNotifications: true,
+		APIEnabled:        false,
+		APIAddress:        "127.0.0.1:8485",
+		KnownVerdictAge:   7 * 24 * time.Hour,
+		SandboxLookup:     true,
+		QuotaMargin:       10,
+		QuotaBulkMargin:   100,
+		QuotaInterval:     10 * time.Minute,
 	}
 }
 
@@ -99,7 +138,11 @@ func (c *Configuration) Load() error {
 	if err != nil {
 		return err
 	}
-	return yaml.Unmarshal(data, c)
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return err
+	}
+	c.setSandboxesProxy()
+	return nil
 }
 
 /*
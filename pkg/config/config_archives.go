@@ -0,0 +1,24 @@
+package config
+
+import "sync"
+
+// Archives - expansion of submitted archives into separate tasks for their
+// files. Limits are applied to all nested archives together
+type Archives struct {
+	mx         sync.RWMutex `gsetter:"-"`
+	Expand     bool         `yaml:"expand"`
+	MaxDepth   int          `yaml:"max_depth"`
+	MaxSize    int64        `yaml:"max_size"`
+	MaxMembers int          `yaml:"max_members"`
+	Passwords  []string     `yaml:"passwords"`
+}
+
+func NewDefaultArchives() *Archives {
+	return &Archives{
+		Expand:     false,
+		MaxDepth:   3,
+		MaxSize:    512 * 1024 * 1024,
+		MaxMembers: 1000,
+		Passwords:  []string{"infected", "virus", "malware"},
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import "sync"
+
+// FileTypes - types of files detected by content that are analyzed. Both
+// lists can have type names (pe, pdf, zip...) or groups (executable,
+// document, script, archive, image, media, text). Empty allow list allows all types
+type FileTypes struct {
+	mx    sync.RWMutex `gsetter:"-"`
+	Allow []string     `yaml:"allow"`
+	Deny  []string     `yaml:"deny"`
+}
+
+func NewDefaultFileTypes() *FileTypes {
+	return &FileTypes{
+		Allow: []string{},
+		Deny:  []string{},
+	}
+}
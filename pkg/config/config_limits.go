@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"sandboxer/pkg/filetype"
+)
+
+// Constraints - objects accepted by sandbox. Zero MaxSize and empty
+// Extensions or Types mean no limit. NoURLs is set for sandbox that does not
+// accept URLs
+type Constraints struct {
+	MaxSize    int64    `yaml:"max_size"`
+	Extensions []string `yaml:"extensions,omitempty"`
+	Types      []string `yaml:"types,omitempty"`
+	NoURLs     bool     `yaml:"no_urls,omitempty"`
+}
+
+// Limits - constraints of each sandbox type
+type Limits struct {
+	mx        sync.RWMutex `gsetter:"-"`
+	VisionOne Constraints  `yaml:"vision_one"`
+	Analyzer  Constraints  `yaml:"analyzer"`
+	Mock      Constraints  `yaml:"mock"`
+}
+
+func NewDefaultLimits() *Limits {
+	return &Limits{
+		VisionOne: Constraints{MaxSize: 60 * 1024 * 1024},
+		Analyzer:  Constraints{},
+		Mock:      Constraints{},
+	}
+}
+
+// Get - constraints of sandbox type
+func (s *Limits) Get(sandboxType SandboxType) Constraints {
+	switch sandboxType {
+	case SandboxVisionOne:
+		return s.GetVisionOne()
+	case SandboxAnalyzer:
+		return s.GetAnalyzer()
+	}
+	return s.GetMock()
+}
+
+// Check - reason why object can not be submitted or empty string if it can
+func (c Constraints) Check(url bool, path string, size int64, fileType string) string {
+	if url {
+		if c.NoURLs {
+			return "URLs are not accepted"
+		}
+		return ""
+	}
+	if c.MaxSize > 0 && size > c.MaxSize {
+		return fmt.Sprintf("file size %d exceeds limit of %d bytes", size, c.MaxSize)
+	}
+	if len(c.Extensions) > 0 && !matchExtension(c.Extensions, path) {
+		return fmt.Sprintf("extension \"%s\" is not supported", filepath.Ext(path))
+	}
+	if len(c.Types) > 0 && !filetype.Type(fileType).Match(c.Types) {
+		return fmt.Sprintf("file type %v is not supported", filetype.Type(fileType))
+	}
+	return ""
+}
+
+// matchExtension - extension of path is one of extensions with or without
+// leading dot. Case is ignored
+func matchExtension(extensions []string, path string) bool {
+	ext := strings.TrimPrefix(filepath.Ext(path), ".")
+	for _, e := range extensions {
+		if strings.EqualFold(strings.TrimPrefix(e, "."), ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// MockRule - verdict for objects matching all non empty conditions
+type MockRule struct {
+	Hash      string `yaml:"hash,omitempty"`
+	Pattern   string `yaml:"pattern,omitempty"`
+	EICAR     bool   `yaml:"eicar,omitempty"`
+	Verdict   string `yaml:"verdict"`
+	Threat    string `yaml:"threat,omitempty"`
+	Fail      string `yaml:"fail,omitempty"`
+	Transient bool   `yaml:"transient,omitempty"`
+}
+
+// Mock - sandbox that works without any service, for testing and demo
+type Mock struct {
+	mx             sync.RWMutex  `gsetter:"-"`
+	DefaultVerdict string        `yaml:"default_verdict"`
+	Latency        time.Duration `yaml:"latency"`
+	AnalysisTime   time.Duration `yaml:"analysis_time"`
+	Rules          []MockRule    `yaml:"rules"`
+}
+
+func NewDefaultMock() *Mock {
+	return &Mock{
+		DefaultVerdict: "No Risk",
+		Latency:        500 * time.Millisecond,
+		AnalysisTime:   30 * time.Second,
+		Rules: []MockRule{
+			{EICAR: true, Verdict: "High Risk", Threat: "Eicar_test_file"},
+		},
+	}
+}
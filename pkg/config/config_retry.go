@@ -0,0 +1,33 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// RetryPolicy - how processing stage is repeated after transient error.
+// Delay before first retry is Backoff and it doubles for each next retry
+// up to MaxBackoff
+type RetryPolicy struct {
+	Attempts   int           `yaml:"attempts"`
+	Backoff    time.Duration `yaml:"backoff"`
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+}
+
+// Retry - retry policies of processing stages
+type Retry struct {
+	mx            sync.RWMutex `gsetter:"-"`
+	Upload        RetryPolicy  `yaml:"upload"`
+	Result        RetryPolicy  `yaml:"result"`
+	Report        RetryPolicy  `yaml:"report"`
+	Investigation RetryPolicy  `yaml:"investigation"`
+}
+
+func NewDefaultRetry() *Retry {
+	return &Retry{
+		Upload:        RetryPolicy{Attempts: 5, Backoff: 30 * time.Second, MaxBackoff: 10 * time.Minute},
+		Result:        RetryPolicy{Attempts: 5, Backoff: 10 * time.Second, MaxBackoff: 5 * time.Minute},
+		Report:        RetryPolicy{Attempts: 3, Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute},
+		Investigation: RetryPolicy{Attempts: 3, Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute},
+	}
+}
@@ -0,0 +1,226 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sandbox - one of configured sandboxes. Missing settings section
+// means that section of the same type from the top level is used
+type Sandbox struct {
+	Name      string       `yaml:"name"`
+	Type      SandboxType  `yaml:"type"`
+	VisionOne *VisionOne   `yaml:"vision_one,omitempty"`
+	DDAn      *DDAn        `yaml:"analyzer,omitempty"`
+	Mock      *Mock        `yaml:"mock,omitempty"`
+	Limits    *Constraints `yaml:"limits,omitempty"`
+}
+
+// UnmarshalYAML - fill sections that are present with defaults before decoding
+func (s *Sandbox) UnmarshalYAML(value *yaml.Node) error {
+	type plain Sandbox
+	p := plain{
+		VisionOne: &VisionOne{},
+		DDAn:      NewDefaultDDAn(nil),
+		Mock:      NewDefaultMock(),
+	}
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	present := make(map[string]bool)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		present[value.Content[i].Value] = true
+	}
+	if !present["vision_one"] {
+		p.VisionOne = nil
+	}
+	if !present["analyzer"] {
+		p.DDAn = nil
+	}
+	if !present["mock"] {
+		p.Mock = nil
+	}
+	if present["limits"] {
+		limits, err := decodeLimits(value, p.Type)
+		if err != nil {
+			return err
+		}
+		p.Limits = limits
+	}
+	*s = Sandbox(p)
+	return nil
+}
+
+// decodeLimits - constraints missing in limits section are taken from
+// defaults of the sandbox type
+func decodeLimits(value *yaml.Node, sandboxType SandboxType) (*Constraints, error) {
+	limits := NewDefaultLimits().Get(sandboxType)
+	for i := 0; i+1 < len(value.Content); i += 2 {
+		if value.Content[i].Value == "limits" {
+			if err := value.Content[i+1].Decode(&limits); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &limits, nil
+}
+
+// RoutingRule - send objects matching all non empty conditions to sandbox
+// or, if FanOut is set, to all listed sandboxes
+type RoutingRule struct {
+	Sandbox    string   `yaml:"sandbox,omitempty"`
+	FanOut     []string `yaml:"fan_out,omitempty"`
+	TaskType   string   `yaml:"type,omitempty"`
+	Extensions []string `yaml:"extensions,omitempty"`
+	Masks      []string `yaml:"masks,omitempty"`
+	MinSize    int64    `yaml:"min_size,omitempty"`
+	MaxSize    int64    `yaml:"max_size,omitempty"`
+}
+
+// Match - check object. Extension and size conditions never match URLs
+func (r *RoutingRule) Match(url bool, path string, size int64) bool {
+	switch strings.ToLower(r.TaskType) {
+	case "":
+	case "url":
+		if !url {
+			return false
+		}
+	case "file":
+		if url {
+			return false
+		}
+	default:
+		return false
+	}
+	if len(r.Extensions) > 0 && (url || !matchExtension(r.Extensions, path)) {
+		return false
+	}
+	if len(r.Masks) > 0 {
+		name := path
+		if !url {
+			name = filepath.Base(path)
+		}
+		found := false
+		for _, mask := range r.Masks {
+			match, err := filepath.Match(strings.ToLower(mask), strings.ToLower(name))
+			if err == nil && match {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.MinSize > 0 && (url || size < r.MinSize) {
+		return false
+	}
+	if r.MaxSize > 0 && (url || size > r.MaxSize) {
+		return false
+	}
+	return true
+}
+
+var ErrUnknownSandbox = errors.New("unknown sandbox")
+
+// GetSandboxes - configured sandboxes. Without sandboxes section, single
+// sandbox of sandbox_type is used
+func (c *Configuration) GetSandboxes() []Sandbox {
+	c.mx.RLock()
+	defer c.mx.RUnlock()
+	if len(c.Sandboxes) == 0 {
+		limits := c.Limits.Get(c.SandboxType)
+		return []Sandbox{{
+			Name:      c.SandboxType.String(),
+			Type:      c.SandboxType,
+			VisionOne: c.VisionOne,
+			DDAn:      c.DDAn,
+			Mock:      c.Mock,
+			Limits:    &limits,
+		}}
+	}
+	result := make([]Sandbox, len(c.Sandboxes))
+	for i, s := range c.Sandboxes {
+		if s.VisionOne == nil {
+			s.VisionOne = c.VisionOne
+		}
+		if s.DDAn == nil {
+			s.DDAn = c.DDAn
+		}
+		if s.Mock == nil {
+			s.Mock = c.Mock
+		}
+		if s.Limits == nil {
+			limits := c.Limits.Get(s.Type)
+			s.Limits = &limits
+		}
+		result[i] = s
+	}
+	return result
+}
+
+// FindSandbox - sandbox with given name. Empty name means first sandbox
+func (c *Configuration) FindSandbox(name string) (Sandbox, error) {
+	sandboxes := c.GetSandboxes()
+	if name == "" {
+		return sandboxes[0], nil
+	}
+	for _, s := range sandboxes {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	return Sandbox{}, fmt.Errorf("%w: %s", ErrUnknownSandbox, name)
+}
+
+// Route - names of sandboxes for object: sandbox of first matching routing
+// rule, all sandboxes of its fan out list or first sandbox
+func (c *Configuration) Route(url bool, path string, size int64) ([]string, error) {
+	c.mx.RLock()
+	routing := c.Routing
+	c.mx.RUnlock()
+	names := []string{""}
+	for _, rule := range routing {
+		if !rule.Match(url, path, size) {
+			continue
+		}
+		names = []string{rule.Sandbox}
+		if len(rule.FanOut) > 0 {
+			names = rule.FanOut
+		}
+		break
+	}
+	result := make([]string, len(names))
+	for i, name := range names {
+		s, err := c.FindSandbox(name)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = s.Name
+	}
+	return result, nil
+}
+
+// SetSandboxes - replace sandboxes and routing rules
+func (c *Configuration) SetSandboxes(sandboxes []Sandbox, routing []RoutingRule) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.Sandboxes = sandboxes
+	c.Routing = routing
+}
+
+// setSandboxesProxy - sandbox own sections do not have proxy after loading
+func (c *Configuration) setSandboxesProxy() {
+	for _, s := range c.Sandboxes {
+		if s.VisionOne != nil && s.VisionOne.Proxy == nil {
+			s.VisionOne.Proxy = c.Proxy
+		}
+		if s.DDAn != nil && s.DDAn.Proxy == nil {
+			s.DDAn.Proxy = c.Proxy
+		}
+	}
+}
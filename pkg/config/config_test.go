@@ -9,8 +9,10 @@ Small test
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,6 +106,220 @@ func TestLoad(t *testing.T) {
 
 }
 
+var configSandboxesTest = `
+sandbox_type: Analyzer
+analyzer:
+    url: "https://ddan.local"
+    api_key: "key"
+sandboxes:
+    - name: cloud
+      type: VisionOne
+      vision_one:
+          token: "abc"
+          domain: "api.xdr.trendmicro.com"
+    - name: onprem
+      type: Analyzer
+      limits:
+          max_size: 1000000
+          extensions: [exe, dll]
+consensus: majority
+routing:
+    - fan_out: [onprem, cloud]
+      masks: ["*.js"]
+    - sandbox: cloud
+      type: url
+    - sandbox: cloud
+      extensions: [zip, .7z, rar]
+    - sandbox: cloud
+      masks: ["*.iso"]
+      min_size: 1000
+    - sandbox: onprem
+`
+
+func TestSandboxes(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "sandboxer.yaml")
+	if err := os.WriteFile(filePath, []byte(configSandboxesTest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(filePath)
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	sandboxes := c.GetSandboxes()
+	if len(sandboxes) != 2 {
+		t.Fatalf("Expected 2 sandboxes, but got %d", len(sandboxes))
+	}
+	t.Run("own section", func(t *testing.T) {
+		if sandboxes[0].VisionOne.GetToken() != "abc" {
+			t.Errorf("Expected abc, but got %s", sandboxes[0].VisionOne.GetToken())
+		}
+		if sandboxes[0].VisionOne.Proxy != c.Proxy {
+			t.Errorf("Proxy is not set")
+		}
+	})
+	t.Run("top level section", func(t *testing.T) {
+		if sandboxes[1].DDAn != c.DDAn {
+			t.Errorf("Expected top level analyzer section")
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		if sandboxes[0].Limits.MaxSize != NewDefaultLimits().VisionOne.MaxSize {
+			t.Errorf("Expected Vision One default limits, but got %v", *sandboxes[0].Limits)
+		}
+		onprem := *sandboxes[1].Limits
+		if onprem.MaxSize != 1000000 || len(onprem.Extensions) != 2 || onprem.NoURLs {
+			t.Errorf("Wrong limits: %v", onprem)
+		}
+	})
+	testCases := []struct {
+		url      bool
+		path     string
+		size     int64
+		expected string
+	}{
+		{true, "http://www.example.com", 0, "cloud"},
+		{false, "/tmp/archive.ZIP", 10, "cloud"},
+		{false, "/tmp/archive.7z", 10, "cloud"},
+		{false, "/tmp/disk.iso", 10, "onprem"},
+		{false, "/tmp/disk.iso", 1000, "cloud"},
+		{false, "/tmp/program.exe", 10, "onprem"},
+		{false, "/tmp/script.js", 10, "onprem,cloud"},
+	}
+	for _, tCase := range testCases {
+		actual, err := c.Route(tCase.url, tCase.path, tCase.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(actual, ",") != tCase.expected {
+			t.Errorf("%s: expected %s, but got %v", tCase.path, tCase.expected, actual)
+		}
+	}
+	t.Run("consensus", func(t *testing.T) {
+		if c.GetConsensus() != ConsensusMajority {
+			t.Errorf("Expected %v, but got %v", ConsensusMajority, c.GetConsensus())
+		}
+	})
+	t.Run("unknown", func(t *testing.T) {
+		c.SetSandboxes(sandboxes, []RoutingRule{{Sandbox: "missing"}})
+		if _, err := c.Route(false, "/tmp/file", 0); !errors.Is(err, ErrUnknownSandbox) {
+			t.Errorf("Expected %v, but got %v", ErrUnknownSandbox, err)
+		}
+	})
+	t.Run("default", func(t *testing.T) {
+		c := New(filePath)
+		actual, err := c.Route(true, "http://www.example.com", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actual) != 1 || actual[0] != SandboxVisionOne.String() {
+			t.Errorf("Expected %v, but got %v", SandboxVisionOne, actual)
+		}
+	})
+}
+
+func TestConstraints(t *testing.T) {
+	c := Constraints{MaxSize: 100, Extensions: []string{".exe", "doc"}, Types: []string{"executable", "ole"}}
+	testCases := []struct {
+		url      bool
+		path     string
+		size     int64
+		fileType string
+		expected string
+	}{
+		{false, "/tmp/program.EXE", 10, "pe", ""},
+		{false, "/tmp/letter.doc", 10, "ole", ""},
+		{false, "/tmp/program.exe", 1000, "pe", "file size 1000 exceeds limit of 100 bytes"},
+		{false, "/tmp/script.js", 10, "script", "extension \".js\" is not supported"},
+		{false, "/tmp/letter.doc", 10, "pdf", "file type PDF is not supported"},
+		{true, "http://www.example.com", 0, "", ""},
+	}
+	for _, tCase := range testCases {
+		if actual := c.Check(tCase.url, tCase.path, tCase.size, tCase.fileType); actual != tCase.expected {
+			t.Errorf("%s: expected \"%s\", but got \"%s\"", tCase.path, tCase.expected, actual)
+		}
+	}
+	c.NoURLs = true
+	if c.Check(true, "http://www.example.com", 0, "") == "" {
+		t.Errorf("URL is accepted")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	defaults := NewDefaultLimits()
+	for sandboxType, expected := range map[SandboxType]Constraints{
+		SandboxVisionOne: defaults.VisionOne,
+		SandboxAnalyzer:  defaults.Analyzer,
+		SandboxMock:      defaults.Mock,
+	} {
+		if actual := defaults.Get(sandboxType); actual.MaxSize != expected.MaxSize || actual.NoURLs != expected.NoURLs {
+			t.Errorf("%v: expected %v, but got %v", sandboxType, expected, actual)
+		}
+	}
+	filePath := filepath.Join(t.TempDir(), "sandboxer.yaml")
+	data := "sandbox_type: mock\nlimits:\n  mock:\n    max_size: 5\n    types: [executable]\n    no_urls: true\n"
+	if err := os.WriteFile(filePath, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(filePath)
+	if err := c.Load(); err != nil {
+		t.Fatal(err)
+	}
+	limits := *c.GetSandboxes()[0].Limits
+	if limits.MaxSize != 5 || len(limits.Types) != 1 || !limits.NoURLs {
+		t.Errorf("Wrong limits: %v", limits)
+	}
+	testCases := []struct {
+		path     string
+		size     int64
+		fileType string
+		expected string
+	}{
+		{"/tmp/program.exe", 5, "pe", ""},
+		{"/tmp/program.exe", 6, "pe", "file size 6 exceeds limit of 5 bytes"},
+		{"/tmp/letter.doc", 5, "ole", "file type OLE is not supported"},
+	}
+	for _, tCase := range testCases {
+		if actual := limits.Check(false, tCase.path, tCase.size, tCase.fileType); actual != tCase.expected {
+			t.Errorf("%s: expected \"%s\", but got \"%s\"", tCase.path, tCase.expected, actual)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator), "home", "user")
+	w := &Watch{Folders: []WatchedFolder{
+		{Path: filepath.Join(root, "Downloads"), Action: WatchNotify},
+		{Path: filepath.Join(root, "Mail"), Recursive: true},
+	}}
+	if _, ok := w.Folder(filepath.Join(root, "Downloads", "setup.exe")); ok {
+		t.Errorf("Disabled watch has folders")
+	}
+	w.SetEnabled(true)
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"Downloads/setup.exe", "Downloads"},
+		{"Downloads/old/setup.exe", ""},
+		{"Downloads", ""},
+		{"Mail/inbox/letter.doc", "Mail"},
+		{"Documents/letter.doc", ""},
+		{"Downloads/..setup.exe", "Downloads"},
+		{"Mail/..data/letter.doc", "Mail"},
+		{"Mail2/letter.doc", ""},
+		{"letter.doc", ""},
+	}
+	for _, tCase := range testCases {
+		actual := ""
+		if folder, ok := w.Folder(filepath.Join(root, filepath.FromSlash(tCase.path))); ok {
+			actual = filepath.Base(folder.Path)
+		}
+		if actual != tCase.expected {
+			t.Errorf("%s: expected \"%s\", but got \"%s\"", tCase.path, tCase.expected, actual)
+		}
+	}
+}
+
 /*
 func TestLoad(t *testing.T) {
 	conf1 := &Configuration{
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// Timeouts - longest time single processing stage can take for one task.
+// Zero means no limit
+type Timeouts struct {
+	mx            sync.RWMutex  `gsetter:"-"`
+	Upload        time.Duration `yaml:"upload"`
+	Result        time.Duration `yaml:"result"`
+	Report        time.Duration `yaml:"report"`
+	Investigation time.Duration `yaml:"investigation"`
+}
+
+func NewDefaultTimeouts() *Timeouts {
+	return &Timeouts{
+		Upload:        10 * time.Minute,
+		Result:        time.Minute,
+		Report:        5 * time.Minute,
+		Investigation: 5 * time.Minute,
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"sandboxer/pkg/xplatform"
+)
+
+// WatchAction - what is done with files that appear in watched folder
+type WatchAction string
+
+const (
+	WatchSubmit WatchAction = "submit" // Analyze file
+	WatchNotify WatchAction = "notify" // Analyze file and notify about any verdict
+)
+
+// WatchedFolder - folder which new and changed files are submitted from.
+// Subfolders are watched only if Recursive is set
+type WatchedFolder struct {
+	Path      string      `yaml:"path"`
+	Recursive bool        `yaml:"recursive"`
+	Action    WatchAction `yaml:"action"`
+}
+
+// Contains - path is inside the folder or its subfolders for recursive one
+func (f WatchedFolder) Contains(path string) bool {
+	rel, err := filepath.Rel(f.Path, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	return f.Recursive || !strings.ContainsRune(rel, filepath.Separator)
+}
+
+// Watch - automatic submission of files from watched folders. File is
+// submitted after it is not changed for Settle duration
+type Watch struct {
+	mx      sync.RWMutex    `gsetter:"-"`
+	Enabled bool            `yaml:"enabled"`
+	Settle  time.Duration   `yaml:"settle"`
+	Folders []WatchedFolder `yaml:"folders"`
+}
+
+func NewDefaultWatch() *Watch {
+	return &Watch{
+		Enabled: false,
+		Settle:  5 * time.Second,
+		Folders: []WatchedFolder{
+			{Path: xplatform.DownloadsFolder(), Recursive: false, Action: WatchSubmit},
+		},
+	}
+}
+
+// Folder - watched folder that contains path. Returns false if watching is
+// disabled or path is not watched
+func (w *Watch) Folder(path string) (WatchedFolder, bool) {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+	if !w.Enabled {
+		return WatchedFolder{}, false
+	}
+	for _, folder := range w.Folders {
+		if folder.Contains(path) {
+			return folder, true
+		}
+	}
+	return WatchedFolder{}, false
+}
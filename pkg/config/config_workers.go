@@ -0,0 +1,27 @@
+package config
+
+import "sync"
+
+// Workers - number of dispatchers working in parallel on each processing stage
+type Workers struct {
+	mx            sync.RWMutex `gsetter:"-"`
+	Prefilter     int          `yaml:"prefilter"`
+	Lookup        int          `yaml:"lookup"`
+	Quota         int          `yaml:"quota"`
+	Upload        int          `yaml:"upload"`
+	Result        int          `yaml:"result"`
+	Report        int          `yaml:"report"`
+	Investigation int          `yaml:"investigation"`
+}
+
+func NewDefaultWorkers() *Workers {
+	return &Workers{
+		Prefilter:     5,
+		Lookup:        5,
+		Quota:         1,
+		Upload:        5,
+		Result:        5,
+		Report:        5,
+		Investigation: 5,
+	}
+}
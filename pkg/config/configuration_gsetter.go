@@ -1,6 +1,9 @@
 package config
 
-import "time"
+import (
+	"sandboxer/pkg/ignore"
+	"time"
+)
 
 func (s *Configuration) GetfilePath() string {
 	s.mx.RLock()
@@ -38,6 +41,18 @@ func (s *Configuration) SetSandboxType(value SandboxType ) {
 	s.SandboxType = value
 }
 
+func (s *Configuration) GetConsensus() Consensus {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Consensus
+}
+
+func (s *Configuration) SetConsensus(value Consensus ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Consensus = value
+}
+
 func (s *Configuration) GetFolder() string {
 	s.mx.RLock()
 	defer s.mx.RUnlock()
@@ -62,6 +77,18 @@ func (s *Configuration) SetIgnore(value []string ) {
 	s.Ignore = value
 }
 
+func (s *Configuration) GetIgnoreRules() []ignore.Rule {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.IgnoreRules
+}
+
+func (s *Configuration) SetIgnoreRules(value []ignore.Rule ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.IgnoreRules = value
+}
+
 func (s *Configuration) GetSleep() time.Duration {
 	s.mx.RLock()
 	defer s.mx.RUnlock()
@@ -74,6 +101,18 @@ func (s *Configuration) SetSleep(value time.Duration ) {
 	s.Sleep = value
 }
 
+func (s *Configuration) GetPollMaxInterval() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.PollMaxInterval
+}
+
+func (s *Configuration) SetPollMaxInterval(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.PollMaxInterval = value
+}
+
 func (s *Configuration) GetPericulosum() string {
 	s.mx.RLock()
 	defer s.mx.RUnlock()
@@ -122,3 +161,87 @@ func (s *Configuration) SetShowNotifications(value bool ) {
 	s.ShowNotifications = value
 }
 
+func (s *Configuration) GetAPIEnabled() bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.APIEnabled
+}
+
+func (s *Configuration) SetAPIEnabled(value bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.APIEnabled = value
+}
+
+func (s *Configuration) GetAPIAddress() string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.APIAddress
+}
+
+func (s *Configuration) SetAPIAddress(value string ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.APIAddress = value
+}
+
+func (s *Configuration) GetKnownVerdictAge() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.KnownVerdictAge
+}
+
+func (s *Configuration) SetKnownVerdictAge(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.KnownVerdictAge = value
+}
+
+func (s *Configuration) GetSandboxLookup() bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.SandboxLookup
+}
+
+func (s *Configuration) SetSandboxLookup(value bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.SandboxLookup = value
+}
+
+func (s *Configuration) GetQuotaMargin() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.QuotaMargin
+}
+
+func (s *Configuration) SetQuotaMargin(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.QuotaMargin = value
+}
+
+func (s *Configuration) GetQuotaBulkMargin() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.QuotaBulkMargin
+}
+
+func (s *Configuration) SetQuotaBulkMargin(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.QuotaBulkMargin = value
+}
+
+func (s *Configuration) GetQuotaInterval() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.QuotaInterval
+}
+
+func (s *Configuration) SetQuotaInterval(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.QuotaInterval = value
+}
+
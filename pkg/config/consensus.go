@@ -0,0 +1,69 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+consensus.go
+
+Policies of final verdict for task submitted to several sandboxes
+*/
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Consensus int
+
+const (
+	ConsensusWorst Consensus = iota
+	ConsensusMajority
+	ConsensusPrimary
+)
+
+// String - return string representation for Consensus value
+func (v Consensus) String() string {
+	s, ok := map[Consensus]string{
+		ConsensusWorst:    "Worst",
+		ConsensusMajority: "Majority",
+		ConsensusPrimary:  "Primary",
+	}[v]
+	if ok {
+		return s
+	}
+	return "Consensus(" + strconv.FormatInt(int64(v), 10) + ")"
+}
+
+// ErrUnknownConsensus - will be returned wrapped when parsing string
+// containing unrecognized value.
+var ErrUnknownConsensus = errors.New("unknown consensus")
+
+var mapConsensusFromString = map[string]Consensus{
+	"worst":    ConsensusWorst,
+	"majority": ConsensusMajority,
+	"primary":  ConsensusPrimary,
+}
+
+// MarshalYAML implements the Marshaler interface of the yaml.v3 package for Consensus.
+func (s Consensus) MarshalYAML() (interface{}, error) {
+	return fmt.Sprintf("%v", s), nil
+}
+
+// UnmarshalYAML implements the Unmarshaler interface of the yaml.v3 package for Consensus.
+func (s *Consensus) UnmarshalYAML(value *yaml.Node) error {
+	var v string
+	err := value.Decode(&v)
+	if err != nil {
+		return err
+	}
+	result, ok := mapConsensusFromString[strings.ToLower(v)]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownConsensus, v)
+	}
+	*s = result
+	return nil
+}
@@ -0,0 +1,26 @@
+package config
+
+func (s *FileTypes) GetAllow() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Allow
+}
+
+func (s *FileTypes) SetAllow(value []string ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Allow = value
+}
+
+func (s *FileTypes) GetDeny() []string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Deny
+}
+
+func (s *FileTypes) SetDeny(value []string ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Deny = value
+}
+
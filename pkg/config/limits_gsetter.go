@@ -0,0 +1,38 @@
+package config
+
+func (s *Limits) GetVisionOne() Constraints {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.VisionOne
+}
+
+func (s *Limits) SetVisionOne(value Constraints ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.VisionOne = value
+}
+
+func (s *Limits) GetAnalyzer() Constraints {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Analyzer
+}
+
+func (s *Limits) SetAnalyzer(value Constraints ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Analyzer = value
+}
+
+func (s *Limits) GetMock() Constraints {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Mock
+}
+
+func (s *Limits) SetMock(value Constraints ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Mock = value
+}
+
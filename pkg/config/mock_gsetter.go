@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+func (s *Mock) GetDefaultVerdict() string {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.DefaultVerdict
+}
+
+func (s *Mock) SetDefaultVerdict(value string ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.DefaultVerdict = value
+}
+
+func (s *Mock) GetLatency() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Latency
+}
+
+func (s *Mock) SetLatency(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Latency = value
+}
+
+func (s *Mock) GetAnalysisTime() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.AnalysisTime
+}
+
+func (s *Mock) SetAnalysisTime(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.AnalysisTime = value
+}
+
+func (s *Mock) GetRules() []MockRule {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Rules
+}
+
+func (s *Mock) SetRules(value []MockRule ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Rules = value
+}
+
@@ -0,0 +1,50 @@
+package config
+
+func (s *Retry) GetUpload() RetryPolicy {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Upload
+}
+
+func (s *Retry) SetUpload(value RetryPolicy ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Upload = value
+}
+
+func (s *Retry) GetResult() RetryPolicy {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Result
+}
+
+func (s *Retry) SetResult(value RetryPolicy ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Result = value
+}
+
+func (s *Retry) GetReport() RetryPolicy {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Report
+}
+
+func (s *Retry) SetReport(value RetryPolicy ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Report = value
+}
+
+func (s *Retry) GetInvestigation() RetryPolicy {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Investigation
+}
+
+func (s *Retry) SetInvestigation(value RetryPolicy ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Investigation = value
+}
+
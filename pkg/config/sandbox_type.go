@@ -24,6 +24,7 @@ type SandboxType int
 const (
 	SandboxVisionOne SandboxType = iota
 	SandboxAnalyzer
+	SandboxMock
 )
 
 // String - return string representation for SandboxType value
@@ -31,6 +32,7 @@ func (v SandboxType) String() string {
 	s, ok := map[SandboxType]string{
 		SandboxVisionOne: "VisionOne",
 		SandboxAnalyzer:  "Analyzer",
+		SandboxMock:      "Mock",
 	}[v]
 	if ok {
 		return s
@@ -45,6 +47,8 @@ var ErrUnknownSandboxType = errors.New("unknown sandbox type")
 var mapSandboxTypeFromString = map[string]SandboxType{
 	"visionone": SandboxVisionOne,
 	"analyzer":  SandboxAnalyzer,
+	"mock":      SandboxMock,
+	"local":     SandboxMock,
 }
 
 // MarshalYAML implements the Marshaler interface of the yaml.v3 package for SandboxType.
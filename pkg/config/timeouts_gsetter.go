@@ -0,0 +1,52 @@
+package config
+
+import "time"
+
+func (s *Timeouts) GetUpload() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Upload
+}
+
+func (s *Timeouts) SetUpload(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Upload = value
+}
+
+func (s *Timeouts) GetResult() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Result
+}
+
+func (s *Timeouts) SetResult(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Result = value
+}
+
+func (s *Timeouts) GetReport() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Report
+}
+
+func (s *Timeouts) SetReport(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Report = value
+}
+
+func (s *Timeouts) GetInvestigation() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Investigation
+}
+
+func (s *Timeouts) SetInvestigation(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Investigation = value
+}
+
@@ -0,0 +1,39 @@
+package config
+
+import "time"
+
+func (s *Watch) GetEnabled() bool {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Enabled
+}
+
+func (s *Watch) SetEnabled(value bool ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Enabled = value
+}
+
+func (s *Watch) GetSettle() time.Duration {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Settle
+}
+
+func (s *Watch) SetSettle(value time.Duration ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Settle = value
+}
+
+func (s *Watch) GetFolders() []WatchedFolder {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Folders
+}
+
+func (s *Watch) SetFolders(value []WatchedFolder ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Folders = value
+}
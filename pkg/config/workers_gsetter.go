@@ -0,0 +1,86 @@
+package config
+
+func (s *Workers) GetPrefilter() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Prefilter
+}
+
+func (s *Workers) SetPrefilter(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Prefilter = value
+}
+
+func (s *Workers) GetLookup() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Lookup
+}
+
+func (s *Workers) SetLookup(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Lookup = value
+}
+
+func (s *Workers) GetQuota() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Quota
+}
+
+func (s *Workers) SetQuota(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Quota = value
+}
+
+func (s *Workers) GetUpload() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Upload
+}
+
+func (s *Workers) SetUpload(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Upload = value
+}
+
+func (s *Workers) GetResult() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Result
+}
+
+func (s *Workers) SetResult(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Result = value
+}
+
+func (s *Workers) GetReport() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Report
+}
+
+func (s *Workers) SetReport(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Report = value
+}
+
+func (s *Workers) GetInvestigation() int {
+	s.mx.RLock()
+	defer s.mx.RUnlock()
+	return s.Investigation
+}
+
+func (s *Workers) SetInvestigation(value int ) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.Investigation = value
+}
+
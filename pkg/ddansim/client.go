@@ -0,0 +1,200 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+client.go
+
+Emulated DDAn client. All calls except Register and TestConnection
+require client UUID to be registered, as real appliance does
+*/
+package ddansim
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mpkondrashin/ddan"
+
+	"sandboxer/pkg/sandbox"
+)
+
+type Client struct {
+	analyzer *Analyzer
+	uuid     string
+}
+
+var _ ddan.ClientInterface = &Client{}
+
+func (c *Client) Register(ctx context.Context) error {
+	if err := c.analyzer.call(c.uuid, "Register", false); err != nil {
+		return err
+	}
+	c.analyzer.Register(c.uuid)
+	return nil
+}
+
+func (c *Client) Unregister(ctx context.Context) error {
+	if err := c.analyzer.call(c.uuid, "Unregister", true); err != nil {
+		return err
+	}
+	c.analyzer.mx.Lock()
+	defer c.analyzer.mx.Unlock()
+	delete(c.analyzer.clients, c.uuid)
+	return nil
+}
+
+func (c *Client) TestConnection(ctx context.Context) error {
+	return c.analyzer.call(c.uuid, "TestConnection", false)
+}
+
+func (c *Client) CheckDuplicateSample(ctx context.Context, sha1List []string, days int) ([]string, error) {
+	if err := c.analyzer.call(c.uuid, "CheckDuplicateSample", true); err != nil {
+		return nil, err
+	}
+	var result []string
+	for _, sha1 := range sha1List {
+		sample, status := c.analyzer.get(sha1)
+		if status == ddan.StatusNotFound {
+			continue
+		}
+		if days > 0 && time.Since(sample.Uploaded) > time.Duration(days)*24*time.Hour {
+			continue
+		}
+		result = append(result, sha1)
+	}
+	return result, nil
+}
+
+func (c *Client) UploadSampleEx(ctx context.Context, filePath, fileName, sha1 string) error {
+	if err := c.analyzer.call(c.uuid, "UploadSampleEx", true); err != nil {
+		return err
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	hashes, eicar, err := sandbox.Inspect(f)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(hashes[1], sha1) {
+		return fmt.Errorf("%s: SHA1 mismatch: %s != %s", fileName, sha1, hashes[1])
+	}
+	c.analyzer.upload(fileName, false, hashes, eicar)
+	return nil
+}
+
+func (c *Client) UploadSampleURLs(ctx context.Context, urls []string) error {
+	if err := c.analyzer.call(c.uuid, "UploadSampleURLs", true); err != nil {
+		return err
+	}
+	for _, url := range urls {
+		hashes, _, err := sandbox.Inspect(strings.NewReader(url))
+		if err != nil {
+			return err
+		}
+		c.analyzer.upload(url, true, hashes, false)
+	}
+	return nil
+}
+
+func (c *Client) GetBriefReport(ctx context.Context, sha1List []string) (*ddan.BriefReports, error) {
+	if err := c.analyzer.call(c.uuid, "GetBriefReport", true); err != nil {
+		return nil, err
+	}
+	reports := &ddan.BriefReports{}
+	for _, sha1 := range sha1List {
+		sample, status := c.analyzer.get(sha1)
+		reports.Reports = appendZero(reports.Reports)
+		report := &reports.Reports[len(reports.Reports)-1]
+		report.SampleStatus = status
+		report.RiskLevel = ddan.RatingUnsupported
+		if status == ddan.StatusDone {
+			report.RiskLevel = Rating(sample.RiskLevel)
+		}
+	}
+	return reports, nil
+}
+
+func (c *Client) GetReport(ctx context.Context, sha1 string) (*ddan.Report, error) {
+	if err := c.analyzer.call(c.uuid, "GetReport", true); err != nil {
+		return nil, err
+	}
+	sample, err := c.analyzer.done(sha1)
+	if err != nil {
+		return nil, err
+	}
+	report := &ddan.Report{}
+	report.FILEANALYZEREPORT = appendZero(report.FILEANALYZEREPORT)
+	report.FILEANALYZEREPORT[0].VirusName.Value = sample.Threat
+	return report, nil
+}
+
+func (c *Client) GetPDFReport(ctx context.Context, sha1 string) (io.Reader, error) {
+	if err := c.analyzer.call(c.uuid, "GetPDFReport", true); err != nil {
+		return nil, err
+	}
+	sample, err := c.analyzer.done(sha1)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Fail == sandbox.MockStageReport {
+		return nil, fmt.Errorf("%s: %w", sample.Name, sandbox.ErrMock)
+	}
+	return bytes.NewReader(sandbox.PlaceholderPDF(sample.lines()...)), nil
+}
+
+func (c *Client) GetPackage(ctx context.Context, sha1 string) (io.Reader, error) {
+	if err := c.analyzer.call(c.uuid, "GetPackage", true); err != nil {
+		return nil, err
+	}
+	sample, err := c.analyzer.done(sha1)
+	if err != nil {
+		return nil, err
+	}
+	if sample.Fail == sandbox.MockStageInvestigation {
+		return nil, fmt.Errorf("%s: %w", sample.Name, sandbox.ErrMock)
+	}
+	var buf bytes.Buffer
+	if err := sandbox.PlaceholderZIP(&buf, sample.lines()...); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
+
+func (c *Client) GetStats(ctx context.Context) (*ddan.Stats, error) {
+	if err := c.analyzer.call(c.uuid, "GetStats", true); err != nil {
+		return nil, err
+	}
+	c.analyzer.mx.Lock()
+	seconds := int(c.analyzer.analysisTime.Seconds())
+	c.analyzer.mx.Unlock()
+	stats := &ddan.Stats{}
+	for _, t := range []*ddan.AvgTime{&stats.AvgTotalProcessingTime, &stats.AvgVAAnalysisTime} {
+		t.Last4Hours = seconds
+		t.Last24Hours = seconds
+		t.Last7Days = seconds
+		t.Last30Days = seconds
+		t.Last90Days = seconds
+	}
+	return stats, nil
+}
+
+func (s *Sample) lines() []string {
+	lines := sandbox.ReportLines(s.Name, s.RiskLevel, s.Threat)
+	lines[0] = "Sandboxer DDAn Emulator Report"
+	return lines
+}
+
+// appendZero - add empty element. Used to fill ddan reports without
+// naming types of their elements
+func appendZero[T any](s []T) []T {
+	var zero T
+	return append(s, zero)
+}
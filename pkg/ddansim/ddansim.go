@@ -0,0 +1,271 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+ddansim.go
+
+Deep Discovery Analyzer emulator. Keeps appliance state (registered
+clients and submitted samples), serves DDAn web service endpoints and
+gives out in-process clients implementing ddan.ClientInterface, so DDAn
+code can be tested without real appliance
+*/
+package ddansim
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpkondrashin/ddan"
+
+	"sandboxer/pkg/sandbox"
+)
+
+var ErrNotFound = errors.New("sample not found")
+
+// statusAuto - sample status is calculated from time passed since upload
+const statusAuto ddan.SampleStatus = -1
+
+type Sample struct {
+	SHA1      string
+	Name      string
+	URL       bool
+	Uploaded  time.Time
+	RiskLevel sandbox.RiskLevel
+	Threat    string
+	Fail      sandbox.MockStage
+	status    ddan.SampleStatus
+}
+
+type Analyzer struct {
+	mx               sync.Mutex
+	analysisTime     time.Duration
+	defaultRiskLevel sandbox.RiskLevel
+	rules            []sandbox.MockRule
+	clients          map[string]bool
+	samples          map[string]*Sample
+	calls            map[string]int
+}
+
+func New() *Analyzer {
+	return &Analyzer{
+		analysisTime:     10 * time.Second,
+		defaultRiskLevel: sandbox.RiskLevelNoRisk,
+		clients:          make(map[string]bool),
+		samples:          make(map[string]*Sample),
+		calls:            make(map[string]int),
+	}
+}
+
+// SetAnalysisTime - time from upload to StatusDone. First half of it
+// sample is reported as StatusArrived and second half as StatusProcessing
+func (a *Analyzer) SetAnalysisTime(analysisTime time.Duration) *Analyzer {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.analysisTime = analysisTime
+	return a
+}
+
+// SetRules - verdicts for uploaded samples. First matching rule is used
+func (a *Analyzer) SetRules(defaultRiskLevel sandbox.RiskLevel, rules []sandbox.MockRule) *Analyzer {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.defaultRiskLevel = defaultRiskLevel
+	a.rules = rules
+	return a
+}
+
+// Client - connection of client with given UUID
+func (a *Analyzer) Client(uuid string) *Client {
+	return &Client{analyzer: a, uuid: uuid}
+}
+
+// Register - register client UUID as done by ddan.Client.Register
+func (a *Analyzer) Register(uuid string) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.clients[uuid] = true
+}
+
+func (a *Analyzer) Registered(uuid string) bool {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.clients[uuid]
+}
+
+// Calls - how many times given method of ddan.ClientInterface was called
+func (a *Analyzer) Calls(method string) int {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	return a.calls[method]
+}
+
+// AddSample - put sample on appliance as if it was uploaded by other client
+func (a *Analyzer) AddSample(sha1, name string, riskLevel sandbox.RiskLevel, threat string) *Sample {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	sample := &Sample{
+		SHA1:      strings.ToLower(sha1),
+		Name:      name,
+		Uploaded:  time.Now().Add(-a.analysisTime),
+		RiskLevel: riskLevel,
+		Threat:    threat,
+		status:    statusAuto,
+	}
+	a.samples[sample.SHA1] = sample
+	return sample
+}
+
+// SetStatus - make sample to be reported with given status
+// regardless of time passed since its upload
+func (a *Analyzer) SetStatus(sha1 string, status ddan.SampleStatus) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	sample, ok := a.samples[strings.ToLower(sha1)]
+	if !ok {
+		return fmt.Errorf("%s: %w", sha1, ErrNotFound)
+	}
+	sample.status = status
+	return nil
+}
+
+// Sample - copy of sample state
+func (a *Analyzer) Sample(sha1 string) (Sample, bool) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	sample, ok := a.samples[strings.ToLower(sha1)]
+	if !ok {
+		return Sample{}, false
+	}
+	return *sample, true
+}
+
+func (a *Analyzer) call(uuid, method string, registered bool) error {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	a.calls[method]++
+	if registered && !a.clients[uuid] {
+		return &ddan.APIError{Response: ddan.ResponseNotRegistered}
+	}
+	return nil
+}
+
+func (a *Analyzer) upload(name string, url bool, hashes []string, eicar bool) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	sample := &Sample{
+		SHA1:      hashes[1],
+		Name:      name,
+		URL:       url,
+		Uploaded:  time.Now(),
+		RiskLevel: a.defaultRiskLevel,
+		status:    statusAuto,
+	}
+	for _, rule := range a.rules {
+		if !rule.Match(name, hashes, eicar) {
+			continue
+		}
+		sample.RiskLevel = rule.RiskLevel
+		sample.Threat = rule.Threat
+		sample.Fail = rule.Fail
+		break
+	}
+	a.samples[sample.SHA1] = sample
+}
+
+// get - sample along with its current status
+func (a *Analyzer) get(sha1 string) (Sample, ddan.SampleStatus) {
+	a.mx.Lock()
+	defer a.mx.Unlock()
+	sample, ok := a.samples[strings.ToLower(sha1)]
+	if !ok {
+		return Sample{}, ddan.StatusNotFound
+	}
+	if sample.status != statusAuto {
+		return *sample, sample.status
+	}
+	passed := time.Since(sample.Uploaded)
+	switch {
+	case passed < a.analysisTime/2:
+		return *sample, ddan.StatusArrived
+	case passed < a.analysisTime:
+		return *sample, ddan.StatusProcessing
+	case sample.Fail == sandbox.MockStageResult:
+		return *sample, ddan.StatusError
+	}
+	return *sample, ddan.StatusDone
+}
+
+// done - sample that has finished analysis
+func (a *Analyzer) done(sha1 string) (Sample, error) {
+	sample, status := a.get(sha1)
+	if status == ddan.StatusNotFound {
+		return sample, fmt.Errorf("%s: %w", sha1, ErrNotFound)
+	}
+	if status != ddan.StatusDone {
+		return sample, fmt.Errorf("%s: %w: status %d", sha1, ErrNotFound, status)
+	}
+	return sample, nil
+}
+
+// Rating - DDAn rating for risk level
+func Rating(riskLevel sandbox.RiskLevel) ddan.Rating {
+	switch riskLevel {
+	case sandbox.RiskLevelNoRisk:
+		return ddan.RatingNoRiskFound
+	case sandbox.RiskLevelLow:
+		return ddan.RatingLowRisk
+	case sandbox.RiskLevelMedium:
+		return ddan.RatingMediumRisk
+	case sandbox.RiskLevelHigh:
+		return ddan.RatingHighRisk
+	}
+	return ddan.RatingUnsupported
+}
+
+type xmlValue struct {
+	Value string `xml:"value,attr"`
+}
+
+type xmlFileAnalyzeReport struct {
+	FileSHA1         xmlValue `xml:"FileSHA1"`
+	OrigFileName     xmlValue `xml:"OrigFileName"`
+	FileType         xmlValue `xml:"FileType"`
+	OverallROZRating xmlValue `xml:"OverallROZRating"`
+	VirusName        xmlValue `xml:"VirusName"`
+}
+
+type xmlReports struct {
+	XMLName           xml.Name               `xml:"REPORTS"`
+	FileAnalyzeReport []xmlFileAnalyzeReport `xml:"FILE_ANALYZE_REPORT"`
+}
+
+// ReportXML - full report of analyzed sample in the DDAn XML report layout
+func (a *Analyzer) ReportXML(sha1 string) ([]byte, error) {
+	sample, err := a.done(sha1)
+	if err != nil {
+		return nil, err
+	}
+	fileType := strings.TrimPrefix(filepath.Ext(sample.Name), ".")
+	if sample.URL {
+		fileType = "URL"
+	}
+	report := xmlReports{
+		FileAnalyzeReport: []xmlFileAnalyzeReport{{
+			FileSHA1:         xmlValue{strings.ToUpper(sample.SHA1)},
+			OrigFileName:     xmlValue{sample.Name},
+			FileType:         xmlValue{fileType},
+			OverallROZRating: xmlValue{fmt.Sprint(int(Rating(sample.RiskLevel)))},
+			VirusName:        xmlValue{sample.Threat},
+		}},
+	}
+	data, err := xml.MarshalIndent(&report, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
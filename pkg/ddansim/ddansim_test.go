@@ -0,0 +1,321 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+ddansim_test.go
+
+Run Deep Discovery Analyzer sandbox against emulator
+*/
+package ddansim
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpkondrashin/ddan"
+
+	"sandboxer/pkg/sandbox"
+)
+
+const testUUID = "11111111-2222-3333-4444-555555555555"
+
+func eicarFile(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "eicar.com")
+	if err := os.WriteFile(path, []byte(sandbox.EICAR), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func eicarRules() []sandbox.MockRule {
+	return []sandbox.MockRule{
+		{EICAR: true, RiskLevel: sandbox.RiskLevelHigh, Threat: "Eicar_test_file"},
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	sim := New().SetAnalysisTime(0).SetRules(sandbox.RiskLevelNoRisk, eicarRules())
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	path := eicarFile(t)
+	id, err := sb.SubmitFile(context.TODO(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sim.Registered(testUUID) {
+		t.Error("client is not registered")
+	}
+	if sim.Calls("Register") != 1 || sim.Calls("CheckDuplicateSample") != 2 || sim.Calls("UploadSampleEx") != 1 {
+		t.Errorf("wrong calls sequence: %v", sim.calls)
+	}
+	sample, ok := sim.Sample(id)
+	if !ok {
+		t.Fatalf("%s: not uploaded", id)
+	}
+	if sample.Name != "eicar.com" || sample.RiskLevel != sandbox.RiskLevelHigh {
+		t.Errorf("wrong sample: %v", sample)
+	}
+	t.Run("duplicate", func(t *testing.T) {
+		duplicateID, err := sb.SubmitFile(context.TODO(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if duplicateID != id {
+			t.Errorf("expected %s, got %s", id, duplicateID)
+		}
+		if sim.Calls("Register") != 1 || sim.Calls("UploadSampleEx") != 1 {
+			t.Errorf("duplicate is uploaded: %v", sim.calls)
+		}
+	})
+	t.Run("url", func(t *testing.T) {
+		url := "http://www.example.com"
+		urlID, err := sb.SubmitURL(context.TODO(), url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if urlID != sandbox.CalculateStringHash(url) {
+			t.Errorf("wrong ID %s", urlID)
+		}
+		if sim.Calls("UploadSampleURLs") != 1 {
+			t.Errorf("URL is not uploaded: %v", sim.calls)
+		}
+		riskLevel, _, err := sb.GetResult(context.TODO(), urlID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != sandbox.RiskLevelNoRisk {
+			t.Errorf("expected %v, got %v", sandbox.RiskLevelNoRisk, riskLevel)
+		}
+	})
+}
+
+func TestGetResultStatus(t *testing.T) {
+	sim := New()
+	sim.Register(testUUID)
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	id := sandbox.CalculateStringHash("sample")
+	sim.AddSample(id, "sample.exe", sandbox.RiskLevelLow, "TROJ_TEST")
+	testCases := []struct {
+		status    ddan.SampleStatus
+		riskLevel sandbox.RiskLevel
+		err       error
+	}{
+		{ddan.StatusNotFound, sandbox.RiskLevelUnknown, sandbox.ErrNotFound},
+		{ddan.StatusArrived, sandbox.RiskLevelNotReady, nil},
+		{ddan.StatusProcessing, sandbox.RiskLevelNotReady, nil},
+		{ddan.StatusDone, sandbox.RiskLevelLow, nil},
+		{ddan.StatusError, sandbox.RiskLevelUnknown, sandbox.ErrError},
+		{ddan.StatusTimeout, sandbox.RiskLevelError, sandbox.ErrError},
+		{ddan.SampleStatus(100), sandbox.RiskLevelError, sandbox.ErrError},
+	}
+	for _, tCase := range testCases {
+		if err := sim.SetStatus(id, tCase.status); err != nil {
+			t.Fatal(err)
+		}
+		riskLevel, _, err := sb.GetResult(context.TODO(), id)
+		if !errors.Is(err, tCase.err) {
+			t.Errorf("status %d: expected error %v, got %v", tCase.status, tCase.err, err)
+		}
+		if riskLevel != tCase.riskLevel {
+			t.Errorf("status %d: expected %v, got %v", tCase.status, tCase.riskLevel, riskLevel)
+		}
+	}
+	t.Run("unknown", func(t *testing.T) {
+		_, _, err := sb.GetResult(context.TODO(), sandbox.CalculateStringHash("unknown"))
+		if !errors.Is(err, sandbox.ErrNotFound) {
+			t.Errorf("expected %v, got %v", sandbox.ErrNotFound, err)
+		}
+	})
+}
+
+func TestGetResultRating(t *testing.T) {
+	sim := New()
+	sim.Register(testUUID)
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	for _, riskLevel := range []sandbox.RiskLevel{
+		sandbox.RiskLevelUnsupported,
+		sandbox.RiskLevelNoRisk,
+		sandbox.RiskLevelLow,
+		sandbox.RiskLevelMedium,
+		sandbox.RiskLevelHigh,
+	} {
+		id := sandbox.CalculateStringHash(riskLevel.String())
+		sim.AddSample(id, riskLevel.String(), riskLevel, "")
+		got, _, err := sb.GetResult(context.TODO(), id)
+		if err != nil {
+			t.Errorf("%v: %v", riskLevel, err)
+			continue
+		}
+		if got != riskLevel {
+			t.Errorf("expected %v, got %v", riskLevel, got)
+		}
+	}
+}
+
+func TestGetResults(t *testing.T) {
+	sim := New()
+	sim.Register(testUUID)
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	riskLevels := []sandbox.RiskLevel{
+		sandbox.RiskLevelNoRisk,
+		sandbox.RiskLevelLow,
+		sandbox.RiskLevelHigh,
+	}
+	var ids []string
+	for _, riskLevel := range riskLevels {
+		id := sandbox.CalculateStringHash(riskLevel.String())
+		sim.AddSample(id, riskLevel.String(), riskLevel, "")
+		ids = append(ids, id)
+	}
+	results, err := sb.GetResults(context.TODO(), ids, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, riskLevel := range riskLevels {
+		r := results[ids[i]]
+		if r.Err != nil || r.RiskLevel != riskLevel {
+			t.Errorf("expected %v, got %+v", riskLevel, r)
+		}
+	}
+}
+
+func TestAnalysisTime(t *testing.T) {
+	sim := New().SetAnalysisTime(2 * time.Second)
+	client := sim.Client(testUUID)
+	if err := client.Register(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	sb := sandbox.NewDDAnSandbox(client)
+	id, err := sb.SubmitFile(context.TODO(), eicarFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	riskLevel, _, err := sb.GetResult(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if riskLevel != sandbox.RiskLevelNotReady {
+		t.Errorf("expected %v, got %v", sandbox.RiskLevelNotReady, riskLevel)
+	}
+	if _, err := client.GetPDFReport(context.Background(), id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+	time.Sleep(2 * time.Second)
+	riskLevel, _, err = sb.GetResult(context.TODO(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if riskLevel != sandbox.RiskLevelNoRisk {
+		t.Errorf("expected %v, got %v", sandbox.RiskLevelNoRisk, riskLevel)
+	}
+}
+
+func TestReports(t *testing.T) {
+	sim := New().SetAnalysisTime(0).SetRules(sandbox.RiskLevelNoRisk, eicarRules())
+	sim.Register(testUUID)
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	id, err := sb.SubmitFile(context.TODO(), eicarFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder := t.TempDir()
+	t.Run("pdf", func(t *testing.T) {
+		path := filepath.Join(folder, "report.pdf")
+		if err := sb.GetReport(context.TODO(), id, path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("Eicar_test_file")) {
+			t.Errorf("wrong report: %s", data)
+		}
+	})
+	t.Run("investigation", func(t *testing.T) {
+		path := filepath.Join(folder, "investigation.zip")
+		if err := sb.GetInvestigation(context.TODO(), id, path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("PK")) {
+			t.Errorf("not a zip file")
+		}
+	})
+	t.Run("xml", func(t *testing.T) {
+		data, err := sim.ReportXML(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var report xmlReports
+		if err := xml.Unmarshal(data, &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.FileAnalyzeReport) != 1 {
+			t.Fatalf("wrong report: %s", data)
+		}
+		if report.FileAnalyzeReport[0].VirusName.Value != "Eicar_test_file" {
+			t.Errorf("wrong virus name: %s", data)
+		}
+	})
+}
+
+func TestNotRegistered(t *testing.T) {
+	sim := New()
+	client := sim.Client(testUUID)
+	_, err := client.GetBriefReport(context.Background(), []string{"sha1"})
+	var apiErr *ddan.APIError
+	if !errors.As(err, &apiErr) || apiErr.Response != ddan.ResponseNotRegistered {
+		t.Errorf("expected not registered error, got %v", err)
+	}
+	if err := client.Register(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Unregister(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if sim.Registered(testUUID) {
+		t.Error("client is still registered")
+	}
+	if err := client.Unregister(context.Background()); !errors.As(err, &apiErr) {
+		t.Errorf("expected not registered error, got %v", err)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	sim := New().SetAnalysisTime(0)
+	sb := sandbox.NewDDAnSandbox(sim.Client(testUUID))
+	path := eicarFile(t)
+	hashes, _, err := sandbox.Inspect(bytes.NewReader([]byte(sandbox.EICAR)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Now().Add(-time.Hour)
+	id, err := sb.Lookup(context.TODO(), hashes[1], hashes[2], since)
+	if err != nil || id != "" {
+		t.Fatalf("not registered client: %s, %v", id, err)
+	}
+	submittedID, err := sb.SubmitFile(context.TODO(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err = sb.Lookup(context.TODO(), hashes[1], hashes[2], since)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != submittedID {
+		t.Errorf("expected %s, got %s", submittedID, id)
+	}
+	if sim.Calls("UploadSampleEx") != 1 {
+		t.Errorf("wrong calls sequence: %v", sim.calls)
+	}
+}
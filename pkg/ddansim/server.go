@@ -0,0 +1,241 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+server.go
+
+Deep Discovery Analyzer web service emulator. Serves sample upload API
+endpoints, so real ddan.Client can be run against httptest.Server
+*/
+package ddansim
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mpkondrashin/ddan"
+)
+
+const (
+	webServicePrefix         = "/web_service/sample_upload/"
+	testConnectionPath       = webServicePrefix + "test_connection"
+	registerPath             = webServicePrefix + "register"
+	unregisterPath           = webServicePrefix + "unregister"
+	checkDuplicateSamplePath = webServicePrefix + "check_duplicate_sample"
+	uploadSamplePath         = webServicePrefix + "upload_sample"
+	getBriefReportPath       = webServicePrefix + "get_brief_report"
+	getReportPath            = webServicePrefix + "get_report"
+	getSandboxPath           = webServicePrefix + "get_sandbox"
+	headerProtocolVersion    = "X-DTAS-ProtocolVersion"
+	headerClientUUID         = "X-DTAS-ClientUUID"
+	headerChecksum           = "X-DTAS-Checksum"
+	headerSHA1               = "X-DTAS-SHA1"
+	headerSampleType         = "X-DTAS-SampleType"
+	headerReportType         = "X-DTAS-ReportType"
+	sampleTypeFile           = "0"
+	sampleTypeURL            = "1"
+	reportTypeXML            = "0"
+	reportTypePDF            = "1"
+	uploadSampleField        = "uploadsample"
+	sha1ListSeparator        = ";"
+	maxUploadSize            = 60 << 20
+)
+
+// ServeHTTP - handle DDAn web service request. Client UUID is taken from
+// X-DTAS-ClientUUID header. Checksum is required, but not verified
+func (a *Analyzer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get(headerProtocolVersion) == "" || r.Header.Get(headerChecksum) == "" {
+		http.Error(w, "missing "+headerProtocolVersion+" or "+headerChecksum, http.StatusBadRequest)
+		return
+	}
+	client := a.Client(r.Header.Get(headerClientUUID))
+	ctx := r.Context()
+	switch r.URL.Path {
+	case testConnectionPath:
+		a.Reply(w, client.TestConnection(ctx))
+	case registerPath:
+		a.Reply(w, client.Register(ctx))
+	case unregisterPath:
+		a.Reply(w, client.Unregister(ctx))
+	case checkDuplicateSamplePath:
+		a.CheckDuplicateSample(w, r, client)
+	case uploadSamplePath:
+		a.UploadSample(w, r, client)
+	case getBriefReportPath:
+		a.GetBriefReport(w, r, client)
+	case getReportPath:
+		a.GetReport(w, r, client)
+	case getSandboxPath:
+		data, err := client.GetPackage(ctx, r.Header.Get(headerSHA1))
+		a.Download(w, "application/zip", data, err)
+	default:
+		http.Error(w, "unknown endpoint "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+	}
+}
+
+// CheckDuplicateSample - return known SHA1 hashes out of semicolon
+// separated list in request body
+func (a *Analyzer) CheckDuplicateSample(w http.ResponseWriter, r *http.Request, client *Client) {
+	sha1List, err := readSHA1List(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := client.CheckDuplicateSample(r.Context(), sha1List, 0)
+	if err != nil {
+		a.Reply(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = io.WriteString(w, strings.Join(result, sha1ListSeparator))
+}
+
+// UploadSample - accept file or newline separated URLs depending on
+// X-DTAS-SampleType header. Content is sent as multipart form field
+func (a *Analyzer) UploadSample(w http.ResponseWriter, r *http.Request, client *Client) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	name, content, err := readUpload(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch r.Header.Get(headerSampleType) {
+	case sampleTypeURL:
+		a.Reply(w, client.UploadSampleURLs(r.Context(), strings.Fields(string(content))))
+	case sampleTypeFile, "":
+		path, err := saveSample(content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer os.Remove(path)
+		a.Reply(w, client.UploadSampleEx(r.Context(), path, name, r.Header.Get(headerSHA1)))
+	default:
+		http.Error(w, "unknown sample type "+r.Header.Get(headerSampleType), http.StatusBadRequest)
+	}
+}
+
+// GetBriefReport - status and rating of samples in semicolon separated list
+func (a *Analyzer) GetBriefReport(w http.ResponseWriter, r *http.Request, client *Client) {
+	sha1List, err := readSHA1List(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	reports, err := client.GetBriefReport(r.Context(), sha1List)
+	if err != nil {
+		a.Reply(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(reports)
+}
+
+// GetReport - full report of analyzed sample in XML or PDF format
+func (a *Analyzer) GetReport(w http.ResponseWriter, r *http.Request, client *Client) {
+	sha1 := r.Header.Get(headerSHA1)
+	switch r.Header.Get(headerReportType) {
+	case reportTypeXML, "":
+		if err := a.call(client.uuid, "GetReport", true); err != nil {
+			a.Reply(w, err)
+			return
+		}
+		data, err := a.ReportXML(sha1)
+		if err != nil {
+			a.Reply(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write(data)
+	case reportTypePDF:
+		data, err := client.GetPDFReport(r.Context(), sha1)
+		a.Download(w, "application/pdf", data, err)
+	default:
+		http.Error(w, "unknown report type "+r.Header.Get(headerReportType), http.StatusBadRequest)
+	}
+}
+
+// Download - write content of given type or error
+func (a *Analyzer) Download(w http.ResponseWriter, contentType string, data io.Reader, err error) {
+	if err != nil {
+		a.Reply(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = io.Copy(w, data)
+}
+
+// Reply - write empty response for successful call or status code of err
+func (a *Analyzer) Reply(w http.ResponseWriter, err error) {
+	var apiErr *ddan.APIError
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusOK)
+	case errors.As(err, &apiErr) && apiErr.Response == ddan.ResponseNotRegistered:
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// saveSample - write uploaded content to temporary file
+func saveSample(content []byte) (string, error) {
+	f, err := os.CreateTemp("", "ddansim-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// readSHA1List - semicolon separated SHA1 hashes of request body
+func readSHA1List(r *http.Request) ([]string, error) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var sha1List []string
+	for _, sha1 := range strings.Split(string(data), sha1ListSeparator) {
+		if sha1 = strings.TrimSpace(sha1); sha1 != "" {
+			sha1List = append(sha1List, sha1)
+		}
+	}
+	if len(sha1List) == 0 {
+		return nil, errors.New("empty SHA1 list")
+	}
+	return sha1List, nil
+}
+
+// readUpload - name and content of uploaded sample. Request that is not
+// multipart form is treated as raw content
+func readUpload(r *http.Request) (string, []byte, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		data, err := io.ReadAll(r.Body)
+		return "sample", data, err
+	}
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return "", nil, err
+		}
+		if part.FormName() != uploadSampleField {
+			continue
+		}
+		data, err := io.ReadAll(part)
+		return part.FileName(), data, err
+	}
+}
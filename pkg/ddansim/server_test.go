@@ -0,0 +1,200 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+server_test.go
+
+Run ddan.Client against emulated DDAn web service
+*/
+package ddansim
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpkondrashin/ddan"
+
+	"sandboxer/pkg/sandbox"
+)
+
+func startServer(t *testing.T, sim *Analyzer) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewTLSServer(sim)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestServer(t *testing.T) {
+	sim := New().SetAnalysisTime(0).SetRules(sandbox.RiskLevelNoRisk, eicarRules())
+	ts := startServer(t, sim)
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := ddan.NewClient("Sandboxer", "test").
+		SetAnalyzer(u, "key", true).
+		SetUUID(testUUID).
+		SetProtocolVersion("1.5")
+	sb := sandbox.NewDDAnSandbox(client)
+	id, err := sb.SubmitFile(context.TODO(), eicarFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sim.Registered(testUUID) {
+		t.Fatal("client is not registered")
+	}
+	if sim.Calls("Register") != 1 || sim.Calls("UploadSampleEx") != 1 {
+		t.Errorf("wrong calls sequence: %v", sim.calls)
+	}
+	t.Run("brief report", func(t *testing.T) {
+		riskLevel, _, err := sb.GetResult(context.TODO(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != sandbox.RiskLevelHigh {
+			t.Errorf("expected %v, got %v", sandbox.RiskLevelHigh, riskLevel)
+		}
+	})
+	t.Run("xml report", func(t *testing.T) {
+		report, err := client.GetReport(context.TODO(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.FILEANALYZEREPORT) != 1 || report.FILEANALYZEREPORT[0].VirusName.Value != "Eicar_test_file" {
+			t.Errorf("wrong report: %+v", report)
+		}
+	})
+	folder := t.TempDir()
+	t.Run("pdf", func(t *testing.T) {
+		path := filepath.Join(folder, "report.pdf")
+		if err := sb.GetReport(context.TODO(), id, path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("Eicar_test_file")) {
+			t.Errorf("wrong report: %s", data)
+		}
+	})
+	t.Run("investigation", func(t *testing.T) {
+		path := filepath.Join(folder, "investigation.zip")
+		if err := sb.GetInvestigation(context.TODO(), id, path); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("PK")) {
+			t.Errorf("not a zip file")
+		}
+	})
+	t.Run("unregister", func(t *testing.T) {
+		if err := client.Unregister(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+		if sim.Registered(testUUID) {
+			t.Error("client is still registered")
+		}
+	})
+}
+
+func TestServerRequests(t *testing.T) {
+	sim := New().SetAnalysisTime(0)
+	sha1 := sandbox.CalculateStringHash("sample")
+	sim.AddSample(sha1, "sample.exe", sandbox.RiskLevelLow, "TROJ_TEST")
+	ts := startServer(t, sim)
+	request := func(path string, header map[string]string, body string) (int, string) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, ts.URL+path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set(headerProtocolVersion, "1.5")
+		req.Header.Set(headerChecksum, "checksum")
+		req.Header.Set(headerClientUUID, testUUID)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, string(data)
+	}
+	testCases := []struct {
+		name   string
+		path   string
+		header map[string]string
+		body   string
+		status int
+	}{
+		{"no checksum", testConnectionPath, map[string]string{headerChecksum: ""}, "", http.StatusBadRequest},
+		{"unknown endpoint", webServicePrefix + "unknown", nil, "", http.StatusNotFound},
+		{"test connection", testConnectionPath, nil, "", http.StatusOK},
+		{"not registered", getBriefReportPath, nil, sha1, http.StatusUnauthorized},
+		{"register", registerPath, nil, "", http.StatusOK},
+		{"empty list", checkDuplicateSamplePath, nil, "", http.StatusBadRequest},
+		{"duplicate", checkDuplicateSamplePath, nil, sha1 + ";" + strings.Repeat("0", 40), http.StatusOK},
+		{"unknown sample", getReportPath, map[string]string{headerSHA1: strings.Repeat("0", 40)}, "", http.StatusNotFound},
+		{"wrong report type", getReportPath, map[string]string{headerSHA1: sha1, headerReportType: "9"}, "", http.StatusBadRequest},
+		{"unregister", unregisterPath, nil, "", http.StatusOK},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			status, body := request(tCase.path, tCase.header, tCase.body)
+			if status != tCase.status {
+				t.Errorf("expected %d, got %d: %s", tCase.status, status, body)
+			}
+		})
+	}
+	t.Run("duplicate list", func(t *testing.T) {
+		request(registerPath, nil, "")
+		_, body := request(checkDuplicateSamplePath, nil, sha1+";"+strings.Repeat("0", 40))
+		if body != sha1 {
+			t.Errorf("expected %s, got %s", sha1, body)
+		}
+	})
+	t.Run("xml report", func(t *testing.T) {
+		status, body := request(getReportPath, map[string]string{headerSHA1: sha1, headerReportType: reportTypeXML}, "")
+		if status != http.StatusOK {
+			t.Fatalf("status %d: %s", status, body)
+		}
+		var report xmlReports
+		if err := xml.Unmarshal([]byte(body), &report); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.FileAnalyzeReport) != 1 || report.FileAnalyzeReport[0].VirusName.Value != "TROJ_TEST" {
+			t.Errorf("wrong report: %s", body)
+		}
+	})
+	t.Run("upload", func(t *testing.T) {
+		hashes, _, err := sandbox.Inspect(strings.NewReader(sandbox.EICAR))
+		if err != nil {
+			t.Fatal(err)
+		}
+		header := map[string]string{headerSHA1: hashes[1], headerSampleType: sampleTypeFile, "Content-Type": "application/octet-stream"}
+		if status, body := request(uploadSamplePath, header, sandbox.EICAR); status != http.StatusOK {
+			t.Fatalf("status %d: %s", status, body)
+		}
+		if _, ok := sim.Sample(hashes[1]); !ok {
+			t.Errorf("%s is not uploaded", hashes[1])
+		}
+	})
+}
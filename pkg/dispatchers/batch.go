@@ -0,0 +1,149 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+batch.go
+
+Check results of many submissions in one request
+*/
+package dispatchers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+// batchSize - most submissions checked in one request
+const batchSize = 100
+
+type batch struct {
+	mx       sync.Mutex
+	results  map[string]sandbox.Result
+	fetched  time.Time
+	fetching chan struct{}
+}
+
+// Batcher - checks results of all tasks waiting for the same sandbox in
+// one request and keeps answers for other tasks. "Not ready" answers are
+// kept for sleep period, final ones till they are given out or till next
+// request. Request is made without holding the lock and tasks that need
+// result while it is in progress wait for its answers
+type Batcher struct {
+	mx      sync.Mutex
+	conf    *config.Configuration
+	list    *task.TaskList
+	now     func() time.Time
+	batches map[string]*batch
+}
+
+func NewBatcher(d BaseDispatcher) *Batcher {
+	return &Batcher{
+		conf:    d.conf,
+		list:    d.list,
+		now:     time.Now,
+		batches: make(map[string]*batch),
+	}
+}
+
+// GetResult - result of submission to sandbox with given name. Sandboxes
+// that do not support batches are asked for this submission only
+func (b *Batcher) GetResult(ctx context.Context, name string, sb sandbox.Sandbox, id string) (sandbox.RiskLevel, string, error) {
+	batchSandbox, ok := sb.(sandbox.BatchSandbox)
+	if !ok {
+		return sb.GetResult(ctx, id)
+	}
+	bt := b.batch(name)
+	bt.mx.Lock()
+	defer bt.mx.Unlock()
+	if r, ok := b.cached(bt, id); ok {
+		return r.RiskLevel, r.Threat, r.Err
+	}
+	for bt.fetching != nil {
+		done := bt.fetching
+		bt.mx.Unlock()
+		select {
+		case <-done:
+		case <-ctx.Done():
+		}
+		bt.mx.Lock()
+		if err := ctx.Err(); err != nil {
+			return sandbox.RiskLevelUnknown, "", err
+		}
+		if r, ok := b.cached(bt, id); ok {
+			return r.RiskLevel, r.Threat, r.Err
+		}
+	}
+	ids, since := b.pending(name, id)
+	logging.Debugf("%s: check %d submissions", name, len(ids))
+	done := make(chan struct{})
+	bt.fetching = done
+	bt.mx.Unlock()
+	results, err := batchSandbox.GetResults(ctx, ids, since)
+	bt.mx.Lock()
+	if bt.fetching == done {
+		bt.fetching = nil
+	}
+	close(done)
+	if err != nil {
+		return sandbox.RiskLevelUnknown, "", err
+	}
+	bt.results = results
+	bt.fetched = b.now()
+	r, ok := b.cached(bt, id)
+	if !ok {
+		return sandbox.RiskLevelUnknown, "", fmt.Errorf("%s: %w", id, sandbox.ErrNotFound)
+	}
+	return r.RiskLevel, r.Threat, r.Err
+}
+
+// cached - answer of last request for submission. Final answer is given
+// out once, so task that is checked again gets fresh one. Must be called
+// with bt.mx locked
+func (b *Batcher) cached(bt *batch, id string) (sandbox.Result, bool) {
+	r, ok := bt.results[id]
+	if !ok {
+		return r, false
+	}
+	if r.RiskLevel == sandbox.RiskLevelNotReady {
+		return r, b.now().Sub(bt.fetched) < b.conf.GetSleep()
+	}
+	delete(bt.results, id)
+	return r, true
+}
+
+func (b *Batcher) batch(name string) *batch {
+	b.mx.Lock()
+	defer b.mx.Unlock()
+	bt, ok := b.batches[name]
+	if !ok {
+		bt = &batch{}
+		b.batches[name] = bt
+	}
+	return bt
+}
+
+// pending - submissions to check along with given one and earliest
+// upload time among them. Unknown upload times are not taken into account
+func (b *Batcher) pending(name string, id string) ([]string, time.Time) {
+	awaiting := b.list.Awaiting(name)
+	since := awaiting[id]
+	delete(awaiting, id)
+	ids := []string{id}
+	for other, uploadTime := range awaiting {
+		if len(ids) == batchSize {
+			break
+		}
+		ids = append(ids, other)
+		if !uploadTime.IsZero() && (since.IsZero() || uploadTime.Before(since)) {
+			since = uploadTime
+		}
+	}
+	return ids, since
+}
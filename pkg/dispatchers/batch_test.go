@@ -0,0 +1,207 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+batch_test.go
+
+Test batch result checks
+*/
+package dispatchers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type batchSandbox struct {
+	sandbox.Sandbox
+	results  map[string]sandbox.Result
+	requests int
+	checked  int
+}
+
+func (s *batchSandbox) GetResults(ctx context.Context, ids []string, since time.Time) (map[string]sandbox.Result, error) {
+	s.requests++
+	s.checked += len(ids)
+	results := make(map[string]sandbox.Result)
+	for _, id := range ids {
+		results[id] = s.results[id]
+	}
+	return results, nil
+}
+
+func TestBatcher(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	conf := config.New("")
+	conf.SetSleep(time.Minute)
+	list := task.NewList()
+	sb := &batchSandbox{results: make(map[string]sandbox.Result)}
+	riskLevels := []sandbox.RiskLevel{
+		sandbox.RiskLevelNoRisk,
+		sandbox.RiskLevelNotReady,
+		sandbox.RiskLevelHigh,
+	}
+	var ids []string
+	for i, riskLevel := range riskLevels {
+		id, err := list.NewTask(task.FileTask, fmt.Sprintf("file%d.exe", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		tsk.Channel = task.ChResult
+		tsk.SandboxID = fmt.Sprintf("id%d", i)
+		sb.results[tsk.SandboxID] = sandbox.Result{RiskLevel: riskLevel}
+		ids = append(ids, tsk.SandboxID)
+	}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := &Batcher{
+		conf:    conf,
+		list:    list,
+		now:     func() time.Time { return now },
+		batches: make(map[string]*batch),
+	}
+	for i, id := range ids {
+		riskLevel, _, err := b.GetResult(context.TODO(), "", sb, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != riskLevels[i] {
+			t.Errorf("%s: expected %v, got %v", id, riskLevels[i], riskLevel)
+		}
+	}
+	if sb.requests != 1 || sb.checked != len(ids) {
+		t.Errorf("expected 1 request for %d submissions, got %d for %d", len(ids), sb.requests, sb.checked)
+	}
+	t.Run("consumed", func(t *testing.T) {
+		sb.results[ids[0]] = sandbox.Result{RiskLevel: sandbox.RiskLevelHigh}
+		riskLevel, _, err := b.GetResult(context.TODO(), "", sb, ids[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sb.requests != 2 {
+			t.Errorf("given out final result is not requested again")
+		}
+		if riskLevel != sandbox.RiskLevelHigh {
+			t.Errorf("expected %v, got %v", sandbox.RiskLevelHigh, riskLevel)
+		}
+	})
+	t.Run("not ready", func(t *testing.T) {
+		if _, _, err := b.GetResult(context.TODO(), "", sb, ids[1]); err != nil {
+			t.Fatal(err)
+		}
+		if sb.requests != 2 {
+			t.Errorf("not ready result is requested again within sleep period")
+		}
+		now = now.Add(2 * time.Minute)
+		if _, _, err := b.GetResult(context.TODO(), "", sb, ids[1]); err != nil {
+			t.Fatal(err)
+		}
+		if sb.requests != 3 {
+			t.Errorf("outdated result is not requested again")
+		}
+	})
+}
+
+func TestBatcherPending(t *testing.T) {
+	list := task.NewList()
+	uploaded := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	uploadTimes := []time.Time{
+		{},
+		uploaded.Add(time.Hour),
+		uploaded,
+		{},
+	}
+	for i, uploadTime := range uploadTimes {
+		id, err := list.NewTask(task.FileTask, fmt.Sprintf("file%d.exe", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		tsk.Channel = task.ChResult
+		tsk.SandboxID = fmt.Sprintf("id%d", i)
+		tsk.UploadTime = uploadTime
+	}
+	b := &Batcher{list: list, batches: make(map[string]*batch)}
+	for _, id := range []string{"id0", "id1"} {
+		ids, since := b.pending("", id)
+		if len(ids) != len(uploadTimes) || ids[0] != id {
+			t.Errorf("%s: wrong submissions to check: %v", id, ids)
+		}
+		if !since.Equal(uploaded) {
+			t.Errorf("%s: expected since %v, got %v", id, uploaded, since)
+		}
+	}
+}
+
+type slowBatchSandbox struct {
+	batchSandbox
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *slowBatchSandbox) GetResults(ctx context.Context, ids []string, since time.Time) (map[string]sandbox.Result, error) {
+	s.started <- struct{}{}
+	<-s.release
+	return s.batchSandbox.GetResults(ctx, ids, since)
+}
+
+func TestBatcherConcurrent(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	conf := config.New("")
+	conf.SetSleep(time.Minute)
+	list := task.NewList()
+	sb := &slowBatchSandbox{
+		batchSandbox: batchSandbox{results: make(map[string]sandbox.Result)},
+		started:      make(chan struct{}, 1),
+		release:      make(chan struct{}),
+	}
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := list.NewTask(task.FileTask, fmt.Sprintf("file%d.exe", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		tsk.Channel = task.ChResult
+		tsk.SandboxID = fmt.Sprintf("id%d", i)
+		sb.results[tsk.SandboxID] = sandbox.Result{RiskLevel: sandbox.RiskLevelLow}
+		ids = append(ids, tsk.SandboxID)
+	}
+	b := NewBatcher(NewBaseDispatcher(conf, task.NewChannels(), list))
+	errs := make(chan error, len(ids))
+	check := func(id string) {
+		riskLevel, _, err := b.GetResult(context.TODO(), "", sb, id)
+		if err == nil && riskLevel != sandbox.RiskLevelLow {
+			err = fmt.Errorf("%s: expected %v, got %v", id, sandbox.RiskLevelLow, riskLevel)
+		}
+		errs <- err
+	}
+	go check(ids[0])
+	<-sb.started
+	for _, id := range ids[1:] {
+		go check(id)
+	}
+	bt := b.batch("")
+	bt.mx.Lock()
+	if bt.fetching == nil {
+		t.Error("request is not in progress")
+	}
+	bt.mx.Unlock()
+	close(sb.release)
+	for range ids {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if sb.requests != 1 {
+		t.Errorf("expected 1 request, got %d", sb.requests)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+consensus.go
+
+Final verdict for task submitted to several sandboxes
+*/
+package dispatchers
+
+import (
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+// Consensus - choose verdict that decides task result. Only sandboxes that
+// have analyzed object are taken into account. If there are none,
+// "not analyzed" verdict is preferred over error
+func Consensus(policy config.Consensus, verdicts []task.Verdict) task.Verdict {
+	var conclusive []task.Verdict
+	for _, v := range verdicts {
+		if v.Conclusive() {
+			conclusive = append(conclusive, v)
+		}
+	}
+	if len(conclusive) == 0 {
+		for _, v := range verdicts {
+			if v.RiskLevel == sandbox.RiskLevelUnsupported {
+				return v
+			}
+		}
+		return verdicts[0]
+	}
+	switch policy {
+	case config.ConsensusPrimary:
+		return conclusive[0]
+	case config.ConsensusMajority:
+		count := make(map[sandbox.RiskLevel]int)
+		for _, v := range conclusive {
+			count[v.RiskLevel]++
+		}
+		result := conclusive[0]
+		for _, v := range conclusive[1:] {
+			if count[v.RiskLevel] > count[result.RiskLevel] ||
+				count[v.RiskLevel] == count[result.RiskLevel] && v.RiskLevel > result.RiskLevel {
+				result = v
+			}
+		}
+		return result
+	}
+	result := conclusive[0]
+	for _, v := range conclusive[1:] {
+		if v.RiskLevel > result.RiskLevel {
+			result = v
+		}
+	}
+	return result
+}
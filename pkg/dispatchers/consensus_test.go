@@ -0,0 +1,47 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+consensus_test.go
+
+Check consensus policies
+*/
+package dispatchers
+
+import (
+	"testing"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func TestConsensus(t *testing.T) {
+	verdicts := func(riskLevels ...sandbox.RiskLevel) []task.Verdict {
+		var result []task.Verdict
+		for i, r := range riskLevels {
+			result = append(result, task.Verdict{Sandbox: string(rune('a' + i)), RiskLevel: r})
+		}
+		return result
+	}
+	testCases := []struct {
+		policy   config.Consensus
+		verdicts []task.Verdict
+		expected string
+	}{
+		{config.ConsensusWorst, verdicts(sandbox.RiskLevelNoRisk, sandbox.RiskLevelMedium, sandbox.RiskLevelLow), "b"},
+		{config.ConsensusWorst, verdicts(sandbox.RiskLevelError, sandbox.RiskLevelNoRisk), "b"},
+		{config.ConsensusMajority, verdicts(sandbox.RiskLevelHigh, sandbox.RiskLevelNoRisk, sandbox.RiskLevelNoRisk), "b"},
+		{config.ConsensusMajority, verdicts(sandbox.RiskLevelNoRisk, sandbox.RiskLevelLow), "b"},
+		{config.ConsensusPrimary, verdicts(sandbox.RiskLevelNoRisk, sandbox.RiskLevelHigh), "a"},
+		{config.ConsensusPrimary, verdicts(sandbox.RiskLevelError, sandbox.RiskLevelUnsupported, sandbox.RiskLevelHigh), "c"},
+		{config.ConsensusPrimary, verdicts(sandbox.RiskLevelError, sandbox.RiskLevelUnsupported), "b"},
+		{config.ConsensusWorst, verdicts(sandbox.RiskLevelError, sandbox.RiskLevelError), "a"},
+	}
+	for i, tCase := range testCases {
+		actual := Consensus(tCase.policy, tCase.verdicts)
+		if actual.Sandbox != tCase.expected {
+			t.Errorf("case %d: %v: expected %s, but got %s", i, tCase.policy, tCase.expected, actual.Sandbox)
+		}
+	}
+}
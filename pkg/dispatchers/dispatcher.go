@@ -10,6 +10,7 @@ package dispatchers
 
 import (
 	"fmt"
+
 	"sandboxer/pkg/config"
 	"sandboxer/pkg/sandbox"
 	"sandboxer/pkg/task"
@@ -30,32 +31,63 @@ func NewBaseDispatcher(conf *config.Configuration, channels *task.Channels, list
 	return BaseDispatcher{conf, channels, list}
 }
 
-func (d *BaseDispatcher) Channel(ch task.Channel) task.IDChannel {
-	return d.channels.TaskChannel[ch]
+// Push - queue task for dispatchers of given channel
+func (d *BaseDispatcher) Push(ch task.Channel, id task.ID, priority task.Priority) {
+	d.channels.Push(ch, id, priority)
+}
+
+// Sandbox - sandbox task is routed to. Tasks created before routing
+// was introduced have no sandbox name and go to the first sandbox
+func (d *BaseDispatcher) Sandbox(tsk *task.Task) (sandbox.Sandbox, error) {
+	return d.SandboxByName(tsk.Sandbox)
 }
 
-func (d *BaseDispatcher) Sandbox() (sandbox.Sandbox, error) {
-	switch d.conf.SandboxType {
+// SandboxByName - configured sandbox. Empty name means first sandbox
+func (d *BaseDispatcher) SandboxByName(name string) (sandbox.Sandbox, error) {
+	sb, err := d.conf.FindSandbox(name)
+	if err != nil {
+		return nil, err
+	}
+	switch sb.Type {
 	case config.SandboxVisionOne:
-		return d.VisionOneSandbox()
+		return VisionOneSandbox(sb.VisionOne)
 	case config.SandboxAnalyzer:
-		return d.AnalyzerSandbox()
+		return AnalyzerSandbox(sb.DDAn)
+	case config.SandboxMock:
+		return MockSandbox(sb.Mock)
 	}
-	return nil, fmt.Errorf("uknown Sandbox Type: %d", d.conf.SandboxType)
+	return nil, fmt.Errorf("%s: uknown Sandbox Type: %d", sb.Name, sb.Type)
 }
 
-func (d *BaseDispatcher) VisionOneSandbox() (sandbox.Sandbox, error) {
-	vOne, err := d.conf.VisionOne.VisionOneSandbox()
+func VisionOneSandbox(conf *config.VisionOne) (sandbox.Sandbox, error) {
+	vOne, err := conf.VisionOneSandbox()
 	if err != nil {
 		return nil, err
 	}
 	return sandbox.NewVOneSandbox(vOne), nil
 }
 
-func (d *BaseDispatcher) AnalyzerSandbox() (sandbox.Sandbox, error) {
-	analyzer, err := d.conf.DDAn.AnalyzerWithUUID()
+func AnalyzerSandbox(conf *config.DDAn) (sandbox.Sandbox, error) {
+	analyzer, err := conf.AnalyzerWithUUID()
 	if err != nil {
 		return nil, err
 	}
 	return sandbox.NewDDAnSandbox(analyzer), nil
 }
+
+func MockSandbox(mock *config.Mock) (sandbox.Sandbox, error) {
+	defaultRiskLevel, err := sandbox.ParseRiskLevel(mock.GetDefaultVerdict())
+	if err != nil {
+		return nil, fmt.Errorf("mock default verdict: %w", err)
+	}
+	var rules []sandbox.MockRule
+	for i, r := range mock.GetRules() {
+		rule, err := sandbox.ParseMockRule(r.Hash, r.Pattern, r.EICAR, r.Verdict, r.Threat, r.Fail)
+		if err != nil {
+			return nil, fmt.Errorf("mock rule #%d: %w", i+1, err)
+		}
+		rule.Transient = r.Transient
+		rules = append(rules, rule)
+	}
+	return sandbox.NewMockSandbox(defaultRiskLevel, mock.GetLatency(), mock.GetAnalysisTime(), rules), nil
+}
@@ -27,7 +27,7 @@ func (d *InvestigationDispatch) InboundChannel() task.Channel {
 }
 
 func (d *InvestigationDispatch) ProcessTask(tsk *task.Task) error {
-	sbox, err := d.Sandbox()
+	sbox, err := d.Sandbox(tsk)
 	if err != nil {
 		return err
 	}
@@ -35,7 +35,7 @@ func (d *InvestigationDispatch) ProcessTask(tsk *task.Task) error {
 	if err != nil {
 		return err
 	}
-	if err := sbox.GetInvestigation(tsk.SandboxID, zipFilePath); err != nil {
+	if err := sbox.GetInvestigation(tsk.Context(), tsk.SandboxID, zipFilePath); err != nil {
 		return err
 	}
 	tsk.SetInvestigation(zipFilePath)
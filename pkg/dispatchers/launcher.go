@@ -9,26 +9,33 @@ Run all dispatchers
 package dispatchers
 
 import (
+	"errors"
+	"fmt"
+	"sandboxer/pkg/api"
 	"sandboxer/pkg/config"
-	"sandboxer/pkg/fifo"
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ipc"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/task"
 	"sync"
-)
-
-const (
-	PrefilterDispatchers = 5
-	UploadDispatchers    = 5
-	//	WaitDispatchers         = 5
-	ResultDispatchers       = 5
-	ReportDispatcher        = 5
-	InvestigationDispatcher = 5
+	"time"
 )
 
 type Launcher struct {
 	conf     *config.Configuration
 	channels *task.Channels
 	list     *task.TaskList
+	api      *api.Server
+	ipc      *ipc.Server
+	watcher  *Watcher
+	stop     chan struct{}
+	shutdown chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+	pmx      sync.Mutex
+	pools    []*pool
+	tmx      sync.Mutex
+	timers   map[task.ID]*time.Timer
 }
 
 func NewLauncher(conf *config.Configuration, channels *task.Channels, list *task.TaskList) *Launcher {
@@ -36,38 +43,124 @@ func NewLauncher(conf *config.Configuration, channels *task.Channels, list *task
 		conf:     conf,
 		channels: channels,
 		list:     list,
+		stop:     make(chan struct{}),
+		shutdown: make(chan struct{}),
+		timers:   make(map[task.ID]*time.Timer),
 	}
 }
 
-func (l *Launcher) Run() {
+// Run - start dispatchers, load saved tasks and start accepting submissions.
+// ipc.ErrAlreadyRunning is returned, and nothing is started, if other
+// instance of application listens to IPC socket already
+func (l *Launcher) Run() error {
 	base := NewBaseDispatcher(l.conf, l.channels, l.list)
-	dispatchers := []struct {
-		count      int
-		dispatcher Dispatcher
-	}{
-		{InvestigationDispatcher, NewInvestigationDispatch(base)},
-		{ReportDispatcher, NewReportDispatch(base)},
-		{ResultDispatchers, NewResultDispatch(base)},
-		//{WaitDispatchers, NewWaitDispatch(base)},
-		{UploadDispatchers, NewUploadDispatch(base)},
-		{PrefilterDispatchers, NewPrefilterDispatch(base)},
-	}
-	var wg sync.WaitGroup
-	for _, d := range dispatchers {
-		for i := 0; i < d.count; i++ {
-			wg.Add(1)
-			go l.RunDispatcher(d.dispatcher, &wg)
+	if err := l.StartIPC(NewSubmitDispatch(base, l.Shutdown)); err != nil {
+		if errors.Is(err, ipc.ErrAlreadyRunning) {
+			return err
 		}
+		logging.Errorf("Start IPC: %v", err)
+	}
+	quota := NewQuotaManager(base)
+	go quota.Run(l.stop)
+	poller := NewPoller(base)
+	batcher := NewBatcher(base)
+	dispatchers := []Dispatcher{
+		NewMembersDispatch(base),
+		NewInvestigationDispatch(base),
+		NewReportDispatch(base),
+		NewResultDispatch(base, poller, batcher),
+		NewUploadDispatch(base, quota),
+		NewQuotaDispatch(base, quota),
+		NewLookupDispatch(base),
+		NewPrefilterDispatch(base),
+	}
+	l.pmx.Lock()
+	for _, d := range dispatchers {
+		l.pools = append(l.pools, &pool{dispatcher: d})
 	}
-	submit := NewSubmitDispatch(base)
-	wg.Add(1)
-	go submit.Run(&wg)
+	l.pmx.Unlock()
+	l.Scale()
 	l.LoadTasks()
-	//wg.Wait()
+	l.ServeIPC()
+	l.StartAPI()
+	l.StartWatcher()
+	return nil
+}
+
+// StartIPC - create IPC socket. Requests are not served till ServeIPC is called
+func (l *Launcher) StartIPC(handler ipc.Handler) error {
+	path, err := globals.SocketPath()
+	if err != nil {
+		return err
+	}
+	server := ipc.NewServer(path, handler)
+	if err := server.Listen(); err != nil {
+		return err
+	}
+	l.ipc = server
+	return nil
+}
+
+// ServeIPC - serve requests to IPC socket, if it was created
+func (l *Launcher) ServeIPC() {
+	if l.ipc == nil {
+		return
+	}
+	go func() {
+		logging.LogError(l.ipc.Serve())
+	}()
+}
+
+// Shutdown - request to stop application. Can be called several times
+func (l *Launcher) Shutdown() {
+	l.once.Do(func() {
+		close(l.shutdown)
+	})
+}
+
+// ShutdownRequested - closed when shutdown is requested through IPC
+func (l *Launcher) ShutdownRequested() <-chan struct{} {
+	return l.shutdown
+}
+
+func (l *Launcher) StartAPI() {
+	if !l.conf.GetAPIEnabled() {
+		return
+	}
+	path, err := globals.APITokenFilePath()
+	if err != nil {
+		logging.Errorf("Start API: %v", err)
+		return
+	}
+	token, err := api.LoadToken(path)
+	if err != nil {
+		logging.Errorf("Start API: %v", err)
+		return
+	}
+	server := api.NewServer(l.conf.GetAPIAddress(), token, l.list, l.channels).
+		SetMetrics(func() any { return l.Metrics() })
+	if err := server.Start(); err != nil {
+		logging.Errorf("Start API: %v", err)
+		return
+	}
+	l.api = server
+}
+
+func (l *Launcher) StartWatcher() {
+	watcher := NewWatcher(l.conf, l.channels, l.list)
+	if err := watcher.Start(); err != nil {
+		logging.Errorf("Start watcher: %v", err)
+		return
+	}
+	l.watcher = watcher
 }
 
 func (l *Launcher) LoadTasks() {
 	logging.Debugf("LoadTasks")
+	if err := l.list.Open(); err != nil {
+		logging.LogError(err)
+		return
+	}
 	err := l.list.LoadTasks(l.conf.GetTasksKeepDays())
 	if err != nil {
 		logging.LogError(err)
@@ -78,10 +171,10 @@ func (l *Launcher) LoadTasks() {
 		for _, id := range ids {
 			err := l.list.Task(id, func(tsk *task.Task) error {
 				logging.Debugf("Process task: %v", tsk)
-				if tsk.Channel == task.ChDone {
+				if channel, _ := tsk.Status(); !channel.Queued() {
 					return nil
 				}
-				l.channels.TaskChannel[tsk.Channel] <- tsk.Number
+				l.Push(tsk)
 				return nil
 			})
 			logging.LogError(err)
@@ -90,46 +183,192 @@ func (l *Launcher) LoadTasks() {
 
 }
 
-func (l *Launcher) RunDispatcher(disp Dispatcher, wg *sync.WaitGroup) {
+// RunDispatcher - process tasks of the pool stage till quit is closed
+func (l *Launcher) RunDispatcher(p *pool, quit <-chan struct{}) {
+	defer l.wg.Done()
+	disp := p.dispatcher
 	logging.Debugf("Start %T", disp)
 	ch := disp.InboundChannel()
-	for id := range l.channels.TaskChannel[ch] {
-		_ = l.list.Task(id, func(tsk *task.Task) error { // Simple Get(id) could be used
-			logging.Debugf("Got from %v task %v", ch, tsk)
-			tsk.Activate()
-			//logging.Debugf("Activate")
-			l.list.Updated()
-			err := disp.ProcessTask(tsk)
-			//logging.Debugf("Deactivate")
-			tsk.Deactivate()
+	for {
+		id, ok := l.channels.Pop(ch, quit)
+		if !ok {
+			logging.Debugf("Stop %T", disp)
+			return
+		}
+		p.busy.Add(1)
+		l.ProcessTask(disp, id)
+		p.busy.Add(-1)
+	}
+}
+
+func (l *Launcher) ProcessTask(disp Dispatcher, id task.ID) {
+	_ = l.list.Task(id, func(tsk *task.Task) error { // Simple Get(id) could be used
+		logging.Debugf("Got from %v task %v", disp.InboundChannel(), tsk)
+		if channel, _ := tsk.Status(); channel != disp.InboundChannel() {
+			logging.Debugf("Skip task #%d: %v", id, channel)
+			return nil
+		}
+		tsk.Activate()
+		l.list.Updated()
+		timeout := l.Timeout(disp.InboundChannel())
+		tsk.StartStage(timeout)
+		err := disp.ProcessTask(tsk)
+		delay := tsk.TakePostpone()
+		if tsk.EndStage() && err != nil {
+			err = fmt.Errorf("%v: %w after %v: %w", disp.InboundChannel(), task.ErrTimeout, timeout, err)
+		}
+		tsk.Deactivate()
+		l.list.Updated()
+		if l.list.Get(id) != tsk {
+			logging.Debugf("Task #%d is deleted or merged", id)
+			return nil
+		}
+		if tsk.Interrupted() {
+			channel, _ := tsk.Status()
+			logging.Infof("Task #%d: %v", id, channel)
 			l.list.Updated()
-			if err != nil {
-				tsk.SetError(err)
-				//l.list.Updated()
-				logging.Errorf("Task #%d: %v (%T)", id, err, disp)
-				return nil
-			}
-			if tsk.Channel == task.ChDone {
+			return nil
+		}
+		if err != nil {
+			if l.Retry(tsk, disp.InboundChannel(), err) {
+				l.list.Updated()
 				return nil
 			}
-			l.channels.TaskChannel[tsk.Channel] <- tsk.Number
+			tsk.SetError(err)
+			logging.Errorf("Task #%d: %v (%T)", id, err, disp)
+			return nil
+		}
+		tsk.ResetRetries()
+		if channel, _ := tsk.Status(); !channel.Queued() {
 			return nil
-		})
+		}
+		if delay > 0 {
+			l.Schedule(tsk, delay)
+			return nil
+		}
+		l.Push(tsk)
+		return nil
+	})
+}
+
+// Schedule - push task to its channel after delay without occupying
+// dispatcher. Task that is paused, cancelled, resumed or deleted meanwhile
+// is not pushed. Pending timers are stopped by Stop
+func (l *Launcher) Schedule(tsk *task.Task, delay time.Duration) {
+	generation := tsk.Generation()
+	l.tmx.Lock()
+	defer l.tmx.Unlock()
+	select {
+	case <-l.stop:
+		logging.LogError(tsk.Save())
+		return
+	default:
+	}
+	if timer, ok := l.timers[tsk.Number]; ok {
+		timer.Stop()
 	}
-	wg.Done()
+	var timer *time.Timer
+	timer = time.AfterFunc(delay, func() {
+		l.tmx.Lock()
+		if l.timers[tsk.Number] != timer {
+			l.tmx.Unlock()
+			return
+		}
+		delete(l.timers, tsk.Number)
+		l.tmx.Unlock()
+		if tsk.Generation() != generation || l.list.Get(tsk.Number) != tsk {
+			return
+		}
+		l.Push(tsk)
+	})
+	l.timers[tsk.Number] = timer
 }
 
+// stopTimers - stop pending timers of Schedule. Their tasks keep their
+// channels and are saved
+func (l *Launcher) stopTimers() int {
+	l.tmx.Lock()
+	defer l.tmx.Unlock()
+	count := 0
+	for id, timer := range l.timers {
+		delete(l.timers, id)
+		if !timer.Stop() {
+			continue
+		}
+		if tsk := l.list.Get(id); tsk != nil {
+			logging.LogError(tsk.Save())
+			count++
+		}
+	}
+	return count
+}
+
+// Timeout - deadline for one task on given processing stage. Zero means no deadline
+func (l *Launcher) Timeout(ch task.Channel) time.Duration {
+	timeouts := l.conf.Timeouts
+	switch ch {
+	case task.ChSubmit:
+		return timeouts.GetUpload()
+	case task.ChResult:
+		return timeouts.GetResult()
+	case task.ChReport:
+		return timeouts.GetReport()
+	case task.ChInvestigation:
+		return timeouts.GetInvestigation()
+	}
+	return 0
+}
+
+// Push - queue task to its channel with its priority unless launcher is
+// stopping. Task that was not pushed keeps its channel and is saved
+func (l *Launcher) Push(tsk *task.Task) {
+	select {
+	case <-l.stop:
+		logging.LogError(tsk.Save())
+	default:
+		channel, _ := tsk.Status()
+		l.channels.Push(channel, tsk.Number, tsk.Priority)
+	}
+}
+
+// Stop - stop accepting new submissions, wait for all dispatchers to finish
+// tasks they are busy with and drain channels saving queued tasks, so
+// LoadTasks will continue them on the next start
 func (l *Launcher) Stop() error {
-	l.channels.Close() // Should we move it to the end?
-	fifoWriter, err := fifo.NewWriter()
-	if err != nil {
-		return err
+	logging.Infof("Stop dispatchers")
+	if l.watcher != nil {
+		logging.LogError(l.watcher.Stop())
 	}
-	defer func() {
-		logging.LogError(fifoWriter.Close())
-	}()
-	if err = fifoWriter.Write(StopPath); err != nil {
-		return err
+	if l.api != nil {
+		logging.LogError(l.api.Stop())
 	}
-	return nil
+	var err error
+	if l.ipc != nil {
+		err = l.ipc.Close()
+		logging.LogError(err)
+	}
+	close(l.stop)
+	l.stopWorkers()
+	l.wg.Wait()
+	count := l.stopTimers()
+	for ch := task.ChPrefilter; ch < task.ChDone; ch++ {
+		for {
+			id, ok := l.channels.TaskChannel[ch].TryPop()
+			if !ok {
+				break
+			}
+			l.SaveTask(id)
+			count++
+		}
+	}
+	logging.Infof("Stopped. Queued tasks saved: %d", count)
+	logging.LogError(l.list.Close())
+	return err
+}
+
+func (l *Launcher) SaveTask(id task.ID) {
+	err := l.list.Task(id, func(tsk *task.Task) error {
+		return tsk.Save()
+	})
+	logging.LogError(err)
 }
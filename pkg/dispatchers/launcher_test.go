@@ -0,0 +1,744 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+launcher_test.go
+
+Run whole dispatchers pipeline against mock sandbox
+*/
+package dispatchers
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/ignore"
+	"sandboxer/pkg/ipc"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func testConfig(t *testing.T) (*config.Configuration, string) {
+	t.Helper()
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	t.Setenv("HOME", folder)
+	t.Setenv("XDG_CONFIG_HOME", folder)
+	t.Setenv("APPDATA", folder)
+	conf := config.New(filepath.Join(folder, "sandboxer.yaml"))
+	conf.SandboxType = config.SandboxMock
+	conf.SetSleep(100 * time.Millisecond)
+	conf.SetShowNotifications(false)
+	conf.Mock.SetLatency(0)
+	conf.Mock.SetAnalysisTime(time.Second)
+	return conf, folder
+}
+
+// waitDone - wait for all tasks to reach done channel
+func waitDone(t *testing.T, list *task.TaskList) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for _, id := range list.GetIDs() {
+		for {
+			tsk := list.Get(id)
+			if tsk == nil {
+				break
+			}
+			channel, _ := tsk.Status()
+			if channel == task.ChDone {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("%s: timeout in %v", tsk.Path, channel)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+// runSamples - run launcher, submit samples and wait for them to be done.
+// Samples are URLs or names of files created in the test folder with given
+// content. Tasks are returned by sample
+func runSamples(t *testing.T, conf *config.Configuration, samples map[string]string) map[string]*task.Task {
+	t.Helper()
+	folder := filepath.Dir(conf.GetFilePath())
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	t.Cleanup(func() {
+		logging.LogError(launcher.Stop())
+	})
+	ids := make(map[string]task.ID)
+	for name, content := range samples {
+		taskType := task.URLTask
+		path := name
+		if !strings.HasPrefix(name, "http") {
+			taskType = task.FileTask
+			path = filepath.Join(folder, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		id, err := list.NewTask(taskType, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+		ids[name] = id
+	}
+	waitDone(t, list)
+	tasks := make(map[string]*task.Task)
+	for name, id := range ids {
+		tasks[name] = list.Get(id)
+	}
+	return tasks
+}
+
+func TestLauncherMock(t *testing.T) {
+	conf, _ := testConfig(t)
+	samples := map[string]sandbox.RiskLevel{
+		"eicar.com": sandbox.RiskLevelHigh,
+		"clean.exe": sandbox.RiskLevelNoRisk,
+	}
+	tasks := runSamples(t, conf, map[string]string{
+		"eicar.com": sandbox.EICAR,
+		"clean.exe": "MZ",
+	})
+	for name, tsk := range tasks {
+		expected := samples[name]
+		if tsk.RiskLevel != expected {
+			t.Errorf("%s: expected %v, but got %v (%s)", tsk.Path, expected, tsk.RiskLevel, tsk.Message)
+		}
+		if _, err := os.Stat(tsk.Report); err != nil {
+			t.Errorf("%s: report: %v", tsk.Path, err)
+		}
+		if _, err := os.Stat(tsk.Investigation); err != nil {
+			t.Errorf("%s: investigation: %v", tsk.Path, err)
+		}
+	}
+}
+
+func TestLauncherRestart(t *testing.T) {
+	conf, folder := testConfig(t)
+	path := filepath.Join(folder, "eicar.com")
+	if err := os.WriteFile(path, []byte(sandbox.EICAR), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	id, err := list.NewTask(task.FileTask, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+	waitDone(t, list)
+	if err := launcher.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	list = task.NewList()
+	launcher = NewLauncher(conf, task.NewChannels(), list)
+	launcher.Run()
+	defer launcher.Stop()
+	tsk := list.Get(id)
+	if tsk == nil || tsk.Path != path || tsk.RiskLevel != sandbox.RiskLevelHigh {
+		t.Fatalf("task #%d is not restored: %v", id, tsk)
+	}
+	next, err := list.NewTask(task.URLTask, "http://example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next <= id {
+		t.Errorf("ID %d is reused", next)
+	}
+}
+
+func TestLauncherRouting(t *testing.T) {
+	conf, _ := testConfig(t)
+	strict := config.NewDefaultMock()
+	strict.SetDefaultVerdict("Medium Risk")
+	strict.SetLatency(0)
+	strict.SetAnalysisTime(0)
+	conf.SetSandboxes([]config.Sandbox{
+		{Name: "default", Type: config.SandboxMock},
+		{Name: "strict", Type: config.SandboxMock, Mock: strict},
+	}, []config.RoutingRule{
+		{Sandbox: "strict", Extensions: []string{"exe"}},
+	})
+	samples := map[string]struct {
+		sandbox   string
+		riskLevel sandbox.RiskLevel
+	}{
+		"program.exe": {"strict", sandbox.RiskLevelMedium},
+		"text.txt":    {"default", sandbox.RiskLevelNoRisk},
+	}
+	contents := make(map[string]string)
+	for name := range samples {
+		contents[name] = name
+	}
+	for name, tsk := range runSamples(t, conf, contents) {
+		expected := samples[name]
+		if tsk.Sandbox != expected.sandbox {
+			t.Errorf("%s: expected %s sandbox, but got %s", tsk.Path, expected.sandbox, tsk.Sandbox)
+		}
+		if tsk.RiskLevel != expected.riskLevel {
+			t.Errorf("%s: expected %v, but got %v (%s)", tsk.Path, expected.riskLevel, tsk.RiskLevel, tsk.Message)
+		}
+	}
+}
+
+func TestLauncherFanOut(t *testing.T) {
+	conf, _ := testConfig(t)
+	strict := config.NewDefaultMock()
+	strict.SetDefaultVerdict("Medium Risk")
+	strict.SetLatency(0)
+	strict.SetAnalysisTime(0)
+	conf.SetSandboxes([]config.Sandbox{
+		{Name: "default", Type: config.SandboxMock},
+		{Name: "strict", Type: config.SandboxMock, Mock: strict},
+	}, []config.RoutingRule{
+		{FanOut: []string{"default", "strict"}},
+	})
+	conf.SetConsensus(config.ConsensusWorst)
+	tsk := runSamples(t, conf, map[string]string{"program.exe": "MZ"})["program.exe"]
+	if tsk.RiskLevel != sandbox.RiskLevelMedium || tsk.Sandbox != "strict" {
+		t.Errorf("expected %v by strict, but got %v by %s (%s)", sandbox.RiskLevelMedium, tsk.RiskLevel, tsk.Sandbox, tsk.Message)
+	}
+	expected := []sandbox.RiskLevel{sandbox.RiskLevelNoRisk, sandbox.RiskLevelMedium}
+	if len(tsk.Verdicts) != len(expected) {
+		t.Fatalf("expected %d verdicts, but got %v", len(expected), tsk.Verdicts)
+	}
+	for i, v := range tsk.Verdicts {
+		if v.RiskLevel != expected[i] || v.SandboxID == "" {
+			t.Errorf("%s: expected %v, but got %v", v.Sandbox, expected[i], v)
+		}
+	}
+	if _, err := os.Stat(tsk.Report); err != nil {
+		t.Errorf("report: %v", err)
+	}
+}
+
+func TestLauncherKnownVerdict(t *testing.T) {
+	conf, folder := testConfig(t)
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	submit := func(name string) *task.Task {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(sandbox.EICAR), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(task.FileTask, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+		waitDone(t, list)
+		return list.Get(id)
+	}
+	first := submit("first.com")
+	second := submit("second.com")
+	if second != first {
+		t.Fatalf("same content is not merged: %v", second)
+	}
+	if len(first.Aliases) != 1 || filepath.Base(first.Aliases[0]) != "second.com" {
+		t.Errorf("wrong aliases: %v", first.Aliases)
+	}
+	if list.Length() != 1 {
+		t.Errorf("expected 1 task, but got %d", list.Length())
+	}
+	if _, err := list.NewTask(task.FileTask, filepath.Join(folder, "second.com")); !errors.Is(err, task.ErrAlreadyExists) {
+		t.Errorf("alias is submitted once again: %v", err)
+	}
+	t.Run("recheck", func(t *testing.T) {
+		sandboxID := first.SandboxID
+		first.Recheck()
+		channels.Push(task.ChPrefilter, first.Number, first.Priority)
+		waitDone(t, list)
+		if first.RiskLevel != sandbox.RiskLevelHigh || first.SandboxID == sandboxID {
+			t.Errorf("rechecked task is not uploaded: %v", first)
+		}
+	})
+	t.Run("outdated", func(t *testing.T) {
+		sandboxID := first.SandboxID
+		conf.SetKnownVerdictAge(0)
+		third := submit("third.com")
+		if third != first || first.RiskLevel != sandbox.RiskLevelHigh || first.SandboxID == sandboxID {
+			t.Errorf("outdated verdict is used: %v", third)
+		}
+	})
+}
+
+func TestLauncherArchive(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.Archives.SetExpand(true)
+	path := filepath.Join(folder, "sample.zip")
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"eicar.com": sandbox.EICAR, "clean.exe": "MZ"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	id, err := list.NewTask(task.FileTask, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+	waitDone(t, list)
+	archive := list.Get(id)
+	if archive.RiskLevel != sandbox.RiskLevelHigh {
+		t.Errorf("expected %v, but got %v (%s)", sandbox.RiskLevelHigh, archive.RiskLevel, archive.Message)
+	}
+	members := list.Members(archive)
+	if len(members) != 2 {
+		t.Fatalf("expected 2 members, but got %d", len(members))
+	}
+	for _, member := range members {
+		if member.Archive != archive.SHA256 {
+			t.Errorf("%s: not linked to archive", member.Path)
+		}
+	}
+	if err := list.DeleteTask(archive); err != nil {
+		t.Fatal(err)
+	}
+	if list.Length() != 0 {
+		t.Errorf("members are not deleted: %d tasks left", list.Length())
+	}
+}
+
+func TestLauncherFileTypes(t *testing.T) {
+	conf, _ := testConfig(t)
+	conf.FileTypes.SetDeny([]string{"executable"})
+	samples := map[string]struct {
+		content  string
+		fileType string
+		expected sandbox.RiskLevel
+	}{
+		"eicar.com": {sandbox.EICAR, "text", sandbox.RiskLevelHigh},
+		"clean.txt": {"MZ", "pe", sandbox.RiskLevelUnsupported},
+	}
+	contents := make(map[string]string)
+	for name, sample := range samples {
+		contents[name] = sample.content
+	}
+	for name, tsk := range runSamples(t, conf, contents) {
+		sample := samples[name]
+		if tsk.FileType != sample.fileType {
+			t.Errorf("%s: expected type %s, but got %s", tsk.Path, sample.fileType, tsk.FileType)
+		}
+		if tsk.RiskLevel != sample.expected {
+			t.Errorf("%s: expected %v, but got %v (%s)", tsk.Path, sample.expected, tsk.RiskLevel, tsk.Message)
+		}
+	}
+}
+
+func TestLauncherLimits(t *testing.T) {
+	conf, _ := testConfig(t)
+	conf.Limits.SetMock(config.Constraints{MaxSize: 10, NoURLs: true})
+	samples := map[string]sandbox.RiskLevel{
+		"http://www.example.com": sandbox.RiskLevelUnsupported,
+		"large.exe":              sandbox.RiskLevelUnsupported,
+		"small.exe":              sandbox.RiskLevelNoRisk,
+	}
+	tasks := runSamples(t, conf, map[string]string{
+		"http://www.example.com": "",
+		"large.exe":              "MZ" + strings.Repeat("\x00", 100),
+		"small.exe":              "MZ",
+	})
+	for name, tsk := range tasks {
+		if tsk.RiskLevel != samples[name] {
+			t.Errorf("%s: expected %v, but got %v (%s)", name, samples[name], tsk.RiskLevel, tsk.Message)
+		}
+	}
+}
+
+func TestLauncherIgnore(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.SetIgnoreRules(append(conf.GetIgnoreRules(),
+		ignore.Rule{Name: "Large", MinSize: 100},
+		ignore.Rule{Glob: "**/node_modules/**"},
+	))
+	project := filepath.Join(folder, "project")
+	files := map[string]string{
+		ignore.FileName:                "*.log\n",
+		"main.exe":                     "MZ",
+		"debug.log":                    "log",
+		"large.bin":                    strings.Repeat("\x00", 200),
+		"node_modules/lib/index.js":    "js",
+		".git/config":                  "config",
+		"src/" + ignore.FileName:       "!keep.log\n",
+		"src/keep.log":                 "log",
+		"src/node_modules_list.txt":    "text",
+		"src/vendor/node_modules/a.js": "js",
+	}
+	for name, content := range files {
+		path := filepath.Join(project, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := map[string]string{
+		"main.exe":                  "",
+		"large.bin":                 "Matched ignore rule 'Large'",
+		"src/keep.log":              "",
+		"src/node_modules_list.txt": "",
+	}
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	id, err := list.NewTask(task.FileTask, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+	deadline := time.Now().Add(10 * time.Second)
+	for list.Length() != len(expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d tasks, but got %d", len(expected), list.Length())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	waitDone(t, list)
+	for _, id := range list.GetIDs() {
+		tsk := list.Get(id)
+		rel, err := filepath.Rel(project, tsk.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		message, ok := expected[filepath.ToSlash(rel)]
+		if !ok {
+			t.Errorf("%s: not ignored", rel)
+			continue
+		}
+		if message == "" && tsk.RiskLevel == sandbox.RiskLevelUnsupported || message != "" && tsk.Message != message {
+			t.Errorf("%s: expected \"%s\", but got %v (%s)", rel, message, tsk.RiskLevel, tsk.Message)
+		}
+	}
+}
+
+func TestLauncherWatch(t *testing.T) {
+	conf, folder := testConfig(t)
+	downloads := filepath.Join(folder, "Downloads")
+	mail := filepath.Join(folder, "Mail")
+	conf.SetIgnore(append(conf.GetIgnore(), "*.tmp"))
+	conf.Watch.SetEnabled(true)
+	conf.Watch.SetSettle(300 * time.Millisecond)
+	conf.Watch.SetFolders([]config.WatchedFolder{
+		{Path: downloads, Action: config.WatchSubmit},
+		{Path: mail, Recursive: true, Action: config.WatchSubmit},
+	})
+	write := func(name, content string) string {
+		t.Helper()
+		path := filepath.Join(folder, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("Downloads/old.exe", "MZ old")
+	write("Mail/"+ignore.FileName, "*.log\n")
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+
+	setup := filepath.Join(downloads, "setup.exe")
+	f, err := os.Create(setup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range []string{"MZ", " new", " setup"} {
+		if _, err := f.WriteString(chunk); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if list.Length() != 0 {
+			t.Fatalf("file is submitted before it settled")
+		}
+	}
+	f.Close()
+	write("Downloads/partial.tmp", "MZ tmp")
+	write("Downloads/sub/nested.exe", "MZ nested")
+	letter := write("Mail/inbox/letter.doc", "letter")
+	write("Mail/inbox/mail.log", "log")
+	expected := []string{setup, letter}
+	deadline := time.Now().Add(10 * time.Second)
+	for list.Length() != len(expected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d tasks, but got %d", len(expected), list.Length())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	waitDone(t, list)
+	time.Sleep(time.Second)
+	if list.Length() != len(expected) {
+		t.Fatalf("expected %d tasks, but got %d", len(expected), list.Length())
+	}
+	for _, path := range expected {
+		if list.FindTask(path) == nil {
+			t.Errorf("%s: not submitted", path)
+		}
+	}
+
+	tsk := list.FindTask(setup)
+	sha256 := tsk.SHA256
+	generation := tsk.Generation()
+	write("Downloads/setup.exe", "MZ changed setup")
+	deadline = time.Now().Add(10 * time.Second)
+	for {
+		// Recheck resets risk level before it gives task new generation
+		rechecked := tsk.Generation() != generation
+		channel, riskLevel := tsk.Status()
+		if rechecked && channel == task.ChDone && riskLevel != sandbox.RiskLevelUnknown {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("changed file is not analyzed: %v", channel)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if tsk.SHA256 == sha256 {
+		t.Errorf("%s: hash is not recalculated", setup)
+	}
+	if list.Length() != len(expected) {
+		t.Errorf("expected %d tasks, but got %d", len(expected), list.Length())
+	}
+}
+
+// waitChannel - wait for task to reach given channel
+func waitChannel(t *testing.T, tsk *task.Task, ch task.Channel) {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for {
+		channel, _ := tsk.Status()
+		if channel == ch {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: timeout in %v waiting for %v", tsk.Path, channel, ch)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLauncherControl(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.Mock.SetAnalysisTime(2 * time.Second)
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	submit := func(name string) *task.Task {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(task.FileTask, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+		return list.Get(id)
+	}
+	paused := submit("paused.exe")
+	cancelled := submit("cancelled.exe")
+	waitChannel(t, paused, task.ChResult)
+	waitChannel(t, cancelled, task.ChResult)
+	if err := list.Pause(paused.Number); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Cancel(cancelled.Number); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * time.Second)
+	if paused.Channel != task.ChPaused || paused.ResumeChannel != task.ChResult {
+		t.Errorf("task is not paused: %v", paused)
+	}
+	if cancelled.Channel != task.ChCancelled || cancelled.RiskLevel != sandbox.RiskLevelUnknown {
+		t.Errorf("task is not cancelled: %v", cancelled)
+	}
+	if err := list.Resume(paused.Number, channels); err != nil {
+		t.Fatal(err)
+	}
+	waitChannel(t, paused, task.ChDone)
+	if paused.RiskLevel != sandbox.RiskLevelNoRisk {
+		t.Errorf("expected %v, got %v", sandbox.RiskLevelNoRisk, paused.RiskLevel)
+	}
+	if err := list.Resume(cancelled.Number, channels); !errors.Is(err, task.ErrWrongState) {
+		t.Errorf("expected %v, got %v", task.ErrWrongState, err)
+	}
+}
+
+func TestLauncherSchedule(t *testing.T) {
+	conf, _ := testConfig(t)
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	schedule := func(name string, delay time.Duration) *task.Task {
+		t.Helper()
+		id, err := list.NewTask(task.URLTask, "http://"+name+".example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		tsk.Channel = task.ChResult
+		launcher.Schedule(tsk, delay)
+		return tsk
+	}
+	pushed := schedule("pushed", 50*time.Millisecond)
+	paused := schedule("paused", 50*time.Millisecond)
+	if err := list.Pause(paused.Number); err != nil {
+		t.Fatal(err)
+	}
+	resumed := schedule("resumed", 50*time.Millisecond)
+	if err := list.Pause(resumed.Number); err != nil {
+		t.Fatal(err)
+	}
+	if err := list.Resume(resumed.Number, channels); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(300 * time.Millisecond)
+	var ids []task.ID
+	for {
+		id, ok := channels.TaskChannel[task.ChResult].TryPop()
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 2 || !slices.Contains(ids, pushed.Number) || !slices.Contains(ids, resumed.Number) {
+		t.Errorf("expected tasks #%d and #%d pushed once, but got %v", pushed.Number, resumed.Number, ids)
+	}
+	schedule("pending", time.Hour)
+	close(launcher.stop)
+	if count := launcher.stopTimers(); count != 1 {
+		t.Errorf("expected 1 pending timer, but got %d", count)
+	}
+	schedule("stopped", time.Millisecond)
+	if len(launcher.timers) != 0 {
+		t.Errorf("timers are left after stop: %d", len(launcher.timers))
+	}
+}
+
+func TestLauncherTimeout(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.Mock.SetLatency(time.Second)
+	conf.Timeouts.SetUpload(200 * time.Millisecond)
+	conf.Retry.SetUpload(config.RetryPolicy{})
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	path := filepath.Join(folder, "slow.exe")
+	if err := os.WriteFile(path, []byte("slow"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := list.NewTask(task.FileTask, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+	tsk := list.Get(id)
+	waitChannel(t, tsk, task.ChDone)
+	if tsk.RiskLevel != sandbox.RiskLevelError || !strings.Contains(tsk.Message, task.ErrTimeout.Error()) {
+		t.Errorf("expected timeout, got %v: %s", tsk.RiskLevel, tsk.Message)
+	}
+}
+
+func TestLauncherScale(t *testing.T) {
+	conf, _ := testConfig(t)
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	workers := func(ch task.Channel) int {
+		for _, m := range launcher.Metrics() {
+			if m.Stage == ch.String() {
+				return m.Workers
+			}
+		}
+		t.Fatalf("no metrics for %v", ch)
+		return 0
+	}
+	if n := workers(task.ChSubmit); n != conf.Workers.GetUpload() {
+		t.Errorf("expected %d upload workers, got %d", conf.Workers.GetUpload(), n)
+	}
+	conf.Workers.SetUpload(2)
+	conf.Workers.SetResult(8)
+	conf.Workers.SetQuota(0)
+	launcher.Scale()
+	for ch, expected := range map[task.Channel]int{task.ChSubmit: 2, task.ChResult: 8, task.ChQuota: 1} {
+		if n := workers(ch); n != expected {
+			t.Errorf("%v: expected %d workers, got %d", ch, expected, n)
+		}
+	}
+}
+
+func TestLauncherAlreadyRunning(t *testing.T) {
+	conf, _ := testConfig(t)
+	path, err := globals.SocketPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	first := NewLauncher(conf, task.NewChannels(), task.NewList())
+	if err := first.Run(); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Stop()
+	second := NewLauncher(conf, task.NewChannels(), task.NewList())
+	if err := second.Run(); !errors.Is(err, ipc.ErrAlreadyRunning) {
+		t.Fatalf("expected %v, got %v", ipc.ErrAlreadyRunning, err)
+	}
+	for _, m := range second.Metrics() {
+		if m.Workers != 0 {
+			t.Errorf("%s: %d workers are started", m.Stage, m.Workers)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+lookup_dispatch.go
+
+Find known verdict for task to avoid uploading it once again
+*/
+package dispatchers
+
+import (
+	"time"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type LookupDispatch struct {
+	BaseDispatcher
+}
+
+func NewLookupDispatch(d BaseDispatcher) *LookupDispatch {
+	return &LookupDispatch{
+		BaseDispatcher: d,
+	}
+}
+
+func (*LookupDispatch) InboundChannel() task.Channel {
+	return task.ChLookup
+}
+
+// ProcessTask - use submission of the same object to the sandbox. Other
+// tasks with the same content are merged by prefilter. Rechecked tasks are
+// always uploaded
+func (d *LookupDispatch) ProcessTask(tsk *task.Task) error {
+	age := d.conf.GetKnownVerdictAge()
+	if age <= 0 || tsk.ForceUpload {
+		tsk.SetChannel(task.ChSubmit)
+		return nil
+	}
+	since := time.Now().Add(-age)
+	if d.conf.GetSandboxLookup() && !tsk.FanOut() {
+		id, err := d.SandboxLookup(tsk, since)
+		if tsk.Context().Err() != nil {
+			return err
+		}
+		if err != nil {
+			logging.Errorf("%s: lookup: %v", tsk.Path, err)
+		}
+		if id != "" {
+			logging.Infof("%s: found in %s sandbox: %s", tsk.Path, tsk.Sandbox, id)
+			tsk.SetSandboxID(id)
+			// Submission found is not older than since, so its result is
+			// requested along with other uploads without full history scan
+			tsk.SetUploaded(since)
+			tsk.SetChannel(task.ChResult)
+			d.list.Updated()
+			return nil
+		}
+	}
+	tsk.SetChannel(task.ChSubmit)
+	return nil
+}
+
+// SandboxLookup - find submission of the object in task sandbox, if it supports lookup
+func (d *LookupDispatch) SandboxLookup(tsk *task.Task, since time.Time) (string, error) {
+	sb, err := d.Sandbox(tsk)
+	if err != nil {
+		return "", err
+	}
+	lookup, ok := sb.(sandbox.Lookup)
+	if !ok {
+		return "", nil
+	}
+	return lookup.Lookup(tsk.Context(), tsk.SHA1, tsk.SHA256, since)
+}
@@ -0,0 +1,82 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+members_dispatch.go
+
+Verdict of archive from verdicts of its files
+*/
+package dispatchers
+
+import (
+	"fmt"
+
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type MembersDispatch struct {
+	BaseDispatcher
+}
+
+func NewMembersDispatch(d BaseDispatcher) *MembersDispatch {
+	return &MembersDispatch{
+		BaseDispatcher: d,
+	}
+}
+
+func (*MembersDispatch) InboundChannel() task.Channel {
+	return task.ChMembers
+}
+
+// ProcessTask - wait for all files extracted from archive to be finished
+// and give archive the worst of their verdicts. Deleted files are not waited for
+func (d *MembersDispatch) ProcessTask(tsk *task.Task) error {
+	members := d.list.Members(tsk)
+	for _, member := range members {
+		if channel, _ := member.Status(); channel != task.ChDone && channel != task.ChCancelled {
+			tsk.Postpone(d.conf.GetSleep())
+			return nil
+		}
+	}
+	worst := RollUp(members)
+	if worst == nil {
+		tsk.SetRiskLevel(sandbox.RiskLevelUnsupported)
+		tsk.SetMessage("No files of archive left")
+	} else {
+		_, riskLevel := worst.Status()
+		tsk.SetRiskLevel(riskLevel)
+		tsk.SetMessage(fmt.Sprintf("%d files, worst: %s", len(members), worst.Title()))
+	}
+	tsk.SetChannel(task.ChDone)
+	d.list.Updated()
+	return nil
+}
+
+// RollUp - member with the worst verdict. Threats are worse than errors and
+// errors are worse than "not analyzed" and "no risk"
+func RollUp(members []*task.Task) (worst *task.Task) {
+	worstSeverity := 0
+	for _, member := range members {
+		_, riskLevel := member.Status()
+		if worst == nil || severity(riskLevel) > worstSeverity {
+			worst = member
+			worstSeverity = severity(riskLevel)
+		}
+	}
+	return
+}
+
+func severity(riskLevel sandbox.RiskLevel) int {
+	switch riskLevel {
+	case sandbox.RiskLevelNoRisk:
+		return 0
+	case sandbox.RiskLevelUnsupported:
+		return 1
+	case sandbox.RiskLevelUnknown, sandbox.RiskLevelNotReady:
+		return 2
+	case sandbox.RiskLevelError:
+		return 3
+	}
+	return 4 + int(riskLevel-sandbox.RiskLevelLow)
+}
@@ -0,0 +1,54 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+members_dispatch_test.go
+
+Test roll up of archive members verdicts
+*/
+package dispatchers
+
+import (
+	"testing"
+
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func TestRollUp(t *testing.T) {
+	testCases := []struct {
+		name       string
+		riskLevels []sandbox.RiskLevel
+		expected   int
+	}{
+		{"no members", nil, -1},
+		{"single", []sandbox.RiskLevel{sandbox.RiskLevelNoRisk}, 0},
+		{"threat over no risk", []sandbox.RiskLevel{sandbox.RiskLevelNoRisk, sandbox.RiskLevelLow}, 1},
+		{"higher threat", []sandbox.RiskLevel{sandbox.RiskLevelHigh, sandbox.RiskLevelMedium, sandbox.RiskLevelLow}, 0},
+		{"threat over error", []sandbox.RiskLevel{sandbox.RiskLevelError, sandbox.RiskLevelLow}, 1},
+		{"error over unknown", []sandbox.RiskLevel{sandbox.RiskLevelUnknown, sandbox.RiskLevelError}, 1},
+		{"unknown over unsupported", []sandbox.RiskLevel{sandbox.RiskLevelUnsupported, sandbox.RiskLevelUnknown}, 1},
+		{"unsupported over no risk", []sandbox.RiskLevel{sandbox.RiskLevelNoRisk, sandbox.RiskLevelUnsupported}, 1},
+		{"first of equal", []sandbox.RiskLevel{sandbox.RiskLevelMedium, sandbox.RiskLevelMedium}, 0},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			var members []*task.Task
+			for i, riskLevel := range tCase.riskLevels {
+				member := task.NewTask(task.ID(i), task.FileTask, "member.exe")
+				member.RiskLevel = riskLevel
+				members = append(members, member)
+			}
+			worst := RollUp(members)
+			if tCase.expected < 0 {
+				if worst != nil {
+					t.Errorf("expected no member, but got %v", worst.RiskLevel)
+				}
+				return
+			}
+			if worst != members[tCase.expected] {
+				t.Errorf("expected member %d, but got %v", tCase.expected, worst)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+poll.go
+
+Choose when to check analysis result
+*/
+package dispatchers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+// estimateInterval - how often average analysis time is requested from sandbox
+const estimateInterval = 10 * time.Minute
+
+type estimate struct {
+	duration time.Duration
+	checked  time.Time
+}
+
+// Poller - chooses delay before next result check of each task. Checks are
+// frequent right after upload and get rarer for long analysis. If sandbox
+// knows its average analysis time, there are no checks till it has passed.
+// Only one request of average analysis time of each sandbox is made at a time
+type Poller struct {
+	mx        sync.Mutex
+	conf      *config.Configuration
+	sandbox   func(name string) (sandbox.Sandbox, error)
+	now       func() time.Time
+	estimates map[string]*estimate
+	refreshes map[string]chan struct{}
+}
+
+func NewPoller(d BaseDispatcher) *Poller {
+	return &Poller{
+		conf:      d.conf,
+		sandbox:   d.SandboxByName,
+		now:       time.Now,
+		estimates: make(map[string]*estimate),
+		refreshes: make(map[string]chan struct{}),
+	}
+}
+
+// Delay - time till next result check. It starts from sleep setting
+// and grows by half on each check up to poll_max_interval
+func (p *Poller) Delay(tsk *task.Task) time.Duration {
+	shortest := p.conf.GetSleep()
+	longest := max(p.conf.GetPollMaxInterval(), shortest)
+	delay := shortest
+	for i := 0; i < tsk.Polls && delay < longest; i++ {
+		delay += delay / 2
+	}
+	delay = min(delay, longest)
+	if tsk.UploadTime.IsZero() {
+		return delay
+	}
+	left := tsk.UploadTime.Add(p.Expected(tsk)).Sub(p.now())
+	if left > delay {
+		delay = min(left, longest)
+	}
+	return delay
+}
+
+// Expected - longest average analysis time of sandboxes task waits result from
+func (p *Poller) Expected(tsk *task.Task) time.Duration {
+	names := []string{tsk.Sandbox}
+	if tsk.FanOut() {
+		names = nil
+		for _, v := range tsk.Verdicts {
+			if !v.Final() {
+				names = append(names, v.Sandbox)
+			}
+		}
+	}
+	var expected time.Duration
+	for _, name := range names {
+		expected = max(expected, p.estimate(tsk.Context(), name))
+	}
+	return expected
+}
+
+// estimate - average analysis time of the sandbox. It is requested
+// every estimateInterval and previous value is used in between. Concurrent
+// callers wait for result of request in progress
+func (p *Poller) estimate(ctx context.Context, name string) time.Duration {
+	p.mx.Lock()
+	defer p.mx.Unlock()
+	e, ok := p.estimates[name]
+	if ok && p.now().Sub(e.checked) < estimateInterval {
+		return e.duration
+	}
+	done, refreshing := p.refreshes[name]
+	if !refreshing {
+		return p.refresh(ctx, name)
+	}
+	p.mx.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	p.mx.Lock()
+	if e, ok := p.estimates[name]; ok {
+		return e.duration
+	}
+	return 0
+}
+
+// refresh - request average analysis time of sandbox with p.mx unlocked
+// and store it. Must be called with p.mx locked
+func (p *Poller) refresh(ctx context.Context, name string) time.Duration {
+	done := make(chan struct{})
+	p.refreshes[name] = done
+	e := &estimate{checked: p.now()}
+	if previous, ok := p.estimates[name]; ok {
+		e.duration = previous.duration
+	}
+	p.mx.Unlock()
+	e.duration = p.request(ctx, name, e.duration)
+	p.mx.Lock()
+	p.estimates[name] = e
+	delete(p.refreshes, name)
+	close(done)
+	return e.duration
+}
+
+// request - average analysis time of sandbox. If sandbox failed to report
+// it, previous value is kept
+func (p *Poller) request(ctx context.Context, name string, previous time.Duration) time.Duration {
+	sb, err := p.sandbox(name)
+	if err != nil {
+		logging.LogError(err)
+		return previous
+	}
+	est, ok := sb.(sandbox.Estimate)
+	if !ok {
+		return 0
+	}
+	duration, err := est.AnalysisTime(ctx)
+	if err != nil {
+		logging.Errorf("%s: analysis time: %v", name, err)
+		return previous
+	}
+	logging.Debugf("%s: average analysis time is %v", name, duration)
+	return duration
+}
@@ -0,0 +1,143 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+poll_test.go
+
+Test result polling schedule
+*/
+package dispatchers
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type estimateSandbox struct {
+	sandbox.Sandbox
+	analysisTime time.Duration
+	release      chan struct{}
+	mx           sync.Mutex
+	requests     int
+}
+
+func (s *estimateSandbox) AnalysisTime(ctx context.Context) (time.Duration, error) {
+	s.mx.Lock()
+	s.requests++
+	s.mx.Unlock()
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+	return s.analysisTime, nil
+}
+
+func TestPollerDelay(t *testing.T) {
+	conf := config.New("")
+	conf.SetSleep(4 * time.Second)
+	conf.SetPollMaxInterval(time.Minute)
+	sb := &estimateSandbox{analysisTime: 3 * time.Minute}
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := &Poller{
+		conf:      conf,
+		sandbox:   func(string) (sandbox.Sandbox, error) { return sb, nil },
+		now:       func() time.Time { return now },
+		estimates: make(map[string]*estimate),
+		refreshes: make(map[string]chan struct{}),
+	}
+	tsk := task.NewTask(0, task.FileTask, "file.exe")
+	testCases := []struct {
+		polls    int
+		expected time.Duration
+	}{
+		{0, 4 * time.Second},
+		{1, 6 * time.Second},
+		{2, 9 * time.Second},
+		{10, time.Minute},
+	}
+	for _, tCase := range testCases {
+		tsk.Polls = tCase.polls
+		if delay := p.Delay(tsk); delay != tCase.expected {
+			t.Errorf("%d polls: expected %v, got %v", tCase.polls, tCase.expected, delay)
+		}
+	}
+	if sb.requests != 0 {
+		t.Errorf("analysis time is requested for task that is not uploaded")
+	}
+	t.Run("estimate", func(t *testing.T) {
+		tsk.SetUploaded(now)
+		if delay := p.Delay(tsk); delay != time.Minute {
+			t.Errorf("right after upload: expected %v, got %v", time.Minute, delay)
+		}
+		now = now.Add(150 * time.Second)
+		if delay := p.Delay(tsk); delay != 30*time.Second {
+			t.Errorf("before expected end: expected %v, got %v", 30*time.Second, delay)
+		}
+		now = now.Add(time.Minute)
+		if delay := p.Delay(tsk); delay != 4*time.Second {
+			t.Errorf("after expected end: expected %v, got %v", 4*time.Second, delay)
+		}
+		if sb.requests != 1 {
+			t.Errorf("expected 1 analysis time request, got %d", sb.requests)
+		}
+	})
+}
+
+func TestPollerRefresh(t *testing.T) {
+	slow := &estimateSandbox{analysisTime: 3 * time.Minute, release: make(chan struct{})}
+	fast := &estimateSandbox{analysisTime: time.Minute}
+	p := &Poller{
+		conf: config.New(""),
+		sandbox: func(name string) (sandbox.Sandbox, error) {
+			if name == "slow" {
+				return slow, nil
+			}
+			return fast, nil
+		},
+		now:       time.Now,
+		estimates: make(map[string]*estimate),
+		refreshes: make(map[string]chan struct{}),
+	}
+	const waiting = 3
+	results := make(chan time.Duration, waiting)
+	for i := 0; i < waiting; i++ {
+		go func() {
+			results <- p.estimate(context.Background(), "slow")
+		}()
+	}
+	for {
+		p.mx.Lock()
+		_, refreshing := p.refreshes["slow"]
+		p.mx.Unlock()
+		if refreshing {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if duration := p.estimate(context.Background(), "fast"); duration != time.Minute {
+		t.Errorf("fast sandbox: expected %v, got %v", time.Minute, duration)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if duration := p.estimate(ctx, "slow"); duration != 0 {
+		t.Errorf("cancelled wait: expected 0, got %v", duration)
+	}
+	close(slow.release)
+	for i := 0; i < waiting; i++ {
+		if duration := <-results; duration != 3*time.Minute {
+			t.Errorf("slow sandbox: expected %v, got %v", 3*time.Minute, duration)
+		}
+	}
+	if slow.requests != 1 {
+		t.Errorf("expected 1 analysis time request, got %d", slow.requests)
+	}
+}
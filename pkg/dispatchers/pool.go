@@ -0,0 +1,118 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+pool.go
+
+Dispatchers of each processing stage and their load
+*/
+package dispatchers
+
+import (
+	"sync/atomic"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+// pool - dispatchers working on the same processing stage
+type pool struct {
+	dispatcher Dispatcher
+	workers    []chan struct{} // closed to stop worker
+	busy       atomic.Int32
+}
+
+// StageMetrics - load of one processing stage
+type StageMetrics struct {
+	Stage   string `json:"stage"`
+	Queued  int    `json:"queued"`
+	Workers int    `json:"workers"`
+	Busy    int    `json:"busy"`
+}
+
+// Workers - number of dispatchers for given processing stage. Each stage
+// has at least one, so tasks never get stuck
+func (l *Launcher) Workers(ch task.Channel) int {
+	workers := l.conf.Workers
+	count := 1
+	switch ch {
+	case task.ChPrefilter:
+		count = workers.GetPrefilter()
+	case task.ChLookup:
+		count = workers.GetLookup()
+	case task.ChQuota:
+		count = workers.GetQuota()
+	case task.ChSubmit:
+		count = workers.GetUpload()
+	case task.ChResult:
+		count = workers.GetResult()
+	case task.ChReport:
+		count = workers.GetReport()
+	case task.ChInvestigation:
+		count = workers.GetInvestigation()
+	}
+	return max(count, 1)
+}
+
+// Scale - start or stop dispatchers, so each stage has number of workers
+// set in configuration. Stopped dispatcher finishes task it is busy with.
+// Can be called any time after Run to apply new configuration
+func (l *Launcher) Scale() {
+	l.pmx.Lock()
+	defer l.pmx.Unlock()
+	select {
+	case <-l.stop:
+		return
+	default:
+	}
+	for _, p := range l.pools {
+		count := l.Workers(p.dispatcher.InboundChannel())
+		if count != len(p.workers) {
+			logging.Infof("%v: %d workers", p.dispatcher.InboundChannel(), count)
+		}
+		for len(p.workers) < count {
+			quit := make(chan struct{})
+			p.workers = append(p.workers, quit)
+			l.wg.Add(1)
+			go l.RunDispatcher(p, quit)
+		}
+		for len(p.workers) > count {
+			last := len(p.workers) - 1
+			close(p.workers[last])
+			p.workers = p.workers[:last]
+		}
+	}
+}
+
+func (l *Launcher) stopWorkers() {
+	l.pmx.Lock()
+	defer l.pmx.Unlock()
+	for _, p := range l.pools {
+		for _, quit := range p.workers {
+			close(quit)
+		}
+		p.workers = nil
+	}
+}
+
+// Metrics - queue length, number of workers and number of busy workers
+// for each processing stage in processing order
+func (l *Launcher) Metrics() []StageMetrics {
+	l.pmx.Lock()
+	defer l.pmx.Unlock()
+	var metrics []StageMetrics
+	for ch := task.ChPrefilter; ch < task.ChDone; ch++ {
+		m := StageMetrics{
+			Stage:  ch.String(),
+			Queued: l.channels.TaskChannel[ch].Len(),
+		}
+		for _, p := range l.pools {
+			if p.dispatcher.InboundChannel() == ch {
+				m.Workers = len(p.workers)
+				m.Busy = int(p.busy.Load())
+			}
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
@@ -13,10 +13,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sandboxer/pkg/extract"
+	"sandboxer/pkg/filetype"
+	"sandboxer/pkg/ignore"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/sandbox"
 	"sandboxer/pkg/task"
-	"strings"
+	"slices"
+	"time"
 )
 
 type PrefilterDispatch struct {
@@ -42,7 +46,7 @@ func (d *PrefilterDispatch) ProcessTask(tsk *task.Task) error {
 		}
 		if info.IsDir() {
 			d.list.DelByID(tsk.Number)
-			go d.InspecfFolder(tsk.Path)
+			go d.InspecfFolder(tsk.Path, max(tsk.Priority, task.PriorityFolderScan))
 			d.list.Updated()
 			return nil
 		}
@@ -52,47 +56,294 @@ func (d *PrefilterDispatch) ProcessTask(tsk *task.Task) error {
 		if err := tsk.CalculateHash(); err != nil {
 			return err
 		}
-		mask := d.MatchIgnoreMask(tsk.Path)
-		if mask != "" {
-			tsk.SetChannel(task.ChDone)
-			tsk.SetRiskLevel(sandbox.RiskLevelUnsupported)
-			tsk.SetMessage(fmt.Sprintf("Matched ignore mask '%s'", mask))
-			tsk.SetChannel(task.ChDone)
-			d.list.Updated()
+		if reason := ignore.Check(d.conf.GetIgnore(), d.conf.GetIgnoreRules(), tsk.Path, info); reason != "" {
+			logging.Debugf("%s: %s", tsk.Path, reason)
+			d.Unsupported(tsk, reason)
+			return nil
+		}
+		reason, err := d.MatchFileType(tsk)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			d.Unsupported(tsk, reason)
 			return nil
 		}
+		if d.conf.Archives.GetExpand() && tsk.Archive == "" {
+			if original := d.list.ClaimContent(tsk); original != nil {
+				return d.Merge(tsk, original)
+			}
+			expanded, err := d.Expand(tsk)
+			if err != nil {
+				return err
+			}
+			if expanded {
+				return nil
+			}
+		}
+		reason, err = d.Route(tsk, info.Size())
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			d.Unsupported(tsk, reason)
+			return nil
+		}
+		if original := d.list.ClaimContent(tsk); original != nil {
+			return d.Merge(tsk, original)
+		}
 	} else {
 		if err := tsk.CalculateHash(); err != nil {
 			return err
 		}
+		reason, err := d.Route(tsk, 0)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			d.Unsupported(tsk, reason)
+			return nil
+		}
 	} //	logging.Debugf("Send Task #%d to %d", tsk.Number, ChUpload)
-	tsk.SetChannel(task.ChSubmit)
+	tsk.SetChannel(task.ChLookup)
 	return nil
 }
 
-func (p *PrefilterDispatch) InspecfFolder(folderPath string) {
+// Merge - make task an alias of other task with the same content and reuse
+// its verdict. If original verdict can not be reused, original is analyzed again
+func (d *PrefilterDispatch) Merge(tsk, original *task.Task) error {
+	logging.Infof("%s: same content as task #%d (%s)", tsk.Path, original.Number, original.Path)
+	if !d.Outdated(original) {
+		return d.list.Merge(tsk, original)
+	}
+	original.Recheck()
+	original.SetPriority(tsk.Priority)
+	if err := d.list.Merge(tsk, original); err != nil {
+		return err
+	}
+	d.Push(task.ChPrefilter, original.Number, original.Priority)
+	return nil
+}
+
+// Outdated - task is finished without usable verdict or its verdict is older
+// than known verdict age. Resubmission of the same content does not make
+// verdict younger
+func (d *PrefilterDispatch) Outdated(tsk *task.Task) bool {
+	channel, riskLevel := tsk.Status()
+	switch channel {
+	case task.ChCancelled:
+		return true
+	case task.ChDone:
+	default:
+		return false
+	}
+	if riskLevel != sandbox.RiskLevelNoRisk && !riskLevel.IsThreat() {
+		return true
+	}
+	age := d.conf.GetKnownVerdictAge()
+	return age <= 0 || tsk.VerdictTime.Before(time.Now().Add(-age))
+}
+
+// membersFolder - subfolder of archive task folder for its extracted files
+const membersFolder = "members"
+
+// Expand - create tasks for files of archive. Archive gets the worst of
+// their verdicts. Archive that can not be expanded within limits is analyzed as is
+func (d *PrefilterDispatch) Expand(tsk *task.Task) (bool, error) {
+	folder, err := tsk.Folder()
+	if err != nil {
+		return false, err
+	}
+	folder = filepath.Join(folder, membersFolder)
+	if d.StopMembers(folder) {
+		logging.Debugf("%s: wait for files of previous expansion", tsk.Path)
+		tsk.Postpone(d.conf.GetSleep())
+		return true, nil
+	}
+	if err := os.RemoveAll(folder); err != nil {
+		return false, err
+	}
+	archives := d.conf.Archives
+	limits := extract.Limits{
+		MaxDepth:   archives.GetMaxDepth(),
+		MaxSize:    archives.GetMaxSize(),
+		MaxMembers: archives.GetMaxMembers(),
+		Passwords:  archives.GetPasswords(),
+	}
+	files, err := extract.Expand(tsk.Context(), tsk.Path, folder, limits)
+	if errors.Is(err, extract.ErrNotArchive) {
+		return false, nil
+	}
+	if tsk.Context().Err() != nil {
+		return false, err
+	}
+	if err != nil || len(files) == 0 {
+		logging.Infof("%s: analyze archive as is: %v", tsk.Path, err)
+		logging.LogError(os.RemoveAll(folder))
+		return false, nil
+	}
+	var members []string
+	for _, path := range files {
+		hash, err := d.Member(tsk, path)
+		if err != nil {
+			return false, err
+		}
+		if !slices.Contains(members, hash) {
+			members = append(members, hash)
+		}
+	}
+	logging.Infof("%s: %d files extracted", tsk.Path, len(files))
+	tsk.Members = members
+	tsk.SetChannel(task.ChMembers)
+	d.list.Updated()
+	return true, nil
+}
+
+// StopMembers - cancel tasks of files left in folder from previous expansion
+// of archive that are still in progress. True is returned while any of them
+// is busy with dispatcher, so folder can not be removed yet
+func (d *PrefilterDispatch) StopMembers(folder string) (busy bool) {
+	for _, member := range d.list.Inside(folder) {
+		if channel, _ := member.Status(); channel.Queued() || channel == task.ChPaused {
+			logging.LogError(member.Cancel())
+		}
+		if member.IsActive() {
+			busy = true
+		}
+	}
+	return
+}
+
+// Member - create task for file extracted from archive and return SHA256
+// of the file. Finished task left from previous expansion of the same
+// archive is rechecked. Task still in progress is left as is: it may not
+// have its hash yet, so the file is hashed separately
+func (d *PrefilterDispatch) Member(archive *task.Task, path string) (string, error) {
+	id, err := d.list.NewTask(task.FileTask, path)
+	if err != nil && !errors.Is(err, task.ErrAlreadyExists) {
+		return "", err
+	}
+	member := d.list.Get(id)
+	if err != nil {
+		member = d.list.FindTask(path)
+		if member == nil {
+			return "", err
+		}
+		if channel, _ := member.Status(); channel != task.ChDone && channel != task.ChCancelled {
+			return task.FileHash(path)
+		}
+		member.Recheck()
+	} else {
+		member.Archive = archive.SHA256
+	}
+	member.SetPriority(archive.Priority)
+	if err := member.CalculateHash(); err != nil {
+		return "", err
+	}
+	d.Push(task.ChPrefilter, member.Number, member.Priority)
+	return member.SHA256, nil
+}
+
+// Route - choose sandboxes for task. Rechecked task keeps its sandboxes.
+// Sandboxes that do not accept it are skipped. If none of them does,
+// reason is returned
+func (d *PrefilterDispatch) Route(tsk *task.Task, size int64) (string, error) {
+	if tsk.FanOut() {
+		tsk.ResetVerdicts()
+		return "", nil
+	}
+	if tsk.Sandbox != "" {
+		return "", nil
+	}
+	names, err := d.conf.Route(tsk.Type == task.URLTask, tsk.Path, size)
+	if err != nil {
+		return "", err
+	}
+	names, reason, err := d.Accepting(tsk, names, size)
+	if err != nil {
+		return "", err
+	}
+	if len(names) == 0 {
+		return reason, nil
+	}
+	logging.Debugf("%s: route to %v", tsk.Path, names)
+	if len(names) > 1 {
+		tsk.SetFanOut(names)
+		return "", nil
+	}
+	tsk.SetSandbox(names[0])
+	return "", nil
+}
+
+// Accepting - sandboxes which constraints task fits. Reason of last
+// rejection is returned as well
+func (d *PrefilterDispatch) Accepting(tsk *task.Task, names []string, size int64) ([]string, string, error) {
+	var accepting []string
+	reason := ""
+	for _, name := range names {
+		sb, err := d.conf.FindSandbox(name)
+		if err != nil {
+			return nil, "", err
+		}
+		if r := sb.Limits.Check(tsk.Type == task.URLTask, tsk.Path, size, tsk.FileType); r != "" {
+			logging.Debugf("%s: %s: %s", tsk.Path, name, r)
+			reason = fmt.Sprintf("%s: %s", name, r)
+			continue
+		}
+		accepting = append(accepting, name)
+	}
+	return accepting, reason, nil
+}
+
+// Unsupported - finish task without analysis. Other tasks of the same
+// content are not merged into it, as their paths can be supported
+func (d *PrefilterDispatch) Unsupported(tsk *task.Task, message string) {
+	d.list.ReleaseContent(tsk.Number)
+	tsk.SetRiskLevel(sandbox.RiskLevelUnsupported)
+	tsk.SetMessage(message)
+	tsk.SetChannel(task.ChDone)
+	d.list.Updated()
+}
+
+// InspecfFolder - submit all files of the folder. They get at least folder
+// scan priority, so single files submitted meanwhile go first
+func (p *PrefilterDispatch) InspecfFolder(folderPath string, priority task.Priority) {
 	logging.Debugf("InspectFolder(%s)", folderPath)
+	var walker ignore.Walker
+	rules := p.conf.GetIgnoreRules()
 	err := filepath.Walk(folderPath,
 		func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				return err
 			}
-			if info.IsDir() {
-				if filepath.Base(path) == ".git" {
+			if reason := walker.Match(path, info.IsDir()); reason != "" {
+				logging.Debugf("%s: %s", path, reason)
+				if info.IsDir() {
 					return filepath.SkipDir
 				}
+				return nil
+			}
+			if info.IsDir() {
+				if path != folderPath {
+					if reason := ignore.Check(nil, rules, path, info); reason != "" {
+						logging.Debugf("%s: %s", path, reason)
+						return filepath.SkipDir
+					}
+				}
+				return walker.Enter(path)
 			}
-			if !info.Mode().IsRegular() {
+			if !info.Mode().IsRegular() || info.Name() == ignore.FileName {
 				return nil
 			}
-			tsk, err := p.list.NewTask(task.FileTask, path)
+			id, err := p.list.NewTask(task.FileTask, path)
 			if err != nil {
 				if errors.Is(err, task.ErrAlreadyExists) {
 					return nil
 				}
 				return err
 			}
-			p.Channel(task.ChPrefilter) <- tsk
+			p.list.Get(id).SetPriority(priority)
+			p.Push(task.ChPrefilter, id, priority)
 			return nil
 		})
 	logging.LogError(err)
@@ -100,15 +351,23 @@ func (p *PrefilterDispatch) InspecfFolder(folderPath string) {
 	// XXX task.SetError(id, err)
 }
 
-func (p *PrefilterDispatch) MatchIgnoreMask(filePath string) string {
-	fileName := filepath.Base(filePath)
-	for _, mask := range p.conf.GetIgnore() {
-		result, err := filepath.Match(strings.ToLower(mask), strings.ToLower(fileName))
-		logging.LogError(err)
-		if result {
-			logging.Debugf("%s: ignore by mask \"%s\"", filePath, mask)
-			return mask
-		}
+// MatchFileType - detect type of file by its content and check it against
+// allowed and denied types. Reason to skip the file is returned
+func (d *PrefilterDispatch) MatchFileType(tsk *task.Task) (string, error) {
+	fileType, err := filetype.DetectFile(tsk.Path)
+	if err != nil {
+		return "", err
+	}
+	tsk.SetFileType(string(fileType))
+	types := d.conf.FileTypes
+	if fileType.Match(types.GetDeny()) {
+		logging.Debugf("%s: %v type is denied", tsk.Path, fileType)
+		return fmt.Sprintf("File type %v is denied", fileType), nil
+	}
+	allow := types.GetAllow()
+	if len(allow) > 0 && !fileType.Match(allow) {
+		logging.Debugf("%s: %v type is not allowed", tsk.Path, fileType)
+		return fmt.Sprintf("File type %v is not allowed", fileType), nil
 	}
-	return ""
+	return "", nil
 }
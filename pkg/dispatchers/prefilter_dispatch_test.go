@@ -0,0 +1,230 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+prefilter_dispatch_test.go
+
+Test prefilter stages of file tasks
+*/
+package dispatchers
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func TestOutdated(t *testing.T) {
+	conf, _ := testConfig(t)
+	conf.SetKnownVerdictAge(time.Hour)
+	d := NewPrefilterDispatch(NewBaseDispatcher(conf, task.NewChannels(), task.NewList()))
+	now := time.Now()
+	testCases := []struct {
+		name      string
+		channel   task.Channel
+		riskLevel sandbox.RiskLevel
+		verdict   time.Time
+		expected  bool
+	}{
+		{"fresh", task.ChDone, sandbox.RiskLevelNoRisk, now, false},
+		{"threat", task.ChDone, sandbox.RiskLevelHigh, now, false},
+		{"old", task.ChDone, sandbox.RiskLevelNoRisk, now.Add(-2 * time.Hour), true},
+		{"no verdict time", task.ChDone, sandbox.RiskLevelNoRisk, time.Time{}, true},
+		{"error", task.ChDone, sandbox.RiskLevelError, now, true},
+		{"unsupported", task.ChDone, sandbox.RiskLevelUnsupported, now, true},
+		{"cancelled", task.ChCancelled, sandbox.RiskLevelUnknown, now, true},
+		{"in progress", task.ChResult, sandbox.RiskLevelUnknown, time.Time{}, false},
+	}
+	for _, tCase := range testCases {
+		tsk := task.NewTask(0, task.FileTask, "file.exe")
+		tsk.Channel = tCase.channel
+		tsk.RiskLevel = tCase.riskLevel
+		tsk.VerdictTime = tCase.verdict
+		tsk.SubmitTime = now
+		if actual := d.Outdated(tsk); actual != tCase.expected {
+			t.Errorf("%s: expected %v, but got %v", tCase.name, tCase.expected, actual)
+		}
+	}
+}
+
+func TestPrefilterMerge(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.SetIgnore([]string{"*.tmp"})
+	list := task.NewList()
+	d := NewPrefilterDispatch(NewBaseDispatcher(conf, task.NewChannels(), list))
+	prefilter := func(name string) *task.Task {
+		t.Helper()
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte("MZ same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(task.FileTask, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		if err := d.ProcessTask(tsk); err != nil {
+			t.Fatal(err)
+		}
+		return tsk
+	}
+	ignored := prefilter("setup.tmp")
+	if ignored.RiskLevel != sandbox.RiskLevelUnsupported {
+		t.Fatalf("%s is not ignored: %v", ignored.Path, ignored.RiskLevel)
+	}
+	first := prefilter("setup.exe")
+	if list.Get(first.Number) != first || first.Channel != task.ChLookup {
+		t.Fatalf("%s is merged into ignored task", first.Path)
+	}
+	second := prefilter("copy.exe")
+	if list.Get(second.Number) != first {
+		t.Errorf("%s is not merged into %s", second.Path, first.Path)
+	}
+	if list.Length() != 2 {
+		t.Errorf("expected 2 tasks, but got %d", list.Length())
+	}
+}
+
+func TestPrefilterMember(t *testing.T) {
+	conf, folder := testConfig(t)
+	list := task.NewList()
+	d := NewPrefilterDispatch(NewBaseDispatcher(conf, task.NewChannels(), list))
+	archive := task.NewTask(0, task.FileTask, filepath.Join(folder, "archive.zip"))
+	path := filepath.Join(folder, "member.exe")
+	if err := os.WriteFile(path, []byte("MZ member"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected, err := task.FileHash(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := list.NewTask(task.FileTask, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inProgress := list.Get(id)
+	hash, err := d.Member(archive, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != expected {
+		t.Errorf("expected %s, but got %q", expected, hash)
+	}
+	if inProgress.SHA256 != "" || inProgress.Channel != task.ChPrefilter {
+		t.Errorf("task in progress is changed: %v %s", inProgress.Channel, inProgress.SHA256)
+	}
+}
+
+func TestPrefilterStopMembers(t *testing.T) {
+	conf, folder := testConfig(t)
+	list := task.NewList()
+	d := NewPrefilterDispatch(NewBaseDispatcher(conf, task.NewChannels(), list))
+	members := filepath.Join(folder, membersFolder)
+	newMember := func(path string, channel task.Channel) *task.Task {
+		t.Helper()
+		id, err := list.NewTask(task.FileTask, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		tsk.SetChannel(channel)
+		return tsk
+	}
+	queued := newMember(filepath.Join(members, "queued.exe"), task.ChResult)
+	busy := newMember(filepath.Join(members, "nested", "busy.exe"), task.ChSubmit)
+	busy.Activate()
+	done := newMember(filepath.Join(members, "done.exe"), task.ChDone)
+	outside := newMember(filepath.Join(folder, "outside.exe"), task.ChResult)
+	if !d.StopMembers(members) {
+		t.Error("busy member is not waited for")
+	}
+	for _, tCase := range []struct {
+		tsk      *task.Task
+		expected task.Channel
+	}{
+		{queued, task.ChCancelled},
+		{busy, task.ChCancelled},
+		{done, task.ChDone},
+		{outside, task.ChResult},
+	} {
+		if channel, _ := tCase.tsk.Status(); channel != tCase.expected {
+			t.Errorf("%s: expected %v, but got %v", tCase.tsk.Path, tCase.expected, channel)
+		}
+	}
+	busy.Deactivate()
+	if d.StopMembers(members) {
+		t.Error("members are busy after they are stopped")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	conf, _ := testConfig(t)
+	conf.SetSandboxes([]config.Sandbox{
+		{Name: "small", Type: config.SandboxMock, Limits: &config.Constraints{MaxSize: 10}},
+		{Name: "large", Type: config.SandboxMock, Limits: &config.Constraints{MaxSize: 100}},
+		{Name: "docs", Type: config.SandboxMock, Limits: &config.Constraints{Extensions: []string{"doc"}}},
+	}, []config.RoutingRule{
+		{Sandbox: "docs", Extensions: []string{"doc"}},
+		{FanOut: []string{"small", "large"}},
+	})
+	d := NewPrefilterDispatch(NewBaseDispatcher(conf, task.NewChannels(), task.NewList()))
+	testCases := []struct {
+		name     string
+		taskType task.TaskType
+		path     string
+		size     int64
+		sandbox  string
+		fanOut   []string
+		reason   string
+	}{
+		{"fan out", task.FileTask, "program.exe", 5, "", []string{"small", "large"}, ""},
+		{"single accepting", task.FileTask, "program.exe", 50, "large", nil, ""},
+		{"none accepting", task.FileTask, "program.exe", 500, "", nil, "large: file size 500 exceeds limit of 100 bytes"},
+		{"url", task.URLTask, "http://www.example.com", 0, "small", nil, ""},
+		{"rule", task.FileTask, "letter.doc", 500, "docs", nil, ""},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			tsk := task.NewTask(0, tCase.taskType, tCase.path)
+			reason, err := d.Route(tsk, tCase.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if reason != tCase.reason {
+				t.Errorf("expected reason \"%s\", but got \"%s\"", tCase.reason, reason)
+			}
+			if tCase.fanOut != nil {
+				var names []string
+				for _, v := range tsk.Verdicts {
+					names = append(names, v.Sandbox)
+				}
+				if !tsk.FanOut() || !slices.Equal(names, tCase.fanOut) {
+					t.Errorf("expected fan out to %v, but got %v", tCase.fanOut, names)
+				}
+				return
+			}
+			if tsk.Sandbox != tCase.sandbox {
+				t.Errorf("expected %s sandbox, but got %s", tCase.sandbox, tsk.Sandbox)
+			}
+		})
+	}
+	t.Run("rechecked", func(t *testing.T) {
+		tsk := task.NewTask(0, task.FileTask, "program.exe")
+		tsk.SetSandbox("large")
+		if reason, err := d.Route(tsk, 5); err != nil || reason != "" || tsk.Sandbox != "large" {
+			t.Errorf("rechecked task changed sandbox: %s, %s, %v", tsk.Sandbox, reason, err)
+		}
+	})
+	t.Run("unknown sandbox", func(t *testing.T) {
+		tsk := task.NewTask(0, task.FileTask, "program.exe")
+		if _, _, err := d.Accepting(tsk, []string{"missing"}, 5); err == nil {
+			t.Error("unknown sandbox is accepting")
+		}
+	})
+}
@@ -0,0 +1,206 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+quota.go
+
+Keep submissions within sandbox daily reserve
+*/
+package dispatchers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type quotaState struct {
+	limited   bool
+	remaining int
+	checked   time.Time
+}
+
+// QuotaManager - tracks remaining submissions of each sandbox. Remaining
+// count is polled every quota_interval, right after daily reset and
+// decreased locally on each submission in between. Sandbox is polled
+// without holding the lock, and only one poll of each sandbox is made at a
+// time: concurrent checks wait for its result
+type QuotaManager struct {
+	mx      sync.Mutex
+	conf    *config.Configuration
+	sandbox func(name string) (sandbox.Sandbox, error)
+	now     func() time.Time
+	states  map[string]*quotaState
+	polls   map[string]chan struct{}
+}
+
+func NewQuotaManager(d BaseDispatcher) *QuotaManager {
+	return &QuotaManager{
+		conf:    d.conf,
+		sandbox: d.SandboxByName,
+		now:     time.Now,
+		states:  make(map[string]*quotaState),
+		polls:   make(map[string]chan struct{}),
+	}
+}
+
+// Allow - check whether task can be submitted to all sandboxes it still has
+// to be submitted to and, if so, count these submissions
+func (q *QuotaManager) Allow(tsk *task.Task) bool {
+	return q.check(tsk, true)
+}
+
+// Available - check quota without counting submissions
+func (q *QuotaManager) Available(tsk *task.Task) bool {
+	return q.check(tsk, false)
+}
+
+// check - bulk tasks need bigger remaining count, so interactive
+// submissions are not starved by folder scans
+func (q *QuotaManager) check(tsk *task.Task, consume bool) bool {
+	names := []string{tsk.Sandbox}
+	if tsk.FanOut() {
+		names = nil
+		for _, v := range tsk.Verdicts {
+			if v.SandboxID == "" {
+				names = append(names, v.Sandbox)
+			}
+		}
+	}
+	margin := q.conf.GetQuotaMargin()
+	if tsk.Bulk() && q.conf.GetQuotaBulkMargin() > margin {
+		margin = q.conf.GetQuotaBulkMargin()
+	}
+	interval := q.conf.GetQuotaInterval()
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	var limited []string
+	for _, name := range names {
+		state := q.state(tsk.Context(), name, interval)
+		if !state.limited {
+			continue
+		}
+		if state.remaining <= margin {
+			logging.Debugf("%s: %s quota: %d remaining, margin %d", tsk.Path, name, state.remaining, margin)
+			return false
+		}
+		limited = append(limited, name)
+	}
+	if !consume {
+		return true
+	}
+	for _, name := range limited {
+		q.states[name].remaining--
+	}
+	return true
+}
+
+// state - remaining count of sandbox. Must be called with q.mx locked.
+// Outdated count is polled, or if poll is already in progress, its result
+// is waited for
+func (q *QuotaManager) state(ctx context.Context, name string, interval time.Duration) *quotaState {
+	now := q.now()
+	state, ok := q.states[name]
+	if ok && now.Sub(state.checked) < interval && sameDay(now, state.checked) {
+		return state
+	}
+	done, polling := q.polls[name]
+	if !polling {
+		return q.poll(ctx, name)
+	}
+	q.mx.Unlock()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+	q.mx.Lock()
+	if state, ok := q.states[name]; ok {
+		return state
+	}
+	return &quotaState{checked: now}
+}
+
+// poll - request remaining count of sandbox with q.mx unlocked and swap
+// new state in. Must be called with q.mx locked
+func (q *QuotaManager) poll(ctx context.Context, name string) *quotaState {
+	done := make(chan struct{})
+	q.polls[name] = done
+	q.mx.Unlock()
+	state := q.request(ctx, name)
+	q.mx.Lock()
+	q.states[name] = state
+	delete(q.polls, name)
+	close(done)
+	return state
+}
+
+// request - remaining count of sandbox. Sandbox that does not report quota
+// or failed to report it is considered unlimited till next poll
+func (q *QuotaManager) request(ctx context.Context, name string) *quotaState {
+	state := &quotaState{checked: q.now()}
+	sb, err := q.sandbox(name)
+	if err != nil {
+		logging.LogError(err)
+		return state
+	}
+	quota, ok := sb.(sandbox.Quota)
+	if !ok {
+		return state
+	}
+	remaining, err := quota.Quota(ctx)
+	if err != nil {
+		logging.Errorf("%s quota: %v", name, err)
+		return state
+	}
+	logging.Debugf("%s quota: %d remaining", name, remaining)
+	state.limited = true
+	state.remaining = remaining
+	return state
+}
+
+// Run - poll sandboxes that were checked before every quota_interval, so
+// uploads rarely wait for poll, till stop is closed
+func (q *QuotaManager) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+	timer := time.NewTimer(q.conf.GetQuotaInterval())
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+			q.Refresh(ctx)
+			timer.Reset(q.conf.GetQuotaInterval())
+		}
+	}
+}
+
+// Refresh - poll all known sandboxes which are not being polled already
+func (q *QuotaManager) Refresh(ctx context.Context) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	names := make([]string, 0, len(q.states))
+	for name := range q.states {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if _, polling := q.polls[name]; !polling {
+			q.poll(ctx, name)
+		}
+	}
+}
+
+// sameDay - daily reserve is reset at midnight UTC
+func sameDay(a, b time.Time) bool {
+	return a.UTC().Truncate(24 * time.Hour).Equal(b.UTC().Truncate(24 * time.Hour))
+}
@@ -0,0 +1,44 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+quota_dispatch.go
+
+Hold tasks till sandbox quota is available
+*/
+package dispatchers
+
+import (
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+type QuotaDispatch struct {
+	BaseDispatcher
+	quota *QuotaManager
+}
+
+func NewQuotaDispatch(d BaseDispatcher, quota *QuotaManager) *QuotaDispatch {
+	return &QuotaDispatch{
+		BaseDispatcher: d,
+		quota:          quota,
+	}
+}
+
+func (*QuotaDispatch) InboundChannel() task.Channel {
+	return task.ChQuota
+}
+
+// ProcessTask - return task to submission when quota is available again,
+// for example after daily reset
+func (d *QuotaDispatch) ProcessTask(tsk *task.Task) error {
+	if d.quota.Available(tsk) {
+		logging.Infof("%s: quota is available", tsk.Path)
+		tsk.SetChannel(task.ChSubmit)
+		d.list.Updated()
+		return nil
+	}
+	tsk.Postpone(d.conf.GetSleep())
+	tsk.SetChannel(task.ChQuota)
+	return nil
+}
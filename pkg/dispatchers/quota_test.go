@@ -0,0 +1,154 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+quota_test.go
+
+Test quota manager against sandbox with daily reserve
+*/
+package dispatchers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+type quotaSandbox struct {
+	sandbox.Sandbox
+	remaining int
+	polls     int
+	release   chan struct{}
+}
+
+func (s *quotaSandbox) Quota(ctx context.Context) (int, error) {
+	s.polls++
+	if s.release != nil {
+		<-s.release
+	}
+	return s.remaining, nil
+}
+
+func TestQuotaManager(t *testing.T) {
+	conf := config.New("")
+	conf.SetQuotaMargin(2)
+	conf.SetQuotaBulkMargin(4)
+	conf.SetQuotaInterval(3 * time.Hour)
+	sb := &quotaSandbox{remaining: 6}
+	now := time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)
+	q := &QuotaManager{
+		conf:    conf,
+		sandbox: func(string) (sandbox.Sandbox, error) { return sb, nil },
+		now:     func() time.Time { return now },
+		states:  make(map[string]*quotaState),
+		polls:   make(map[string]chan struct{}),
+	}
+	interactive := task.NewTask(0, task.FileTask, "interactive.exe")
+	bulk := task.NewTask(1, task.FileTask, "bulk.exe")
+	bulk.SetPriority(task.PriorityFolderScan)
+	if !q.Allow(bulk) || !q.Allow(bulk) {
+		t.Error("bulk task is not allowed above bulk margin")
+	}
+	if q.Allow(bulk) {
+		t.Error("bulk task is allowed within bulk margin")
+	}
+	if !q.Available(interactive) || !q.Allow(interactive) || !q.Allow(interactive) {
+		t.Error("interactive task is not allowed above margin")
+	}
+	if q.Available(interactive) || q.Allow(interactive) {
+		t.Error("interactive task is allowed within margin")
+	}
+	if sb.polls != 1 {
+		t.Errorf("expected 1 poll, got %d", sb.polls)
+	}
+	t.Run("interval", func(t *testing.T) {
+		sb.remaining = 2
+		now = now.Add(3 * time.Hour)
+		if q.Available(interactive) {
+			t.Error("quota is available before reset")
+		}
+		if sb.polls != 2 {
+			t.Errorf("expected 2 polls, got %d", sb.polls)
+		}
+	})
+	t.Run("daily reset", func(t *testing.T) {
+		sb.remaining = 6
+		now = now.Add(90 * time.Minute)
+		if !q.Available(bulk) {
+			t.Error("quota is not available after reset")
+		}
+		if sb.polls != 3 {
+			t.Errorf("expected 3 polls, got %d", sb.polls)
+		}
+	})
+}
+
+func TestQuotaManagerPoll(t *testing.T) {
+	conf := config.New("")
+	conf.SetQuotaMargin(2)
+	conf.SetQuotaInterval(time.Hour)
+	slow := &quotaSandbox{remaining: 10, release: make(chan struct{})}
+	fast := &quotaSandbox{remaining: 10}
+	q := &QuotaManager{
+		conf: conf,
+		sandbox: func(name string) (sandbox.Sandbox, error) {
+			if name == "slow" {
+				return slow, nil
+			}
+			return fast, nil
+		},
+		now:    time.Now,
+		states: make(map[string]*quotaState),
+		polls:  make(map[string]chan struct{}),
+	}
+	newTask := func(id task.ID, name string) *task.Task {
+		tsk := task.NewTask(id, task.FileTask, name+".exe")
+		tsk.Sandbox = name
+		return tsk
+	}
+	const waiting = 3
+	results := make(chan bool, waiting)
+	for i := 0; i < waiting; i++ {
+		go func(id task.ID) {
+			results <- q.Allow(newTask(id, "slow"))
+		}(task.ID(i))
+	}
+	for {
+		q.mx.Lock()
+		_, polling := q.polls["slow"]
+		q.mx.Unlock()
+		if polling {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if !q.Allow(newTask(waiting, "fast")) {
+		t.Error("fast sandbox is not allowed while slow one is polled")
+	}
+	close(slow.release)
+	for i := 0; i < waiting; i++ {
+		if !<-results {
+			t.Error("slow sandbox is not allowed")
+		}
+	}
+	if slow.polls != 1 {
+		t.Errorf("expected 1 poll, got %d", slow.polls)
+	}
+	if remaining := q.states["slow"].remaining; remaining != 10-waiting {
+		t.Errorf("expected %d remaining, got %d", 10-waiting, remaining)
+	}
+	t.Run("refresh", func(t *testing.T) {
+		fast.remaining = 5
+		q.Refresh(context.TODO())
+		if slow.polls != 2 || fast.polls != 2 {
+			t.Errorf("expected 2 polls, got %d and %d", slow.polls, fast.polls)
+		}
+		if remaining := q.states["fast"].remaining; remaining != 5 {
+			t.Errorf("expected 5 remaining, got %d", remaining)
+		}
+	})
+}
@@ -27,7 +27,7 @@ func (d *ReportDispatch) InboundChannel() task.Channel {
 }
 
 func (d *ReportDispatch) ProcessTask(tsk *task.Task) error {
-	sbox, err := d.Sandbox()
+	sbox, err := d.Sandbox(tsk)
 	if err != nil {
 		return err
 	}
@@ -35,7 +35,7 @@ func (d *ReportDispatch) ProcessTask(tsk *task.Task) error {
 	if err != nil {
 		return err
 	}
-	if err := sbox.GetReport(tsk.SandboxID, filePath); err != nil {
+	if err := sbox.GetReport(tsk.Context(), tsk.SandboxID, filePath); err != nil {
 		return err
 	}
 	tsk.SetReport(filePath)
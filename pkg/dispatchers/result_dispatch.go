@@ -12,21 +12,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sandboxer/pkg/config"
 	"sandboxer/pkg/globals"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/sandbox"
 	"sandboxer/pkg/task"
 	"sandboxer/pkg/xplatform"
-	"time"
 )
 
 type ResultDispatch struct {
 	BaseDispatcher
+	poller  *Poller
+	batcher *Batcher
 }
 
-func NewResultDispatch(d BaseDispatcher) *ResultDispatch {
+func NewResultDispatch(d BaseDispatcher, poller *Poller, batcher *Batcher) *ResultDispatch {
 	return &ResultDispatch{
 		BaseDispatcher: d,
+		poller:         poller,
+		batcher:        batcher,
 	}
 }
 
@@ -35,20 +39,22 @@ func (d *ResultDispatch) InboundChannel() task.Channel {
 }
 
 func (d *ResultDispatch) ProcessTask(tsk *task.Task) error {
-	sb, err := d.Sandbox()
+	if tsk.FanOut() {
+		return d.FanOut(tsk)
+	}
+	sb, err := d.Sandbox(tsk)
 	if err != nil {
 		return err
 	}
-	riskLevel, threatName, err := sb.GetResult(tsk.SandboxID)
+	riskLevel, threatName, err := d.batcher.GetResult(tsk.Context(), tsk.Sandbox, sb, tsk.SandboxID)
 	logging.Debugf("GetResut: %v (%d), %s [%v]", riskLevel, riskLevel, threatName, err)
+	if tsk.Context().Err() != nil {
+		return err
+	}
 	tsk.SetRiskLevel(riskLevel)
 	switch riskLevel {
 	case sandbox.RiskLevelNotReady:
-		tsk.Deactivate()
-		d.list.Updated()
-		logging.Debugf("Seleep %v for %v", d.conf.GetSleep(), tsk)
-		time.Sleep(d.conf.GetSleep())
-		tsk.SetChannel(task.ChResult)
+		d.Wait(tsk)
 	case sandbox.RiskLevelUnsupported:
 		if err != nil {
 			tsk.SetMessage(err.Error())
@@ -60,16 +66,101 @@ func (d *ResultDispatch) ProcessTask(tsk *task.Task) error {
 		//	case sandbox.RiskLevelError:
 		//		return err
 	default:
-		tsk.SetMessage(threatName)
-		tsk.SetChannel(task.ChReport)
-		if d.conf.GetShowNotifications() && tsk.RiskLevel.IsThreat() {
-			subtitle := fmt.Sprintf("%v threat found %s", tsk.RiskLevel, threatName)
-			d.Alert(subtitle, filepath.Base(tsk.Path))
-		}
+		d.Found(tsk, threatName)
 	}
 	return err
 }
 
+// FanOut - get results from all sandboxes task is submitted to and
+// choose final verdict when all of them are done. Transient error of one
+// sandbox is returned to be retried, while its retry attempts last.
+// After that its verdict becomes error
+func (d *ResultDispatch) FanOut(tsk *task.Task) error {
+	ready := true
+	var transient error
+	for i := range tsk.Verdicts {
+		v := tsk.Verdicts[i]
+		if v.Final() {
+			continue
+		}
+		sb, err := d.SandboxByName(v.Sandbox)
+		if err != nil {
+			return err
+		}
+		riskLevel, threatName, err := d.batcher.GetResult(tsk.Context(), v.Sandbox, sb, v.SandboxID)
+		logging.Debugf("GetResut from %s: %v (%d), %s [%v]", v.Sandbox, riskLevel, riskLevel, threatName, err)
+		if tsk.Context().Err() != nil {
+			return err
+		}
+		if err != nil {
+			if sandbox.IsTransient(err) && tsk.Retries < d.conf.Retry.GetResult().Attempts {
+				transient = err
+				continue
+			}
+			v.RiskLevel = sandbox.RiskLevelError
+			v.Message = err.Error()
+			tsk.SetVerdict(i, v)
+			continue
+		}
+		v.RiskLevel = riskLevel
+		v.Message = threatName
+		if riskLevel == sandbox.RiskLevelUnsupported && threatName == "" {
+			v.Message = "Unsupported file type"
+		}
+		tsk.SetVerdict(i, v)
+		if !v.Final() {
+			ready = false
+		}
+	}
+	if transient != nil {
+		return transient
+	}
+	if !ready {
+		d.Wait(tsk)
+		return nil
+	}
+	verdict := Consensus(d.conf.GetConsensus(), tsk.Verdicts)
+	logging.Debugf("%s: %v consensus: %v by %s", tsk.Path, d.conf.GetConsensus(), verdict.RiskLevel, verdict.Sandbox)
+	tsk.SetSandbox(verdict.Sandbox)
+	tsk.SetSandboxID(verdict.SandboxID)
+	tsk.SetRiskLevel(verdict.RiskLevel)
+	if verdict.Conclusive() {
+		d.Found(tsk, verdict.Message)
+		return nil
+	}
+	if verdict.RiskLevel == sandbox.RiskLevelUnsupported {
+		tsk.SetMessage(verdict.Message)
+		tsk.SetChannel(task.ChDone)
+		return nil
+	}
+	return fmt.Errorf("%s: %s", verdict.Sandbox, verdict.Message)
+}
+
+// Wait - check task result later. Launcher holds task till then, so
+// dispatcher is free to process other tasks
+func (d *ResultDispatch) Wait(tsk *task.Task) {
+	delay := d.poller.Delay(tsk)
+	tsk.CountPoll()
+	logging.Debugf("Check %v again in %v", tsk, delay)
+	tsk.Postpone(delay)
+	tsk.SetChannel(task.ChResult)
+}
+
+// Found - task has verdict, so report can be downloaded. Files of watched
+// folders with notify action get notification for any verdict
+func (d *ResultDispatch) Found(tsk *task.Task, threatName string) {
+	tsk.SetMessage(threatName)
+	tsk.SetChannel(task.ChReport)
+	if d.conf.GetShowNotifications() && tsk.RiskLevel.IsThreat() {
+		subtitle := fmt.Sprintf("%v threat found %s", tsk.RiskLevel, threatName)
+		d.Alert(subtitle, filepath.Base(tsk.Path))
+		return
+	}
+	if folder, ok := d.conf.Watch.Folder(tsk.Path); ok && folder.Action == config.WatchNotify {
+		d.Alert(fmt.Sprintf("Verdict: %v", tsk.RiskLevel), filepath.Base(tsk.Path))
+	}
+}
+
 func (d *ResultDispatch) Alert(subtitle, message string) {
 	iconPath := d.conf.Resource("icon_transparent.png")
 	_, err := os.Stat(iconPath)
@@ -0,0 +1,69 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+retry.go
+
+Repeat stages failed with transient errors
+*/
+package dispatchers
+
+import (
+	"math/rand"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+// RetryPolicy - retry policy for given processing stage. Stages
+// without policy are not repeated
+func (l *Launcher) RetryPolicy(ch task.Channel) config.RetryPolicy {
+	retry := l.conf.Retry
+	switch ch {
+	case task.ChSubmit:
+		return retry.GetUpload()
+	case task.ChResult:
+		return retry.GetResult()
+	case task.ChReport:
+		return retry.GetReport()
+	case task.ChInvestigation:
+		return retry.GetInvestigation()
+	}
+	return config.RetryPolicy{}
+}
+
+// Retry - schedule stage to be repeated after transient error. Returns false
+// if error is permanent or all attempts are used, so task should fail
+func (l *Launcher) Retry(tsk *task.Task, ch task.Channel, err error) bool {
+	policy := l.RetryPolicy(ch)
+	if !sandbox.IsTransient(err) || tsk.Retries >= policy.Attempts {
+		return false
+	}
+	tsk.SetRetry(err)
+	delay := Backoff(policy, tsk.Retries)
+	logging.Infof("Task #%d: retry %d of %d in %v: %v", tsk.Number, tsk.Retries, policy.Attempts, delay, err)
+	tsk.SetChannel(ch)
+	l.Schedule(tsk, delay)
+	return true
+}
+
+// Backoff - delay before given retry (counting from one). It grows
+// exponentially and has random jitter of up to half of its value, so
+// tasks failed together are not repeated at once
+func Backoff(policy config.RetryPolicy, retry int) time.Duration {
+	delay := policy.Backoff
+	for i := 1; i < retry && (policy.MaxBackoff <= 0 || delay < policy.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return delay - half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,119 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+retry_test.go
+
+Test retry of stages failed with transient errors
+*/
+package dispatchers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/sandbox"
+	"sandboxer/pkg/task"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := config.RetryPolicy{Attempts: 10, Backoff: time.Second, MaxBackoff: 10 * time.Second}
+	testCases := []struct {
+		retry    int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{10, 10 * time.Second},
+	}
+	for _, tCase := range testCases {
+		delay := Backoff(policy, tCase.retry)
+		if delay < tCase.expected/2 || delay > tCase.expected {
+			t.Errorf("retry %d: expected %v with jitter, got %v", tCase.retry, tCase.expected, delay)
+		}
+	}
+}
+
+func TestLauncherRetry(t *testing.T) {
+	conf, folder := testConfig(t)
+	conf.Mock.Rules = []config.MockRule{
+		{Pattern: "*.flaky", Verdict: "No Risk", Fail: "submit", Transient: true},
+		{Pattern: "*.fail", Verdict: "No Risk", Fail: "submit"},
+	}
+	conf.Retry.SetUpload(config.RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	submit := func(name string) *task.Task {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(task.FileTask, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+		return list.Get(id)
+	}
+	flaky := submit("file.flaky")
+	failed := submit("file.fail")
+	waitChannel(t, flaky, task.ChDone)
+	waitChannel(t, failed, task.ChDone)
+	if flaky.RiskLevel != sandbox.RiskLevelError || flaky.Retries != 2 || flaky.LastError == "" {
+		t.Errorf("transient error: expected 2 retries, got %d: %v", flaky.Retries, flaky)
+	}
+	if failed.RiskLevel != sandbox.RiskLevelError || failed.Retries != 0 {
+		t.Errorf("permanent error: expected no retries, got %d: %v", failed.Retries, failed)
+	}
+}
+
+func TestLauncherFanOutRetry(t *testing.T) {
+	conf, folder := testConfig(t)
+	flaky := config.NewDefaultMock()
+	flaky.SetLatency(0)
+	flaky.SetAnalysisTime(0)
+	flaky.Rules = []config.MockRule{
+		{Pattern: "*.exe", Verdict: "High Risk", Fail: "result", Transient: true},
+	}
+	conf.SetSandboxes([]config.Sandbox{
+		{Name: "default", Type: config.SandboxMock},
+		{Name: "flaky", Type: config.SandboxMock, Mock: flaky},
+	}, []config.RoutingRule{
+		{FanOut: []string{"default", "flaky"}},
+	})
+	conf.Retry.SetResult(config.RetryPolicy{Attempts: 2, Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond})
+	channels := task.NewChannels()
+	list := task.NewList()
+	launcher := NewLauncher(conf, channels, list)
+	launcher.Run()
+	defer launcher.Stop()
+	path := filepath.Join(folder, "program.exe")
+	if err := os.WriteFile(path, []byte("MZ"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id, err := list.NewTask(task.FileTask, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channels.Push(task.ChPrefilter, id, task.PriorityInteractive)
+	tsk := list.Get(id)
+	waitChannel(t, tsk, task.ChDone)
+	if tsk.LastError == "" {
+		t.Errorf("transient error is not retried: %v", tsk)
+	}
+	if tsk.RiskLevel != sandbox.RiskLevelNoRisk || tsk.Sandbox != "default" {
+		t.Errorf("expected %v by default, but got %v by %s (%s)", sandbox.RiskLevelNoRisk, tsk.RiskLevel, tsk.Sandbox, tsk.Message)
+	}
+	if len(tsk.Verdicts) != 2 || tsk.Verdicts[1].RiskLevel != sandbox.RiskLevelError {
+		t.Errorf("expected error verdict of flaky sandbox, but got %v", tsk.Verdicts)
+	}
+}
@@ -4,82 +4,149 @@ Software is distributed under MIT license as stated in LICENSE file
 
 submit_dispatch.go
 
-Send files from FIFO to prefilter channel
+Serve IPC requests: send submitted objects to prefilter channel, report tasks status
 */
 package dispatchers
 
 import (
-	"log"
-	"sync"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
-	"sandboxer/pkg/fifo"
+	"sandboxer/pkg/ipc"
 	"sandboxer/pkg/logging"
 	"sandboxer/pkg/task"
 )
 
-const StopPath = "STOP"
-
 type SubmitDispatch struct {
 	BaseDispatcher
+	shutdown func()
 }
 
-func NewSubmitDispatch(b BaseDispatcher) *SubmitDispatch {
-	return &SubmitDispatch{b}
+func NewSubmitDispatch(b BaseDispatcher, shutdown func()) *SubmitDispatch {
+	return &SubmitDispatch{b, shutdown}
 }
 
-func (d *SubmitDispatch) Run(wg *sync.WaitGroup) {
-	logging.Debugf("Start %T", d)
-	for {
-		fifoReader, err := fifo.NewReader()
-		if err != nil {
-			log.Fatal(err)
-		}
-		var s string
-		if err := fifoReader.Read(&s); err != nil {
-			if err.Error() != "EOF" {
-				logging.Errorf("read FIFO: %v", err)
-			}
-			continue
-		}
-		fifoReader.Close()
-		logging.Infof("Got new path: %s", s)
-		if s == StopPath {
-			break
-		}
-		//		logging.Debugf("SEND %s to %d", s, ChPrefilter)
-		tsk, err := d.list.NewTask(task.FileTask, s)
-		if err != nil {
-			//if !errors.Is(err, task.ErrAlreadyExists) {
-			logging.LogError(err)
-			//}
-			continue
-		}
-		d.Channel(task.ChPrefilter) <- tsk
-		//d.list.Updated()
+func (d *SubmitDispatch) Handle(request *ipc.Request) ipc.Response {
+	switch request.Type {
+	case ipc.MessageSubmitFile:
+		return d.SubmitFile(request.Path, request.Priority)
+	case ipc.MessageSubmitURL:
+		return d.SubmitURL(request.URL, request.Priority)
+	case ipc.MessageQueryStatus:
+		return d.QueryStatus(request.TaskID)
+	case ipc.MessageListTasks:
+		return d.ListTasks()
+	case ipc.MessageCancel:
+		return d.Cancel(request.TaskID)
+	case ipc.MessagePause:
+		return d.Pause(request.TaskID)
+	case ipc.MessageResume:
+		return d.Resume(request.TaskID)
+	case ipc.MessageShutdown:
+		logging.Infof("Got shutdown request")
+		d.shutdown()
+		return ipc.Response{}
 	}
-	wg.Done()
-	logging.Debugf("Stop SubmitDispatch")
+	return ipc.NewErrorResponse(fmt.Errorf("%w: unknown message type \"%s\"", ipc.ErrBadRequest, request.Type))
 }
 
-/*
-type StringChannel chan string
+func (d *SubmitDispatch) SubmitFile(path string, priority task.Priority) ipc.Response {
+	logging.Infof("Got new path: %s", path)
+	if path == "" {
+		return ipc.NewErrorResponse(fmt.Errorf("%w: path is empty", ipc.ErrBadRequest))
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return ipc.NewErrorResponse(fmt.Errorf("%w: %v", ipc.ErrBadRequest, err))
+	}
+	if _, err := os.Stat(path); err != nil {
+		return ipc.NewErrorResponse(fmt.Errorf("%w: %v", ipc.ErrBadRequest, err))
+	}
+	return d.Submit(task.FileTask, path, priority)
+}
+
+func (d *SubmitDispatch) SubmitURL(url string, priority task.Priority) ipc.Response {
+	logging.Infof("Got new URL: %s", url)
+	url = strings.TrimSpace(url)
+	if url == "" {
+		return ipc.NewErrorResponse(fmt.Errorf("%w: URL is empty", ipc.ErrBadRequest))
+	}
+	return d.Submit(task.URLTask, url, priority)
+}
 
-func SubmitDispatchFunc(ch StringChannel) {
-	for {
-		fifoReader, err := fifo.NewReader()
-		if err != nil {
-			log.Fatal(err)
+// Submit - create new task with given priority. If task for this path
+// already exists, it is returned along with "already exists" error
+func (d *SubmitDispatch) Submit(taskType task.TaskType, path string, priority task.Priority) ipc.Response {
+	id, err := d.list.NewTask(taskType, path)
+	if err != nil {
+		logging.LogError(err)
+		response := ipc.NewErrorResponse(err)
+		if tsk := d.list.FindTask(path); tsk != nil {
+			response.Task = &ipc.TaskInfo{ID: tsk.Number, Task: tsk}
 		}
-		var s string
-		if err := fifoReader.Read(&s); err != nil {
-			if err.Error() != "EOF" {
-				logging.Errorf("read FIFO: %v", err)
+		return response
+	}
+	d.list.Get(id).SetPriority(priority)
+	d.Push(task.ChPrefilter, id, priority)
+	return ipc.Response{Task: &ipc.TaskInfo{ID: id, Task: d.list.Get(id)}}
+}
+
+func (d *SubmitDispatch) QueryStatus(id task.ID) ipc.Response {
+	tsk := d.list.Get(id)
+	if tsk == nil {
+		return ipc.NewErrorResponse(fmt.Errorf("task #%d: %w", id, ipc.ErrNotFound))
+	}
+	return ipc.Response{Task: &ipc.TaskInfo{ID: id, Task: tsk}}
+}
+
+func (d *SubmitDispatch) ListTasks() ipc.Response {
+	var response ipc.Response
+	d.list.Process(func(ids []task.ID) {
+		for _, id := range ids {
+			tsk := d.list.Get(id)
+			if tsk == nil {
+				continue
 			}
-			continue
+			response.Tasks = append(response.Tasks, ipc.TaskInfo{ID: id, Task: tsk})
 		}
-		fifoReader.Close()
-		logging.Infof("Got new path: %s", s)
-		ch <- s
+	})
+	return response
+}
+
+// Cancel - stop task that is not finished yet. Task is kept with
+// "Cancelled" state
+func (d *SubmitDispatch) Cancel(id task.ID) ipc.Response {
+	logging.Infof("Cancel task #%d", id)
+	return d.Control(id, d.list.Cancel)
+}
+
+// Pause - stop task till it is resumed
+func (d *SubmitDispatch) Pause(id task.ID) ipc.Response {
+	logging.Infof("Pause task #%d", id)
+	return d.Control(id, d.list.Pause)
+}
+
+// Resume - continue paused task
+func (d *SubmitDispatch) Resume(id task.ID) ipc.Response {
+	logging.Infof("Resume task #%d", id)
+	return d.Control(id, func(id task.ID) error {
+		return d.list.Resume(id, d.channels)
+	})
+}
+
+func (d *SubmitDispatch) Control(id task.ID, action func(id task.ID) error) ipc.Response {
+	tsk := d.list.Get(id)
+	if tsk == nil {
+		return ipc.NewErrorResponse(fmt.Errorf("task #%d: %w", id, ipc.ErrNotFound))
 	}
+	if err := action(id); err != nil {
+		if errors.Is(err, task.ErrWrongState) {
+			err = fmt.Errorf("%w: %v", ipc.ErrBadRequest, err)
+		}
+		return ipc.NewErrorResponse(err)
+	}
+	return ipc.Response{Task: &ipc.TaskInfo{ID: id, Task: tsk}}
 }
-*/
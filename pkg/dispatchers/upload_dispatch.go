@@ -9,41 +9,95 @@ Upload file for inspection
 package dispatchers
 
 import (
+	"time"
+
 	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
 	"sandboxer/pkg/task"
 )
 
 type UploadDispatch struct {
 	BaseDispatcher
+	quota *QuotaManager
 }
 
 func (*UploadDispatch) InboundChannel() task.Channel {
 	return task.ChSubmit
 }
 
-func NewUploadDispatch(d BaseDispatcher) *UploadDispatch {
+func NewUploadDispatch(d BaseDispatcher, quota *QuotaManager) *UploadDispatch {
 	return &UploadDispatch{
 		BaseDispatcher: d,
+		quota:          quota,
 	}
 }
 
 func (d *UploadDispatch) ProcessTask(tsk *task.Task) error {
-	sb, err := d.Sandbox()
-	if err != nil {
-		return err
+	if !d.quota.Allow(tsk) {
+		logging.Infof("%s: waiting for quota", tsk.Path)
+		tsk.SetChannel(task.ChQuota)
+		d.list.Updated()
+		return nil
 	}
-	var id string
-	if tsk.Type == task.URLTask {
-		id, err = sb.SubmitURL(tsk.Path)
-	} else {
-		id, err = sb.SubmitFile(tsk.Path)
+	if tsk.FanOut() {
+		return d.FanOut(tsk)
 	}
+	id, err := d.Submit(tsk.Sandbox, tsk)
 	if err != nil {
 		return err
 	}
 	tsk.SetSandboxID(id)
+	tsk.SetUploaded(time.Now())
 	logging.Infof("Accepted: %v", id)
 	tsk.SetChannel(task.ChResult)
 	d.list.Updated()
 	return nil
 }
+
+// FanOut - submit task to all its sandboxes. Task fails only if
+// none of the sandboxes has accepted it
+func (d *UploadDispatch) FanOut(tsk *task.Task) error {
+	var lastErr error
+	accepted := 0
+	for i := range tsk.Verdicts {
+		v := tsk.Verdicts[i]
+		if v.SandboxID != "" {
+			accepted++
+			continue
+		}
+		id, err := d.Submit(v.Sandbox, tsk)
+		if tsk.Context().Err() != nil {
+			return err
+		}
+		if err != nil {
+			logging.Errorf("%s: %s: %v", v.Sandbox, tsk.Path, err)
+			v.RiskLevel = sandbox.RiskLevelError
+			v.Message = err.Error()
+			tsk.SetVerdict(i, v)
+			lastErr = err
+			continue
+		}
+		logging.Infof("Accepted by %s: %v", v.Sandbox, id)
+		v.SandboxID = id
+		tsk.SetVerdict(i, v)
+		accepted++
+	}
+	if accepted == 0 {
+		return lastErr
+	}
+	tsk.SetUploaded(time.Now())
+	tsk.SetChannel(task.ChResult)
+	d.list.Updated()
+	return nil
+}
+
+func (d *UploadDispatch) Submit(sandboxName string, tsk *task.Task) (string, error) {
+	sb, err := d.SandboxByName(sandboxName)
+	if err != nil {
+		return "", err
+	}
+	if tsk.Type == task.URLTask {
+		return sb.SubmitURL(tsk.Context(), tsk.Path)
+	}
+	return sb.SubmitFile(tsk.Context(), tsk.Path)
+}
@@ -0,0 +1,290 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+watcher.go
+
+Submit new and changed files of watched folders
+*/
+package dispatchers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"sandboxer/pkg/config"
+	"sandboxer/pkg/ignore"
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+// change - last change of file that is not submitted yet
+type change struct {
+	changed time.Time
+	timer   *time.Timer
+}
+
+// Watcher - submits files that are created or changed in watched folders.
+// File is submitted once it is not changed for settle time, so files that
+// are being downloaded or saved are not analyzed half written
+type Watcher struct {
+	mx       sync.Mutex
+	conf     *config.Configuration
+	channels *task.Channels
+	list     *task.TaskList
+	fs       *fsnotify.Watcher
+	pending  map[string]*change
+	stopped  bool
+	wg       sync.WaitGroup
+}
+
+func NewWatcher(conf *config.Configuration, channels *task.Channels, list *task.TaskList) *Watcher {
+	return &Watcher{
+		conf:     conf,
+		channels: channels,
+		list:     list,
+		pending:  make(map[string]*change),
+	}
+}
+
+// Start - watch configured folders. Nothing is done if watching is disabled
+func (w *Watcher) Start() error {
+	if !w.conf.Watch.GetEnabled() {
+		return nil
+	}
+	fs, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.fs = fs
+	for _, folder := range w.conf.Watch.GetFolders() {
+		logging.Infof("Watch %s (recursive: %v, action: %s)", folder.Path, folder.Recursive, folder.Action)
+		if err := w.AddFolder(folder); err != nil {
+			logging.Errorf("Watch %s: %v", folder.Path, err)
+		}
+	}
+	w.wg.Add(1)
+	go w.run()
+	return nil
+}
+
+// Stop - stop watching. Files that have not settled yet are not submitted
+func (w *Watcher) Stop() error {
+	if w.fs == nil {
+		return nil
+	}
+	w.mx.Lock()
+	w.stopped = true
+	for path, c := range w.pending {
+		c.timer.Stop()
+		delete(w.pending, path)
+	}
+	w.mx.Unlock()
+	err := w.fs.Close()
+	w.wg.Wait()
+	return err
+}
+
+// AddFolder - watch folder. Recursive folder is watched with all its
+// subfolders that are not ignored
+func (w *Watcher) AddFolder(folder config.WatchedFolder) error {
+	if !folder.Recursive {
+		return w.fs.Add(folder.Path)
+	}
+	return w.addTree(folder.Path, folder.Path, false)
+}
+
+// addTree - watch dir of root folder and its subfolders. Files found are
+// scheduled for submission if schedule is set, as they appear along with
+// new dir
+func (w *Watcher) addTree(root, dir string, schedule bool) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root {
+			if reason := w.ignored(root, path, info); reason != "" {
+				logging.Debugf("%s: %s", path, reason)
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if info.IsDir() {
+			return w.fs.Add(path)
+		}
+		if schedule {
+			w.schedule(path)
+		}
+		return nil
+	})
+}
+
+func (w *Watcher) run() {
+	defer w.wg.Done()
+	for {
+		select {
+		case event, ok := <-w.fs.Events:
+			if !ok {
+				return
+			}
+			w.Handle(event)
+		case err, ok := <-w.fs.Errors:
+			if !ok {
+				return
+			}
+			logging.Errorf("Watch: %v", err)
+		}
+	}
+}
+
+// Handle - postpone submission of created or written file till it settles.
+// New subfolders of recursive folders are watched as well
+func (w *Watcher) Handle(event fsnotify.Event) {
+	path := event.Name
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		w.cancel(path)
+		return
+	}
+	if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+		return
+	}
+	folder, ok := w.conf.Watch.Folder(path)
+	if !ok {
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return
+	}
+	if !info.IsDir() {
+		w.schedule(path)
+		return
+	}
+	if !folder.Recursive || !event.Has(fsnotify.Create) {
+		return
+	}
+	if reason := w.ignored(folder.Path, path, info); reason != "" {
+		logging.Debugf("%s: %s", path, reason)
+		return
+	}
+	if err := w.addTree(folder.Path, path, true); err != nil {
+		logging.Errorf("Watch %s: %v", path, err)
+	}
+}
+
+// schedule - submit file after settle time since its last change
+func (w *Watcher) schedule(path string) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if w.stopped {
+		return
+	}
+	if c, ok := w.pending[path]; ok {
+		c.changed = time.Now()
+		return
+	}
+	w.pending[path] = &change{
+		changed: time.Now(),
+		timer:   time.AfterFunc(w.conf.Watch.GetSettle(), func() { w.settle(path) }),
+	}
+}
+
+// settle - submit file if it was not changed for settle time or wait more
+func (w *Watcher) settle(path string) {
+	w.mx.Lock()
+	c, ok := w.pending[path]
+	if !ok || w.stopped {
+		w.mx.Unlock()
+		return
+	}
+	if wait := w.conf.Watch.GetSettle() - time.Since(c.changed); wait > 0 {
+		c.timer = time.AfterFunc(wait, func() { w.settle(path) })
+		w.mx.Unlock()
+		return
+	}
+	delete(w.pending, path)
+	w.wg.Add(1)
+	w.mx.Unlock()
+	defer w.wg.Done()
+	w.Submit(path)
+}
+
+// cancel - forget file that is removed or renamed before it settled
+func (w *Watcher) cancel(path string) {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+	if c, ok := w.pending[path]; ok {
+		c.timer.Stop()
+		delete(w.pending, path)
+	}
+}
+
+// Submit - create task for settled file. If file already has task, it
+// is analyzed again when its content is changed
+func (w *Watcher) Submit(path string) {
+	folder, ok := w.conf.Watch.Folder(path)
+	if !ok {
+		return
+	}
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() || info.Name() == ignore.FileName {
+		return
+	}
+	if reason := w.ignored(folder.Path, path, info); reason != "" {
+		logging.Debugf("%s: %s", path, reason)
+		return
+	}
+	id, err := w.list.NewTask(task.FileTask, path)
+	if errors.Is(err, task.ErrAlreadyExists) {
+		w.Changed(path)
+		return
+	}
+	if err != nil {
+		logging.LogError(err)
+		return
+	}
+	logging.Infof("Watched folder %s: submit %s", folder.Path, path)
+	w.list.Get(id).SetPriority(task.PriorityBackground)
+	w.channels.Push(task.ChPrefilter, id, task.PriorityBackground)
+}
+
+// Changed - analyze again file which content is changed after its task was
+// finished. Tasks in progress and files merged into other tasks are left as is
+func (w *Watcher) Changed(path string) {
+	tsk := w.list.FindTask(path)
+	if tsk == nil || tsk.Path != path {
+		return
+	}
+	if channel, _ := tsk.Status(); channel != task.ChDone {
+		return
+	}
+	hash, err := task.FileHash(path)
+	if err != nil {
+		logging.LogError(err)
+		return
+	}
+	if hash == tsk.SHA256 {
+		return
+	}
+	logging.Infof("Watched file is changed: %s", path)
+	tsk.Recheck()
+	w.channels.Push(task.ChPrefilter, tsk.Number, tsk.Priority)
+}
+
+// ignored - reason to skip path of watched root folder: ignore masks, rules
+// or .sandboxerignore files on the way from root
+func (w *Watcher) ignored(root, path string, info os.FileInfo) string {
+	if reason := ignore.Check(w.conf.GetIgnore(), w.conf.GetIgnoreRules(), path, info); reason != "" {
+		return reason
+	}
+	reason, err := ignore.MatchPath(root, path, info.IsDir())
+	logging.LogError(err)
+	return reason
+}
@@ -0,0 +1,265 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+archive.go
+
+Expand ZIP, TAR and GZ archives with limits against archive bombs
+*/
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Limits - restrictions of archive expansion. Size and members count are
+// counted for all nested archives together
+type Limits struct {
+	MaxDepth   int
+	MaxSize    int64
+	MaxMembers int
+	Passwords  []string
+}
+
+var (
+	ErrNotArchive = errors.New("not an archive")
+	ErrLimit      = errors.New("archive limit exceeded")
+	ErrPassword   = errors.New("no matching password")
+	ErrEncryption = errors.New("unsupported encryption")
+)
+
+// Format - kind of archive
+type Format int
+
+const (
+	FormatNone Format = iota
+	FormatZIP
+	FormatTAR
+	FormatGZ
+)
+
+// headerSize - bytes enough to detect any of supported formats
+const headerSize = 512
+
+// Detect - archive format by first bytes of the file
+func Detect(header []byte) Format {
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return FormatZIP
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return FormatGZ
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return FormatTAR
+	}
+	return FormatNone
+}
+
+// DetectFile - archive format of the file
+func DetectFile(filePath string) (Format, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return FormatNone, err
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return FormatNone, err
+	}
+	return Detect(header[:n]), nil
+}
+
+// expander - state of one archive expansion shared by nested archives
+type expander struct {
+	ctx     context.Context
+	limits  Limits
+	files   []string
+	size    int64
+	members int
+}
+
+// Expand - extract files of the archive to folder. Archives inside it are
+// expanded as well down to limits.MaxDepth levels and are not returned.
+// For files that are not archives ErrNotArchive is returned
+func Expand(ctx context.Context, filePath, folder string, limits Limits) ([]string, error) {
+	format, err := DetectFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if format == FormatNone {
+		return nil, fmt.Errorf("%s: %w", filePath, ErrNotArchive)
+	}
+	e := &expander{ctx: ctx, limits: limits}
+	if err := e.expand(filePath, format, folder, 1); err != nil {
+		return nil, fmt.Errorf("%s: %w", filePath, err)
+	}
+	return e.files, nil
+}
+
+func (e *expander) expand(filePath string, format Format, folder string, depth int) error {
+	switch format {
+	case FormatZIP:
+		return e.expandZIP(filePath, folder, depth)
+	case FormatTAR:
+		f, err := os.Open(filePath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return e.expandTAR(f, folder, depth)
+	case FormatGZ:
+		return e.expandGZ(filePath, folder, depth)
+	}
+	return ErrNotArchive
+}
+
+func (e *expander) expandZIP(filePath, folder string, depth int) error {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := e.expandZIPFile(f, folder, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *expander) expandZIPFile(f *zip.File, folder string, depth int) error {
+	var rc io.ReadCloser
+	var err error
+	if f.Flags&zipEncrypted != 0 {
+		rc, err = openEncrypted(f, e.limits.Passwords)
+	} else {
+		rc, err = f.Open()
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	return e.member(f.Name, rc, folder, depth)
+}
+
+func (e *expander) expandTAR(r io.Reader, folder string, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := e.member(header.Name, tr, folder, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// expandGZ - TAR inside GZ is expanded at the same level. Other content is single member
+func (e *expander) expandGZ(filePath, folder string, depth int) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gzr.Close()
+	br := bufio.NewReaderSize(gzr, headerSize)
+	header, _ := br.Peek(headerSize)
+	if Detect(header) == FormatTAR {
+		return e.expandTAR(br, folder, depth)
+	}
+	name := gzr.Name
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	}
+	return e.member(name, br, folder, depth)
+}
+
+// member - write file of archive to folder and expand it if it is archive too
+func (e *expander) member(name string, r io.Reader, folder string, depth int) error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	e.members++
+	if e.members > e.limits.MaxMembers {
+		return fmt.Errorf("%w: more than %d files", ErrLimit, e.limits.MaxMembers)
+	}
+	target, err := safeJoin(folder, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	left := e.limits.MaxSize - e.size
+	size, err := writeFile(target, io.LimitReader(r, left+1))
+	e.size += size
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if size > left {
+		return fmt.Errorf("%w: more than %d bytes", ErrLimit, e.limits.MaxSize)
+	}
+	if depth < e.limits.MaxDepth {
+		format, err := DetectFile(target)
+		if err != nil {
+			return err
+		}
+		if format != FormatNone {
+			if err := e.expand(target, format, target+"_", depth+1); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			return os.Remove(target)
+		}
+	}
+	e.files = append(e.files, target)
+	return nil
+}
+
+func writeFile(filePath string, r io.Reader) (int64, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return 0, err
+	}
+	size, err := io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return size, err
+	}
+	return size, f.Close()
+}
+
+// safeJoin - path of archive member inside folder. Absolute names and ".."
+// elements can not lead out of it
+func safeJoin(folder, name string) (string, error) {
+	name = path.Clean("/" + strings.ReplaceAll(name, "\\", "/"))
+	if name == "/" {
+		return "", errors.New("empty file name")
+	}
+	return filepath.Join(folder, filepath.FromSlash(name[1:])), nil
+}
@@ -0,0 +1,199 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+archive_test.go
+
+Test archives expansion
+*/
+package extract
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func writeZIP(t *testing.T, filePath string, files map[string][]byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func tgz(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for name, data := range files {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// writeEncryptedZIP - single stored file protected with traditional encryption
+func writeEncryptedZIP(t *testing.T, filePath, name, password string, data []byte) {
+	t.Helper()
+	crc := crc32.ChecksumIEEE(data)
+	plain := append([]byte("0123456789a"), byte(crc>>24))
+	plain = append(plain, data...)
+	z := newZipCrypto(password)
+	encrypted := make([]byte, len(plain))
+	for i, c := range plain {
+		encrypted[i] = c ^ z.stream()
+		z.update(c)
+	}
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Store,
+		Flags:              zipEncrypted,
+		CRC32:              crc,
+		CompressedSize64:   uint64(len(encrypted)),
+		UncompressedSize64: uint64(len(data)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	folder := t.TempDir()
+	archive := filepath.Join(folder, "sample.zip")
+	writeZIP(t, archive, map[string][]byte{
+		"a.txt":         []byte("first"),
+		"../../evil.sh": []byte("second"),
+		"dir/inner.tgz": tgz(t, map[string][]byte{"b.txt": []byte("third")}),
+	})
+	limits := Limits{MaxDepth: 3, MaxSize: 1024 * 1024, MaxMembers: 10}
+	relative := func(files []string, target string) (result []string) {
+		for _, f := range files {
+			rel, err := filepath.Rel(target, f)
+			if err != nil || strings.HasPrefix(rel, "..") {
+				t.Errorf("%s is outside of %s", f, target)
+			}
+			result = append(result, filepath.ToSlash(rel))
+		}
+		sort.Strings(result)
+		return
+	}
+	t.Run("nested", func(t *testing.T) {
+		target := filepath.Join(folder, "nested")
+		files, err := Expand(context.Background(), archive, target, limits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"a.txt", "dir/inner.tgz_/b.txt", "evil.sh"}
+		if actual := relative(files, target); strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected %v, but got %v", expected, actual)
+		}
+	})
+	t.Run("depth", func(t *testing.T) {
+		target := filepath.Join(folder, "depth")
+		l := limits
+		l.MaxDepth = 1
+		files, err := Expand(context.Background(), archive, target, l)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := []string{"a.txt", "dir/inner.tgz", "evil.sh"}
+		if actual := relative(files, target); strings.Join(actual, ",") != strings.Join(expected, ",") {
+			t.Errorf("expected %v, but got %v", expected, actual)
+		}
+	})
+	t.Run("members", func(t *testing.T) {
+		l := limits
+		l.MaxMembers = 2
+		_, err := Expand(context.Background(), archive, filepath.Join(folder, "members"), l)
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("expected %v, but got %v", ErrLimit, err)
+		}
+	})
+	t.Run("size", func(t *testing.T) {
+		l := limits
+		l.MaxSize = 8
+		_, err := Expand(context.Background(), archive, filepath.Join(folder, "size"), l)
+		if !errors.Is(err, ErrLimit) {
+			t.Errorf("expected %v, but got %v", ErrLimit, err)
+		}
+	})
+	t.Run("not archive", func(t *testing.T) {
+		_, err := Expand(context.Background(), filepath.Join(folder, "nested", "a.txt"), filepath.Join(folder, "none"), limits)
+		if !errors.Is(err, ErrNotArchive) {
+			t.Errorf("expected %v, but got %v", ErrNotArchive, err)
+		}
+	})
+}
+
+func TestExpandEncrypted(t *testing.T) {
+	folder := t.TempDir()
+	archive := filepath.Join(folder, "infected.zip")
+	data := []byte("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR")
+	writeEncryptedZIP(t, archive, "eicar.com", "infected", data)
+	limits := Limits{MaxDepth: 1, MaxSize: 1024, MaxMembers: 10, Passwords: []string{"virus", "infected"}}
+	files, err := Expand(context.Background(), archive, filepath.Join(folder, "out"), limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected one file, but got %v", files)
+	}
+	actual, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, data) {
+		t.Errorf("expected %q, but got %q", data, actual)
+	}
+	limits.Passwords = []string{"wrong"}
+	_, err = Expand(context.Background(), archive, filepath.Join(folder, "wrong"), limits)
+	if !errors.Is(err, ErrPassword) && !errors.Is(err, zip.ErrChecksum) {
+		t.Errorf("expected %v, but got %v", ErrPassword, err)
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+zipcrypto.go
+
+Traditional PKWARE encryption of ZIP files, used for "infected" password
+protected samples. AES encrypted files are not supported
+*/
+package extract
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+)
+
+const (
+	zipEncrypted      = 0x1
+	zipDataDescriptor = 0x8
+	zipCryptoHeader   = 12
+)
+
+type zipCrypto struct {
+	k0, k1, k2 uint32
+}
+
+func newZipCrypto(password string) *zipCrypto {
+	z := &zipCrypto{0x12345678, 0x23456789, 0x34567890}
+	for i := 0; i < len(password); i++ {
+		z.update(password[i])
+	}
+	return z
+}
+
+func (z *zipCrypto) update(b byte) {
+	z.k0 = crc32Byte(z.k0, b)
+	z.k1 = (z.k1+(z.k0&0xff))*134775813 + 1
+	z.k2 = crc32Byte(z.k2, byte(z.k1>>24))
+}
+
+func (z *zipCrypto) stream() byte {
+	t := z.k2 | 2
+	return byte((t * (t ^ 1)) >> 8)
+}
+
+func (z *zipCrypto) decrypt(buf []byte) {
+	for i := range buf {
+		buf[i] ^= z.stream()
+		z.update(buf[i])
+	}
+}
+
+func crc32Byte(crc uint32, b byte) uint32 {
+	return crc32.IEEETable[byte(crc)^b] ^ (crc >> 8)
+}
+
+type zipCryptoReader struct {
+	r io.Reader
+	z *zipCrypto
+}
+
+func (r *zipCryptoReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.z.decrypt(p[:n])
+	return n, err
+}
+
+// checksumReader - fails at the end of data with wrong CRC32, as password
+// check byte of encryption header matches for some of wrong passwords
+type checksumReader struct {
+	io.ReadCloser
+	hash hash.Hash32
+	crc  uint32
+}
+
+func (r *checksumReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && r.hash.Sum32() != r.crc {
+		return n, zip.ErrChecksum
+	}
+	return n, err
+}
+
+// openEncrypted - decrypt file with first of passwords that fits
+func openEncrypted(f *zip.File, passwords []string) (io.ReadCloser, error) {
+	if f.Method != zip.Store && f.Method != zip.Deflate {
+		return nil, fmt.Errorf("%w: method %d", ErrEncryption, f.Method)
+	}
+	check := byte(f.CRC32 >> 24)
+	if f.Flags&zipDataDescriptor != 0 {
+		check = byte(f.ModifiedTime >> 8)
+	}
+	for _, password := range passwords {
+		raw, err := f.OpenRaw()
+		if err != nil {
+			return nil, err
+		}
+		z := newZipCrypto(password)
+		header := make([]byte, zipCryptoHeader)
+		if _, err := io.ReadFull(raw, header); err != nil {
+			return nil, err
+		}
+		z.decrypt(header)
+		if header[zipCryptoHeader-1] != check {
+			continue
+		}
+		var rc io.ReadCloser = io.NopCloser(&zipCryptoReader{raw, z})
+		if f.Method == zip.Deflate {
+			rc = flate.NewReader(&zipCryptoReader{raw, z})
+		}
+		return &checksumReader{rc, crc32.NewIEEE(), f.CRC32}, nil
+	}
+	return nil, ErrPassword
+}
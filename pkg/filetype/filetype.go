@@ -0,0 +1,292 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+filetype.go
+
+Detect type of file by its content
+*/
+package filetype
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Type - kind of file content. Empty value is unknown binary content
+type Type string
+
+const (
+	Unknown Type = ""
+	PE      Type = "pe"
+	ELF     Type = "elf"
+	MachO   Type = "macho"
+	OLE     Type = "ole"
+	OOXML   Type = "ooxml"
+	PDF     Type = "pdf"
+	RTF     Type = "rtf"
+	HTML    Type = "html"
+	Script  Type = "script"
+	ZIP     Type = "zip"
+	GZIP    Type = "gzip"
+	TAR     Type = "tar"
+	RAR     Type = "rar"
+	SevenZ  Type = "7z"
+	BZIP2   Type = "bzip2"
+	XZ      Type = "xz"
+	CAB     Type = "cab"
+	PNG     Type = "png"
+	JPEG    Type = "jpeg"
+	GIF     Type = "gif"
+	BMP     Type = "bmp"
+	TIFF    Type = "tiff"
+	ICO     Type = "ico"
+	WEBP    Type = "webp"
+	MP3     Type = "mp3"
+	MP4     Type = "mp4"
+	AVI     Type = "avi"
+	WAV     Type = "wav"
+	OGG     Type = "ogg"
+	FLAC    Type = "flac"
+	MKV     Type = "mkv"
+	Text    Type = "text"
+)
+
+// Group - types of the same purpose
+type Group string
+
+const (
+	GroupExecutable Group = "executable"
+	GroupDocument   Group = "document"
+	GroupScript     Group = "script"
+	GroupArchive    Group = "archive"
+	GroupImage      Group = "image"
+	GroupMedia      Group = "media"
+	GroupText       Group = "text"
+)
+
+var groups = map[Type]Group{
+	PE:     GroupExecutable,
+	ELF:    GroupExecutable,
+	MachO:  GroupExecutable,
+	OLE:    GroupDocument,
+	OOXML:  GroupDocument,
+	PDF:    GroupDocument,
+	RTF:    GroupDocument,
+	HTML:   GroupDocument,
+	Script: GroupScript,
+	ZIP:    GroupArchive,
+	GZIP:   GroupArchive,
+	TAR:    GroupArchive,
+	RAR:    GroupArchive,
+	SevenZ: GroupArchive,
+	BZIP2:  GroupArchive,
+	XZ:     GroupArchive,
+	CAB:    GroupArchive,
+	PNG:    GroupImage,
+	JPEG:   GroupImage,
+	GIF:    GroupImage,
+	BMP:    GroupImage,
+	TIFF:   GroupImage,
+	ICO:    GroupImage,
+	WEBP:   GroupImage,
+	MP3:    GroupMedia,
+	MP4:    GroupMedia,
+	AVI:    GroupMedia,
+	WAV:    GroupMedia,
+	OGG:    GroupMedia,
+	FLAC:   GroupMedia,
+	MKV:    GroupMedia,
+	Text:   GroupText,
+}
+
+var titles = map[Type]string{
+	Unknown: "Unknown",
+	PE:      "PE",
+	ELF:     "ELF",
+	MachO:   "Mach-O",
+	OLE:     "OLE",
+	OOXML:   "OOXML",
+	SevenZ:  "7Z",
+	Text:    "Text",
+	Script:  "Script",
+}
+
+// Group - group of the type
+func (t Type) Group() Group {
+	return groups[t]
+}
+
+// String - short name to show
+func (t Type) String() string {
+	if title, ok := titles[t]; ok {
+		return title
+	}
+	return strings.ToUpper(string(t))
+}
+
+// Match - type or its group is one of names. Case is ignored
+func (t Type) Match(names []string) bool {
+	for _, name := range names {
+		if strings.EqualFold(name, string(t)) || t != Unknown && strings.EqualFold(name, string(t.Group())) {
+			return true
+		}
+	}
+	return false
+}
+
+type part struct {
+	offset int
+	magic  string
+}
+
+type signature struct {
+	parts    []part
+	fileType Type
+}
+
+var signatures = []signature{
+	{[]part{{0, "MZ"}}, PE},
+	{[]part{{0, "\x7fELF"}}, ELF},
+	{[]part{{0, "\xfe\xed\xfa\xce"}}, MachO},
+	{[]part{{0, "\xfe\xed\xfa\xcf"}}, MachO},
+	{[]part{{0, "\xce\xfa\xed\xfe"}}, MachO},
+	{[]part{{0, "\xcf\xfa\xed\xfe"}}, MachO},
+	{[]part{{0, "\xd0\xcf\x11\xe0\xa1\xb1\x1a\xe1"}}, OLE},
+	{[]part{{0, "%PDF-"}}, PDF},
+	{[]part{{0, "{\\rtf"}}, RTF},
+	{[]part{{0, "PK\x03\x04"}}, ZIP},
+	{[]part{{0, "PK\x05\x06"}}, ZIP},
+	{[]part{{0, "\x1f\x8b"}}, GZIP},
+	{[]part{{257, "ustar"}}, TAR},
+	{[]part{{0, "Rar!\x1a\x07"}}, RAR},
+	{[]part{{0, "7z\xbc\xaf\x27\x1c"}}, SevenZ},
+	{[]part{{0, "BZh"}}, BZIP2},
+	{[]part{{0, "\xfd7zXZ\x00"}}, XZ},
+	{[]part{{0, "MSCF"}}, CAB},
+	{[]part{{0, "\x89PNG\r\n\x1a\n"}}, PNG},
+	{[]part{{0, "\xff\xd8\xff"}}, JPEG},
+	{[]part{{0, "GIF87a"}}, GIF},
+	{[]part{{0, "GIF89a"}}, GIF},
+	{[]part{{0, "BM"}, {6, "\x00\x00\x00\x00"}}, BMP},
+	{[]part{{0, "II*\x00"}}, TIFF},
+	{[]part{{0, "MM\x00*"}}, TIFF},
+	{[]part{{0, "\x00\x00\x01\x00"}}, ICO},
+	{[]part{{0, "RIFF"}, {8, "WEBP"}}, WEBP},
+	{[]part{{0, "RIFF"}, {8, "AVI "}}, AVI},
+	{[]part{{0, "RIFF"}, {8, "WAVE"}}, WAV},
+	{[]part{{0, "ID3"}}, MP3},
+	{[]part{{0, "\xff\xfb"}}, MP3},
+	{[]part{{4, "ftyp"}}, MP4},
+	{[]part{{0, "OggS"}}, OGG},
+	{[]part{{0, "fLaC"}}, FLAC},
+	{[]part{{0, "\x1a\x45\xdf\xa3"}}, MKV},
+}
+
+// scriptExtensions - text files that are run by interpreters
+var scriptExtensions = []string{
+	".js", ".jse", ".vbs", ".vbe", ".wsf", ".wsh", ".hta", ".ps1", ".psm1",
+	".bat", ".cmd", ".sh", ".py", ".pl", ".rb", ".php", ".applescript",
+}
+
+// headerSize - bytes enough to check all signatures
+const headerSize = 512
+
+// Detect - type of content by its first bytes. Extension of file name
+// tells scripts from other text files
+func Detect(header []byte, fileName string) Type {
+	for _, s := range signatures {
+		if s.match(header) {
+			return s.fileType
+		}
+	}
+	if bytes.HasPrefix(header, []byte("\xca\xfe\xba\xbe")) && len(header) >= 8 &&
+		binary.BigEndian.Uint32(header[4:8]) < 20 {
+		return MachO // Universal binary. Java class files have the same magic
+	}
+	if !isText(header) {
+		return Unknown
+	}
+	if bytes.HasPrefix(header, []byte("#!")) {
+		return Script
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for _, e := range scriptExtensions {
+		if ext == e {
+			return Script
+		}
+	}
+	start := strings.ToLower(strings.TrimSpace(string(header)))
+	if strings.HasPrefix(start, "<!doctype html") || strings.HasPrefix(start, "<html") {
+		return HTML
+	}
+	return Text
+}
+
+// DetectFile - type of file content. ZIP files are checked for Office documents
+func DetectFile(filePath string) (Type, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return Unknown, err
+	}
+	defer f.Close()
+	header := make([]byte, headerSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Unknown, err
+	}
+	fileType := Detect(header[:n], filePath)
+	if fileType == ZIP && isOOXML(filePath) {
+		return OOXML, nil
+	}
+	return fileType, nil
+}
+
+func (s signature) match(header []byte) bool {
+	for _, p := range s.parts {
+		if len(header) < p.offset+len(p.magic) || string(header[p.offset:p.offset+len(p.magic)]) != p.magic {
+			return false
+		}
+	}
+	return true
+}
+
+func isOOXML(filePath string) bool {
+	r, err := zip.OpenReader(filePath)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		if f.Name == "[Content_Types].xml" {
+			return true
+		}
+	}
+	return false
+}
+
+// isText - valid UTF-8 without control characters other than spaces.
+// Last character may be cut by the header end
+func isText(header []byte) bool {
+	if len(header) == 0 {
+		return false
+	}
+	for len(header) > 0 {
+		r, size := utf8.DecodeRune(header)
+		if r == utf8.RuneError && size <= 1 {
+			return len(header) < utf8.UTFMax && !utf8.FullRune(header)
+		}
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' && r != '\f' {
+			return false
+		}
+		header = header[size:]
+	}
+	return true
+}
@@ -0,0 +1,109 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+filetype_test.go
+
+Test detection of file types
+*/
+package filetype
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		header   string
+		fileName string
+		expected Type
+	}{
+		{"MZ\x90\x00", "sample.txt", PE},
+		{"\x7fELF\x02\x01", "sample", ELF},
+		{"\xcf\xfa\xed\xfe", "sample", MachO},
+		{"\xca\xfe\xba\xbe\x00\x00\x00\x02", "sample", MachO},
+		{"\xca\xfe\xba\xbe\x00\x00\x00\x34", "sample.class", Unknown},
+		{"%PDF-1.7", "sample.pdf", PDF},
+		{"\x89PNG\r\n\x1a\n", "sample.png", PNG},
+		{"RIFF\x00\x00\x00\x00WEBP", "sample", WEBP},
+		{"BM\x36\x00\x00\x00\x00\x00\x00\x00", "sample.bmp", BMP},
+		{"BMW cars", "sample.txt", Text},
+		{"#!/bin/sh\necho", "sample", Script},
+		{"WScript.Echo 1", "sample.VBS", Script},
+		{"  <!DOCTYPE html><html>", "sample.txt", HTML},
+		{"plain text", "sample.exe", Text},
+		{"caf\xc3", "sample.txt", Text},
+		{"\x00\x01\x02\x03", "sample.txt", Unknown},
+		{"", "sample.txt", Unknown},
+	}
+	for _, tc := range testCases {
+		if actual := Detect([]byte(tc.header), tc.fileName); actual != tc.expected {
+			t.Errorf("%q: expected %v, but got %v", tc.header, tc.expected, actual)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		fileType Type
+		names    []string
+		expected bool
+	}{
+		{PE, []string{"Executable"}, true},
+		{PE, []string{"pe"}, true},
+		{PE, []string{"image", "document"}, false},
+		{OOXML, []string{"document"}, true},
+		{Unknown, []string{""}, true},
+		{Unknown, []string{"executable"}, false},
+		{Text, nil, false},
+	}
+	for _, tc := range testCases {
+		if actual := tc.fileType.Match(tc.names); actual != tc.expected {
+			t.Errorf("%v %v: expected %v, but got %v", tc.fileType, tc.names, tc.expected, actual)
+		}
+	}
+}
+
+func TestDetectFile(t *testing.T) {
+	folder := t.TempDir()
+	writeZIP := func(name string, files ...string) string {
+		filePath := filepath.Join(folder, name)
+		f, err := os.Create(filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		w := zip.NewWriter(f)
+		for _, file := range files {
+			if _, err := w.Create(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return filePath
+	}
+	testCases := []struct {
+		filePath string
+		expected Type
+	}{
+		{writeZIP("document.docx", "[Content_Types].xml", "word/document.xml"), OOXML},
+		{writeZIP("archive.docx", "readme.txt"), ZIP},
+	}
+	for _, tc := range testCases {
+		actual, err := DetectFile(tc.filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%s: expected %v, but got %v", tc.filePath, tc.expected, actual)
+		}
+	}
+	if _, err := DetectFile(filepath.Join(folder, "missing")); err == nil {
+		t.Error("no error for missing file")
+	}
+}
@@ -15,4 +15,5 @@ const (
 	ExitGotSignal                    = 40
 	ExitNewInstaller                 = 50
 	ExitSetupLogging                 = 60
+	ExitAlreadyRunning               = 70
 )
@@ -19,8 +19,11 @@ import (
 )
 
 const (
-	tasksFolder = "tasks"
-	logsFolder  = "logs"
+	tasksFolder   = "tasks"
+	logsFolder    = "logs"
+	uploadsFolder = "uploads"
+	storeFileName = "tasks.db"
+	tokenFileName = "api_token"
 )
 
 func ConfigurationFilePath() (string, error) {
@@ -47,6 +50,33 @@ func TasksFolder() (string, error) {
 	return filepath.Join(folder, tasksFolder), nil
 }
 
+// StoreFilePath - database of tasks
+func StoreFilePath() (string, error) {
+	folder, err := xplatform.UserDataFolder(AppID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, storeFileName), nil
+}
+
+func UploadsFolder() (string, error) {
+	folder, err := xplatform.UserDataFolder(AppID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, uploadsFolder), nil
+}
+
+// APITokenFilePath - bearer token clients of local HTTP API should present.
+// It is kept next to configuration file
+func APITokenFilePath() (string, error) {
+	folder, err := xplatform.UserDataFolder(AppID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, tokenFileName), nil
+}
+
 func AnalyzerClientUUIDFilePath() (string, error) {
 	folder, err := xplatform.UserDataFolder(AppID)
 	if err != nil {
@@ -63,6 +93,25 @@ func PidFilePath() (string, error) {
 	return filepath.Join(folder, Name+".pid"), nil
 }
 
+// DaemonPidFilePath - PID file of Sandboxer daemon. It differs from GUI one,
+// so uninstaller can stop both of them
+func DaemonPidFilePath() (string, error) {
+	folder, err := xplatform.UserDataFolder(AppID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, Name+"d.pid"), nil
+}
+
+// SocketPath - IPC socket used by submit and other tools to talk to Sandboxer
+func SocketPath() (string, error) {
+	folder, err := xplatform.UserDataFolder(AppID)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(folder, Name+".sock"), nil
+}
+
 func SetupLogging(logFileName string) (func(), error) {
 	logging.SetLevel(logging.DEBUG)
 	logFolder, err := LogsFolder()
@@ -14,7 +14,6 @@ const (
 	Name               = "sandboxer"
 	AppID              = "com.github.mpkondrashin." + Name
 	ConfigFileName     = Name + ".yaml"
-	FIFOName           = Name + "_submit_fifo"
 	AnalyzerClientUUID = Name + "_uuid.txt"
 	MaxLogFileSize     = 10_000_000
 	LogsKeep           = 1
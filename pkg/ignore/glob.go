@@ -0,0 +1,87 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+glob.go
+
+Globs in gitignore style
+*/
+package ignore
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// compiled - regular expressions of globs and rules by their source
+var compiled sync.Map
+
+// compileGlob - regular expression for gitignore style glob: "**" matches
+// any number of folders, "*" and "?" do not match "/". Glob without "/"
+// matches name at any level, other globs match path from its beginning.
+// Files inside matched folder match as well. Case is ignored
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	if re, ok := compiled.Load("glob:" + glob); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	anchored := strings.Contains(glob, "/")
+	pattern := strings.TrimSuffix(strings.TrimPrefix(glob, "/"), "/**")
+	var sb strings.Builder
+	sb.WriteString("(?i)^")
+	if !anchored {
+		sb.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			sb.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	sb.WriteString("(/.*)?$")
+	re, err := regexp.Compile(sb.String())
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store("glob:"+glob, re)
+	return re, nil
+}
+
+// compileRegex - regular expression that is compiled once
+func compileRegex(regex string) (*regexp.Regexp, error) {
+	if re, ok := compiled.Load("regex:" + regex); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store("regex:"+regex, re)
+	return re, nil
+}
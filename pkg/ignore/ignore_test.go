@@ -0,0 +1,230 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+ignore_test.go
+
+Test ignore rules and .sandboxerignore files
+*/
+package ignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGlob(t *testing.T) {
+	testCases := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"**/node_modules/**", "home/user/project/node_modules/lib/index.js", true},
+		{"**/node_modules/**", "home/user/project/node_modules", true},
+		{"**/node_modules/**", "home/user/project/node_modules.js", false},
+		{"node_modules", "project/node_modules/index.js", true},
+		{"*.ISO", "tmp/disk.iso", true},
+		{"*.iso", "tmp/disk.iso.txt", false},
+		{"/tmp/*.iso", "tmp/disk.iso", true},
+		{"/tmp/*.iso", "tmp/images/disk.iso", false},
+		{"/tmp/**/*.iso", "tmp/images/disk.iso", true},
+		{"/tmp/**/*.iso", "tmp/disk.iso", true},
+		{"file?.txt", "a/file1.txt", true},
+		{"file?.txt", "a/file10.txt", false},
+		{"file[0-9].txt", "a/file7.txt", true},
+		{"file[!0-9].txt", "a/file7.txt", false},
+		{"a+b(c).txt", "dir/a+b(c).txt", true},
+	}
+	for _, tc := range testCases {
+		re, err := compileGlob(tc.glob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := re.MatchString(tc.path); actual != tc.expected {
+			t.Errorf("%s %s: expected %v, but got %v (%s)", tc.glob, tc.path, tc.expected, actual, re)
+		}
+	}
+}
+
+func TestRule(t *testing.T) {
+	folder := t.TempDir()
+	write := func(name string, size int, age time.Duration) (string, os.FileInfo) {
+		path := filepath.Join(folder, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-age)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path, info
+	}
+	large, largeInfo := write("large.bin", 1000, 0)
+	old, oldInfo := write("old.txt", 10, 48*time.Hour)
+	hidden, hiddenInfo := write(".hidden", 10, 0)
+	module, moduleInfo := write("node_modules/lib/index.js", 10, 0)
+	testCases := []struct {
+		rule     Rule
+		path     string
+		info     os.FileInfo
+		expected bool
+	}{
+		{Rule{MinSize: 100}, large, largeInfo, true},
+		{Rule{MinSize: 100}, old, oldInfo, false},
+		{Rule{MaxSize: 100}, old, oldInfo, true},
+		{Rule{OlderThan: 24 * time.Hour}, old, oldInfo, true},
+		{Rule{OlderThan: 24 * time.Hour}, large, largeInfo, false},
+		{Rule{NewerThan: time.Hour}, large, largeInfo, true},
+		{Rule{Hidden: true}, hidden, hiddenInfo, true},
+		{Rule{Hidden: true}, large, largeInfo, false},
+		{Rule{Glob: "**/node_modules/**"}, module, moduleInfo, true},
+		{Rule{Glob: "**/node_modules/**", MinSize: 100}, module, moduleInfo, false},
+		{Rule{Regex: `\.(js|txt)$`}, old, oldInfo, true},
+		{Rule{Regex: `\.(js|txt)$`}, large, largeInfo, false},
+		{Rule{Name: "empty"}, large, largeInfo, false},
+	}
+	now := time.Now()
+	for _, tc := range testCases {
+		actual, err := tc.rule.Match(tc.path, tc.info, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != tc.expected {
+			t.Errorf("%v %s: expected %v, but got %v", tc.rule, tc.path, tc.expected, actual)
+		}
+	}
+	folderInfo, err := os.Stat(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, _ := (Rule{MaxSize: 100}).Match(folder, folderInfo, now); match {
+		t.Errorf("size condition matches folder")
+	}
+	if _, err := (Rule{Regex: "("}).Match(old, oldInfo, now); err == nil {
+		t.Errorf("no error for wrong regex")
+	}
+	reason := Check([]string{"*.tmp"}, []Rule{{Name: "Large", MinSize: 100}}, large, largeInfo)
+	if reason != "Matched ignore rule 'Large'" {
+		t.Errorf("wrong reason: %s", reason)
+	}
+	reason = Check([]string{"*.BIN"}, nil, large, largeInfo)
+	if reason != "Matched ignore mask '*.BIN'" {
+		t.Errorf("wrong reason: %s", reason)
+	}
+}
+
+func TestFile(t *testing.T) {
+	folder := filepath.Join(string(filepath.Separator), "project")
+	file, err := ParseFile(folder, strings.NewReader(`
+# comment
+*.log
+!keep.log
+build/
+/docs/*.pdf
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		path     string
+		isDir    bool
+		expected bool
+	}{
+		{"debug.log", false, true},
+		{"src/debug.log", false, true},
+		{"src/keep.log", false, false},
+		{"build", true, true},
+		{"build", false, false},
+		{"src/build/main.o", false, true},
+		{"docs/manual.pdf", false, true},
+		{"src/docs/manual.pdf", false, false},
+		{"main.go", false, false},
+	}
+	for _, tc := range testCases {
+		ignored, _ := file.Match(filepath.Join(folder, filepath.FromSlash(tc.path)), tc.isDir)
+		if ignored != tc.expected {
+			t.Errorf("%s: expected %v, but got %v", tc.path, tc.expected, ignored)
+		}
+	}
+}
+
+func TestWalker(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		FileName:             "*.tmp\nsecret/\n",
+		"a.tmp":              "",
+		"a.txt":              "",
+		"secret/key.txt":     "",
+		"sub/" + FileName:    "!b.tmp\n*.txt\n",
+		"sub/b.tmp":          "",
+		"sub/b.txt":          "",
+		"other/c.tmp":        "",
+		"other/c.txt":        "",
+		"sub/deeper/d.tmp":   "",
+		"sub/deeper/d.bin":   "",
+		"sub/deeper/e.txt":   "",
+		"other/deeper/f.txt": "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var walker Walker
+	var found []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if walker.Match(path, info.IsDir()) != "" {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return walker.Enter(path)
+		}
+		if info.Name() != FileName {
+			rel, _ := filepath.Rel(root, path)
+			found = append(found, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "a.txt,other/c.txt,other/deeper/f.txt,sub/b.tmp,sub/deeper/d.bin"
+	if actual := strings.Join(found, ","); actual != expected {
+		t.Errorf("expected %s, but got %s", expected, actual)
+	}
+	for name, expected := range map[string]bool{
+		"a.tmp":            true,
+		"secret/key.txt":   true,
+		"sub/b.tmp":        false,
+		"sub/deeper/e.txt": true,
+		"sub/deeper/d.bin": false,
+	} {
+		reason, err := MatchPath(root, filepath.Join(root, filepath.FromSlash(name)), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (reason != "") != expected {
+			t.Errorf("%s: expected %v, but got \"%s\"", name, expected, reason)
+		}
+	}
+}
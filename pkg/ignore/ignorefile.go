@@ -0,0 +1,177 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+ignorefile.go
+
+.sandboxerignore files in gitignore style
+*/
+package ignore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileName - name of file with patterns of files to skip in its folder
+const FileName = ".sandboxerignore"
+
+type pattern struct {
+	line    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// File - patterns of one .sandboxerignore file. Patterns are matched
+// against paths relative to its folder
+type File struct {
+	folder   string
+	patterns []pattern
+}
+
+// LoadFile - read .sandboxerignore of the folder. If there is no such file,
+// nil is returned
+func LoadFile(folder string) (*File, error) {
+	f, err := os.Open(filepath.Join(folder, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseFile(folder, f)
+}
+
+// ParseFile - read patterns: one per line, "#" starts comment, "!" negates
+// pattern, trailing "/" matches folders only
+func ParseFile(folder string, r io.Reader) (*File, error) {
+	file := &File{folder: folder}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := pattern{line: line}
+		glob := line
+		if strings.HasPrefix(glob, "!") {
+			p.negate = true
+			glob = glob[1:]
+		}
+		glob = strings.TrimPrefix(glob, `\`)
+		if strings.HasSuffix(glob, "/") {
+			p.dirOnly = true
+			glob = strings.TrimSuffix(glob, "/")
+		}
+		if glob == "" {
+			continue
+		}
+		re, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", filepath.Join(folder, FileName), line, err)
+		}
+		p.re = re
+		file.patterns = append(file.patterns, p)
+	}
+	return file, scanner.Err()
+}
+
+// Match - last pattern that matches path decides whether it is ignored.
+// Pattern is returned if any matched
+func (f *File) Match(path string, isDir bool) (ignored bool, line string) {
+	rel, err := filepath.Rel(f.folder, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false, ""
+	}
+	dir := filepath.ToSlash(filepath.Dir(rel))
+	rel = filepath.ToSlash(rel)
+	for _, p := range f.patterns {
+		if p.dirOnly && !isDir {
+			// File is matched only by being inside matched folder
+			if !p.re.MatchString(dir) {
+				continue
+			}
+		} else if !p.re.MatchString(rel) {
+			continue
+		}
+		ignored, line = !p.negate, p.line
+	}
+	return
+}
+
+// Walker - .sandboxerignore files of folders on the way from walk root
+// to current path. Folders are expected to be walked depth first
+type Walker struct {
+	files []*File
+}
+
+// Enter - read .sandboxerignore of the folder that is walked into
+func (w *Walker) Enter(folder string) error {
+	w.leave(folder)
+	file, err := LoadFile(folder)
+	if err != nil || file == nil {
+		return err
+	}
+	w.files = append(w.files, file)
+	return nil
+}
+
+// Match - reason to skip path or empty string. Deeper files override
+// patterns of upper ones
+func (w *Walker) Match(path string, isDir bool) string {
+	w.leave(filepath.Dir(path))
+	reason := ""
+	for _, file := range w.files {
+		ignored, line := file.Match(path, isDir)
+		if line == "" {
+			continue
+		}
+		reason = ""
+		if ignored {
+			reason = fmt.Sprintf("Matched '%s' in %s", line, filepath.Join(file.folder, FileName))
+		}
+	}
+	return reason
+}
+
+// leave - forget files of folders that do not contain path
+func (w *Walker) leave(path string) {
+	for len(w.files) > 0 {
+		rel, err := filepath.Rel(w.files[len(w.files)-1].folder, path)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return
+		}
+		w.files = w.files[:len(w.files)-1]
+	}
+}
+
+// MatchPath - reason to skip path by .sandboxerignore files of root and
+// folders on the way from root to path
+func MatchPath(root, path string, isDir bool) (string, error) {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	var w Walker
+	if err := w.Enter(root); err != nil {
+		return "", err
+	}
+	if rel != "." {
+		folder := root
+		for _, name := range strings.Split(rel, string(filepath.Separator)) {
+			folder = filepath.Join(folder, name)
+			if err := w.Enter(folder); err != nil {
+				return "", err
+			}
+		}
+	}
+	return w.Match(path, isDir), nil
+}
@@ -0,0 +1,151 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+rule.go
+
+Rules of files that are not analyzed
+*/
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/xplatform"
+)
+
+// Rule - ignore files matching all non empty conditions. Glob is matched
+// against full path, Regex too. Size and age conditions never match folders.
+// Rule without conditions matches nothing
+type Rule struct {
+	Name      string        `yaml:"name,omitempty"`
+	Glob      string        `yaml:"glob,omitempty"`
+	Regex     string        `yaml:"regex,omitempty"`
+	MinSize   int64         `yaml:"min_size,omitempty"`
+	MaxSize   int64         `yaml:"max_size,omitempty"`
+	OlderThan time.Duration `yaml:"older_than,omitempty"`
+	NewerThan time.Duration `yaml:"newer_than,omitempty"`
+	Hidden    bool          `yaml:"hidden,omitempty"`
+}
+
+// Match - check file. Now is used to calculate file age
+func (r Rule) Match(path string, info os.FileInfo, now time.Time) (bool, error) {
+	if r.empty() {
+		return false, nil
+	}
+	slashPath := strings.TrimPrefix(filepath.ToSlash(path), "/")
+	if r.Glob != "" {
+		re, err := compileGlob(r.Glob)
+		if err != nil {
+			return false, fmt.Errorf("glob \"%s\": %w", r.Glob, err)
+		}
+		if !re.MatchString(slashPath) {
+			return false, nil
+		}
+	}
+	if r.Regex != "" {
+		re, err := compileRegex(r.Regex)
+		if err != nil {
+			return false, fmt.Errorf("regex \"%s\": %w", r.Regex, err)
+		}
+		if !re.MatchString(filepath.ToSlash(path)) {
+			return false, nil
+		}
+	}
+	if r.Hidden && !xplatform.IsHidden(path, info) {
+		return false, nil
+	}
+	if r.MinSize == 0 && r.MaxSize == 0 && r.OlderThan == 0 && r.NewerThan == 0 {
+		return true, nil
+	}
+	if info == nil || info.IsDir() {
+		return false, nil
+	}
+	if r.MinSize > 0 && info.Size() < r.MinSize {
+		return false, nil
+	}
+	if r.MaxSize > 0 && info.Size() > r.MaxSize {
+		return false, nil
+	}
+	age := now.Sub(info.ModTime())
+	if r.OlderThan > 0 && age < r.OlderThan {
+		return false, nil
+	}
+	if r.NewerThan > 0 && age > r.NewerThan {
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r Rule) empty() bool {
+	return r.Glob == "" && r.Regex == "" && !r.Hidden &&
+		r.MinSize == 0 && r.MaxSize == 0 && r.OlderThan == 0 && r.NewerThan == 0
+}
+
+// String - name of the rule or its conditions
+func (r Rule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	var conditions []string
+	if r.Glob != "" {
+		conditions = append(conditions, "glob "+r.Glob)
+	}
+	if r.Regex != "" {
+		conditions = append(conditions, "regex "+r.Regex)
+	}
+	if r.Hidden {
+		conditions = append(conditions, "hidden")
+	}
+	if r.MinSize > 0 {
+		conditions = append(conditions, fmt.Sprintf("size >= %d", r.MinSize))
+	}
+	if r.MaxSize > 0 {
+		conditions = append(conditions, fmt.Sprintf("size <= %d", r.MaxSize))
+	}
+	if r.OlderThan > 0 {
+		conditions = append(conditions, fmt.Sprintf("older than %v", r.OlderThan))
+	}
+	if r.NewerThan > 0 {
+		conditions = append(conditions, fmt.Sprintf("newer than %v", r.NewerThan))
+	}
+	return strings.Join(conditions, ", ")
+}
+
+// MatchMask - first of file name masks that file matches or empty string
+func MatchMask(masks []string, filePath string) string {
+	fileName := strings.ToLower(filepath.Base(filePath))
+	for _, mask := range masks {
+		result, err := filepath.Match(strings.ToLower(mask), fileName)
+		logging.LogError(err)
+		if result {
+			return mask
+		}
+	}
+	return ""
+}
+
+// Check - reason to ignore file: mask or rule it matches. Empty string if
+// file should be analyzed
+func Check(masks []string, rules []Rule, filePath string, info os.FileInfo) string {
+	if mask := MatchMask(masks, filePath); mask != "" {
+		return fmt.Sprintf("Matched ignore mask '%s'", mask)
+	}
+	now := time.Now()
+	for _, rule := range rules {
+		match, err := rule.Match(filePath, info, now)
+		if err != nil {
+			logging.LogError(err)
+			continue
+		}
+		if match {
+			return fmt.Sprintf("Matched ignore rule '%v'", rule)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,162 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+client.go
+
+IPC client. Single client can be used by several goroutines
+*/
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"sandboxer/pkg/globals"
+	"sandboxer/pkg/task"
+)
+
+var ErrNotRunning = errors.New(globals.AppName + " is not running")
+
+type Client struct {
+	mx      sync.Mutex
+	conn    net.Conn
+	encoder *json.Encoder
+	decoder *json.Decoder
+	seq     uint64
+}
+
+// Dial - connect to running Sandboxer. If it is not running, returned error
+// wraps ErrNotRunning
+func Dial(path string) (*Client, error) {
+	conn, err := dial(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+	}
+	return &Client{
+		conn:    conn,
+		encoder: json.NewEncoder(conn),
+		decoder: json.NewDecoder(bufio.NewReader(conn)),
+	}, nil
+}
+
+// DialDefault - connect to Sandboxer of current user
+func DialDefault() (*Client, error) {
+	path, err := globals.SocketPath()
+	if err != nil {
+		return nil, err
+	}
+	return Dial(path)
+}
+
+// Running - check whether socket is served by Sandboxer or Sandboxer daemon
+func Running(path string) bool {
+	conn, err := dial(path)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// RunningDefault - check whether Sandboxer or Sandboxer daemon of current
+// user is running. Only one of them can run at a time
+func RunningDefault() bool {
+	path, err := globals.SocketPath()
+	if err != nil {
+		return false
+	}
+	return Running(path)
+}
+
+// IsDown - check whether error returned by Dial means that Sandboxer is not running
+func IsDown(err error) bool {
+	return errors.Is(err, ErrNotRunning)
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Call - send request and wait for response. Returned error is either
+// connection error or error reported by Sandboxer
+func (c *Client) Call(request Request) (*Response, error) {
+	c.mx.Lock()
+	defer c.mx.Unlock()
+	c.seq++
+	request.Version = ProtocolVersion
+	request.Seq = c.seq
+	if err := c.encoder.Encode(&request); err != nil {
+		return nil, err
+	}
+	var response Response
+	if err := c.decoder.Decode(&response); err != nil {
+		return nil, err
+	}
+	if response.Seq != request.Seq {
+		return nil, fmt.Errorf("response #%d for request #%d", response.Seq, request.Seq)
+	}
+	return &response, response.Err()
+}
+
+// SubmitFile - submit file for analysis with given priority. If file is already
+// submitted, existing task is returned along with error wrapping ErrAlreadyExists
+func (c *Client) SubmitFile(path string, priority task.Priority) (*TaskInfo, error) {
+	response, err := c.Call(Request{Type: MessageSubmitFile, Path: path, Priority: priority})
+	if response == nil {
+		return nil, err
+	}
+	return response.Task, err
+}
+
+// SubmitURL - submit URL for analysis. Works same way as SubmitFile
+func (c *Client) SubmitURL(url string, priority task.Priority) (*TaskInfo, error) {
+	response, err := c.Call(Request{Type: MessageSubmitURL, URL: url, Priority: priority})
+	if response == nil {
+		return nil, err
+	}
+	return response.Task, err
+}
+
+func (c *Client) Status(id task.ID) (*TaskInfo, error) {
+	response, err := c.Call(Request{Type: MessageQueryStatus, TaskID: id})
+	if err != nil {
+		return nil, err
+	}
+	return response.Task, nil
+}
+
+func (c *Client) List() ([]TaskInfo, error) {
+	response, err := c.Call(Request{Type: MessageListTasks})
+	if err != nil {
+		return nil, err
+	}
+	return response.Tasks, nil
+}
+
+func (c *Client) Cancel(id task.ID) error {
+	_, err := c.Call(Request{Type: MessageCancel, TaskID: id})
+	return err
+}
+
+// Pause - stop task till Resume is called
+func (c *Client) Pause(id task.ID) error {
+	_, err := c.Call(Request{Type: MessagePause, TaskID: id})
+	return err
+}
+
+// Resume - continue paused task
+func (c *Client) Resume(id task.ID) error {
+	_, err := c.Call(Request{Type: MessageResume, TaskID: id})
+	return err
+}
+
+// Shutdown - ask Sandboxer to stop. Returns after request is acknowledged
+func (c *Client) Shutdown() error {
+	_, err := c.Call(Request{Type: MessageShutdown})
+	return err
+}
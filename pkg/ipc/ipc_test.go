@@ -0,0 +1,173 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+ipc_test.go
+
+Test IPC client and server
+*/
+package ipc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/task"
+)
+
+func testHandler(request *Request) Response {
+	switch request.Type {
+	case MessageSubmitFile:
+		if request.Path == "exists" {
+			response := NewErrorResponse(fmt.Errorf("%s: %w", request.Path, task.ErrAlreadyExists))
+			response.Task = &TaskInfo{ID: 1, Task: &task.Task{Path: request.Path}}
+			return response
+		}
+		return Response{Task: &TaskInfo{ID: 2, Task: &task.Task{Path: request.Path, Priority: request.Priority}}}
+	case MessageQueryStatus:
+		return NewErrorResponse(fmt.Errorf("task #%d: %w", request.TaskID, ErrNotFound))
+	}
+	return NewErrorResponse(fmt.Errorf("%w: %s", ErrBadRequest, request.Type))
+}
+
+func startServer(t *testing.T) string {
+	t.Helper()
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	path := filepath.Join(t.TempDir(), "test.sock")
+	server := NewServer(path, HandlerFunc(testHandler))
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() {
+		if err := server.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+	return path
+}
+
+func TestClientServer(t *testing.T) {
+	path := startServer(t)
+	client, err := Dial(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	t.Run("submit", func(t *testing.T) {
+		tsk, err := client.SubmitFile("file.txt", task.PriorityBackground)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tsk.ID != 2 || tsk.Path != "file.txt" || tsk.Priority != task.PriorityBackground {
+			t.Errorf("Wrong task: %v", tsk)
+		}
+	})
+	t.Run("already exists", func(t *testing.T) {
+		tsk, err := client.SubmitFile("exists", task.PriorityInteractive)
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("Expected ErrAlreadyExists, but got %v", err)
+		}
+		if tsk == nil || tsk.ID != 1 {
+			t.Errorf("Existing task is not returned: %v", tsk)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		if _, err := client.Status(5); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, but got %v", err)
+		}
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				c := client
+				if i%2 == 0 {
+					var err error
+					c, err = Dial(path)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer c.Close()
+				}
+				name := fmt.Sprintf("file%d", i)
+				tsk, err := c.SubmitFile(name, task.PriorityInteractive)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if tsk.Path != name {
+					t.Errorf("Expected %s, but got %s", name, tsk.Path)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestUnsupportedVersion(t *testing.T) {
+	path := startServer(t)
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := json.NewEncoder(conn).Encode(Request{Version: ProtocolVersion + 1, Seq: 7, Type: MessageListTasks}); err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := json.NewDecoder(conn).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Seq != 7 {
+		t.Errorf("Expected seq 7, but got %d", response.Seq)
+	}
+	if !errors.Is(response.Err(), ErrUnsupportedVersion) {
+		t.Errorf("Expected ErrUnsupportedVersion, but got %v", response.Err())
+	}
+}
+
+func TestStaleSocket(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	path := filepath.Join(t.TempDir(), "test.sock")
+	if err := os.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(path, HandlerFunc(testHandler))
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	second := NewServer(path, HandlerFunc(testHandler))
+	if err := second.Listen(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("Expected ErrAlreadyRunning, but got %v", err)
+	}
+}
+
+func TestRunning(t *testing.T) {
+	path := startServer(t)
+	if !Running(path) {
+		t.Error("Running server is not detected")
+	}
+	if Running(filepath.Join(t.TempDir(), "missing.sock")) {
+		t.Error("Missing server is detected")
+	}
+}
+
+func TestIsDown(t *testing.T) {
+	_, err := Dial(filepath.Join(t.TempDir(), "missing.sock"))
+	if !IsDown(err) {
+		t.Errorf("Expected IsDown, but got %v", err)
+	}
+}
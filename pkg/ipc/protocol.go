@@ -0,0 +1,117 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+protocol.go
+
+Messages of request/response protocol between Sandboxer and its clients
+*/
+package ipc
+
+import (
+	"errors"
+	"fmt"
+
+	"sandboxer/pkg/task"
+)
+
+// ProtocolVersion - is increased on every incompatible protocol change
+const ProtocolVersion = 1
+
+type MessageType string
+
+const (
+	MessageSubmitFile  MessageType = "submit-file"
+	MessageSubmitURL   MessageType = "submit-url"
+	MessageQueryStatus MessageType = "query-status"
+	MessageListTasks   MessageType = "list-tasks"
+	MessageCancel      MessageType = "cancel"
+	MessagePause       MessageType = "pause"
+	MessageResume      MessageType = "resume"
+	MessageShutdown    MessageType = "shutdown"
+)
+
+type ErrorCode string
+
+const (
+	CodeOK                 ErrorCode = ""
+	CodeAlreadyExists      ErrorCode = "already-exists"
+	CodeNotFound           ErrorCode = "not-found"
+	CodeBadRequest         ErrorCode = "bad-request"
+	CodeUnsupportedVersion ErrorCode = "unsupported-version"
+	CodeInternal           ErrorCode = "internal"
+)
+
+var (
+	ErrAlreadyExists      = errors.New("already exists")
+	ErrNotFound           = errors.New("not found")
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnsupportedVersion = errors.New("unsupported protocol version")
+	ErrInternal           = errors.New("internal error")
+)
+
+var codeError = map[ErrorCode]error{
+	CodeAlreadyExists:      ErrAlreadyExists,
+	CodeNotFound:           ErrNotFound,
+	CodeBadRequest:         ErrBadRequest,
+	CodeUnsupportedVersion: ErrUnsupportedVersion,
+	CodeInternal:           ErrInternal,
+}
+
+// Request - message sent by client. Seq is returned back in response
+type Request struct {
+	Version  int           `json:"version"`
+	Seq      uint64        `json:"seq"`
+	Type     MessageType   `json:"type"`
+	Path     string        `json:"path,omitempty"`
+	URL      string        `json:"url,omitempty"`
+	TaskID   task.ID       `json:"task_id,omitempty"`
+	Priority task.Priority `json:"priority,omitempty"`
+}
+
+// TaskInfo - task with its ID
+type TaskInfo struct {
+	ID task.ID `json:"ID"`
+	*task.Task
+}
+
+// Response - acknowledgement for each request
+type Response struct {
+	Version int        `json:"version"`
+	Seq     uint64     `json:"seq"`
+	Code    ErrorCode  `json:"code,omitempty"`
+	Error   string     `json:"error,omitempty"`
+	Task    *TaskInfo  `json:"task,omitempty"`
+	Tasks   []TaskInfo `json:"tasks,omitempty"`
+}
+
+// Err - error returned by server or nil. Can be checked using errors.Is
+// against ErrAlreadyExists and other errors of this package
+func (r *Response) Err() error {
+	if r.Code == CodeOK {
+		return nil
+	}
+	err, ok := codeError[r.Code]
+	if !ok {
+		err = ErrInternal
+	}
+	return fmt.Errorf("%w: %s", err, r.Error)
+}
+
+// NewErrorResponse - response with error code deduced from error
+func NewErrorResponse(err error) Response {
+	code := CodeInternal
+	for c, e := range codeError {
+		if errors.Is(err, e) {
+			code = c
+			break
+		}
+	}
+	if errors.Is(err, task.ErrAlreadyExists) {
+		code = CodeAlreadyExists
+	}
+	return Response{
+		Code:  code,
+		Error: err.Error(),
+	}
+}
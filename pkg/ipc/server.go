@@ -0,0 +1,148 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+server.go
+
+IPC server: newline delimited JSON requests, one response per request.
+Each connection is served by its own goroutine
+*/
+package ipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"sandboxer/pkg/logging"
+)
+
+// Handler - processes requests. Called concurrently for different connections
+type Handler interface {
+	Handle(request *Request) Response
+}
+
+type HandlerFunc func(request *Request) Response
+
+func (f HandlerFunc) Handle(request *Request) Response {
+	return f(request)
+}
+
+type Server struct {
+	path     string
+	handler  Handler
+	listener net.Listener
+	wg       sync.WaitGroup
+	mx       sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+var ErrAlreadyRunning = errors.New("already running")
+
+func NewServer(path string, handler Handler) *Server {
+	return &Server{
+		path:    path,
+		handler: handler,
+		conns:   make(map[net.Conn]struct{}),
+	}
+}
+
+// Listen - create socket. Socket left by crashed process is removed
+func (s *Server) Listen() error {
+	if _, err := os.Stat(s.path); err == nil {
+		conn, err := dial(s.path)
+		if err == nil {
+			conn.Close()
+			return fmt.Errorf("%s: %w", s.path, ErrAlreadyRunning)
+		}
+		logging.Debugf("Remove stale socket %s", s.path)
+		if err := os.Remove(s.path); err != nil {
+			return err
+		}
+	}
+	listener, err := listen(s.path)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	return nil
+}
+
+// Serve - accept connections until Close is called
+func (s *Server) Serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.mx.Lock()
+		s.conns[conn] = struct{}{}
+		s.mx.Unlock()
+		s.wg.Add(1)
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mx.Lock()
+		delete(s.conns, conn)
+		s.mx.Unlock()
+		conn.Close()
+	}()
+	decoder := json.NewDecoder(bufio.NewReader(conn))
+	encoder := json.NewEncoder(conn)
+	for {
+		var request Request
+		if err := decoder.Decode(&request); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				logging.LogError(encoder.Encode(s.versioned(&request, NewErrorResponse(fmt.Errorf("%w: %v", ErrBadRequest, err)))))
+			}
+			return
+		}
+		logging.Debugf("IPC request: %s", request.Type)
+		var response Response
+		if request.Version != ProtocolVersion {
+			response = NewErrorResponse(fmt.Errorf("%w: %d (expected %d)", ErrUnsupportedVersion, request.Version, ProtocolVersion))
+		} else {
+			response = s.handler.Handle(&request)
+		}
+		if err := encoder.Encode(s.versioned(&request, response)); err != nil {
+			logging.Errorf("IPC response: %v", err)
+			return
+		}
+	}
+}
+
+func (s *Server) versioned(request *Request, response Response) Response {
+	response.Version = ProtocolVersion
+	response.Seq = request.Seq
+	return response
+}
+
+// Close - stop accepting connections, close opened ones and remove socket
+func (s *Server) Close() error {
+	if s.listener == nil {
+		return nil
+	}
+	err := s.listener.Close()
+	s.mx.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mx.Unlock()
+	s.wg.Wait()
+	if rmErr := os.Remove(s.path); rmErr != nil && !errors.Is(rmErr, os.ErrNotExist) {
+		logging.LogError(rmErr)
+	}
+	return err
+}
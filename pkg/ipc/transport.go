@@ -0,0 +1,28 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+transport.go
+
+Connection level of IPC. Unix domain sockets are supported by Windows 10
+version 1803 and later, so same transport is used on all platforms
+*/
+package ipc
+
+import (
+	"net"
+	"time"
+)
+
+const (
+	network     = "unix"
+	dialTimeout = 5 * time.Second
+)
+
+func listen(path string) (net.Listener, error) {
+	return net.Listen(network, path)
+}
+
+func dial(path string) (net.Conn, error) {
+	return net.DialTimeout(network, path, dialTimeout)
+}
@@ -17,6 +17,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/mpkondrashin/ddan"
 )
@@ -26,6 +27,9 @@ type DDAnSandbox struct {
 }
 
 var _ Sandbox = &DDAnSandbox{}
+var _ Lookup = &DDAnSandbox{}
+var _ Estimate = &DDAnSandbox{}
+var _ BatchSandbox = &DDAnSandbox{}
 
 func NewDDAnSandbox(analyzer ddan.ClientInterface) *DDAnSandbox {
 	return &DDAnSandbox{
@@ -33,12 +37,12 @@ func NewDDAnSandbox(analyzer ddan.ClientInterface) *DDAnSandbox {
 	}
 }
 
-func (s *DDAnSandbox) SubmitURL(filePath string) (string, error) {
-	return s.Submit(false, filePath)
+func (s *DDAnSandbox) SubmitURL(ctx context.Context, filePath string) (string, error) {
+	return s.Submit(ctx, false, filePath)
 }
 
-func (s *DDAnSandbox) SubmitFile(filePath string) (string, error) {
-	return s.Submit(true, filePath)
+func (s *DDAnSandbox) SubmitFile(ctx context.Context, filePath string) (string, error) {
+	return s.Submit(ctx, true, filePath)
 }
 
 func CalculateStringHash(input string) string {
@@ -47,7 +51,7 @@ func CalculateStringHash(input string) string {
 	return hex.EncodeToString(hash.Sum(nil))
 }
 
-func (s *DDAnSandbox) Submit(file bool, content string) (string, error) {
+func (s *DDAnSandbox) Submit(ctx context.Context, file bool, content string) (string, error) {
 	var sha1 string
 	var err error
 	if file {
@@ -58,7 +62,7 @@ func (s *DDAnSandbox) Submit(file bool, content string) (string, error) {
 	} else {
 		sha1 = CalculateStringHash(content)
 	}
-	sha1List, err := s.analyzer.CheckDuplicateSample(context.TODO(), []string{sha1}, 0)
+	sha1List, err := s.analyzer.CheckDuplicateSample(ctx, []string{sha1}, 0)
 	if err != nil {
 		var apiErr *ddan.APIError
 		if !errors.As(err, &apiErr) {
@@ -67,11 +71,11 @@ func (s *DDAnSandbox) Submit(file bool, content string) (string, error) {
 		if apiErr.Response != ddan.ResponseNotRegistered {
 			return "", err
 		}
-		err := s.analyzer.Register(context.TODO())
+		err := s.analyzer.Register(ctx)
 		if err != nil {
 			return "", err
 		}
-		sha1List, err = s.analyzer.CheckDuplicateSample(context.TODO(), []string{sha1}, 0)
+		sha1List, err = s.analyzer.CheckDuplicateSample(ctx, []string{sha1}, 0)
 		if err != nil {
 			return "", err
 		}
@@ -80,9 +84,9 @@ func (s *DDAnSandbox) Submit(file bool, content string) (string, error) {
 		return sha1, nil
 	}
 	if file {
-		err = s.analyzer.UploadSampleEx(context.TODO(), content, filepath.Base(content), sha1)
+		err = s.analyzer.UploadSampleEx(ctx, content, filepath.Base(content), sha1)
 	} else {
-		err = s.analyzer.UploadSampleURLs(context.TODO(), []string{content})
+		err = s.analyzer.UploadSampleURLs(ctx, []string{content})
 	}
 	if err != nil {
 		return "", err
@@ -90,15 +94,73 @@ func (s *DDAnSandbox) Submit(file bool, content string) (string, error) {
 	return sha1, nil
 }
 
-func (s *DDAnSandbox) GetResult(id string) (RiskLevel, string, error) {
-	briefReports, err := s.analyzer.GetBriefReport(context.TODO(), []string{id})
+// Lookup - check whether Analyzer already has the sample. Samples are
+// identified by SHA1 on Analyzer, so it is returned as ID
+func (s *DDAnSandbox) Lookup(ctx context.Context, sha1, sha256 string, since time.Time) (string, error) {
+	days := int(time.Since(since).Hours()/24) + 1
+	sha1List, err := s.analyzer.CheckDuplicateSample(ctx, []string{sha1}, days)
+	if err != nil {
+		var apiErr *ddan.APIError
+		if errors.As(err, &apiErr) && apiErr.Response == ddan.ResponseNotRegistered {
+			return "", nil
+		}
+		return "", err
+	}
+	if len(sha1List) != 1 {
+		return "", nil
+	}
+	return sha1, nil
+}
+
+// AnalysisTime - average total processing time (including queueing) for the
+// shortest period Analyzer has statistics for
+func (s *DDAnSandbox) AnalysisTime(ctx context.Context) (time.Duration, error) {
+	stats, err := s.analyzer.GetStats(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("GetStats: %w", err)
+	}
+	avg := stats.AvgTotalProcessingTime
+	for _, seconds := range []int{avg.Last4Hours, avg.Last24Hours, avg.Last7Days, avg.Last30Days, avg.Last90Days} {
+		if seconds > 0 {
+			return time.Duration(seconds) * time.Second, nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *DDAnSandbox) GetResult(ctx context.Context, id string) (RiskLevel, string, error) {
+	briefReports, err := s.analyzer.GetBriefReport(ctx, []string{id})
 	if err != nil {
 		return RiskLevelUnknown, "", fmt.Errorf("GetBriefReport: %w", err)
 	}
 	if len(briefReports.Reports) != 1 {
 		return RiskLevelUnknown, "", fmt.Errorf("%s: %w: wrong brief report length", id, ErrError)
 	}
-	briefReport := briefReports.Reports[0]
+	return s.result(ctx, id, briefReports, 0)
+}
+
+// GetResults - get brief reports of all samples in one request. Brief
+// reports go in the same order as requested SHA1 values
+func (s *DDAnSandbox) GetResults(ctx context.Context, ids []string, since time.Time) (map[string]Result, error) {
+	briefReports, err := s.analyzer.GetBriefReport(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("GetBriefReport: %w", err)
+	}
+	if len(briefReports.Reports) != len(ids) {
+		return nil, fmt.Errorf("%w: wrong brief report length: %d instead of %d", ErrError, len(briefReports.Reports), len(ids))
+	}
+	results := make(map[string]Result, len(ids))
+	for i, id := range ids {
+		riskLevel, threat, err := s.result(ctx, id, briefReports, i)
+		results[id] = Result{RiskLevel: riskLevel, Threat: threat, Err: err}
+	}
+	return results, nil
+}
+
+// result - verdict from i-th brief report. Threat name is requested
+// separately for samples with risk found
+func (s *DDAnSandbox) result(ctx context.Context, id string, briefReports *ddan.BriefReports, i int) (RiskLevel, string, error) {
+	briefReport := briefReports.Reports[i]
 	switch briefReport.SampleStatus {
 	case ddan.StatusNotFound:
 		return RiskLevelUnknown, "", fmt.Errorf("%s: %w", id, ErrNotFound)
@@ -124,7 +186,7 @@ func (s *DDAnSandbox) GetResult(id string) (RiskLevel, string, error) {
 	if briefReport.RiskLevel < 0 {
 		return RiskLevelUnknown, "", fmt.Errorf("%s: %w: %v", id, ErrError, briefReport.RiskLevel)
 	}
-	reports, err := s.analyzer.GetReport(context.TODO(), id)
+	reports, err := s.analyzer.GetReport(ctx, id)
 	if err != nil {
 		return RiskLevelError, "", fmt.Errorf("GetReport(%s): %w", id, err)
 	}
@@ -145,21 +207,21 @@ func (s *DDAnSandbox) GetResult(id string) (RiskLevel, string, error) {
 	}
 }
 
-func (s *DDAnSandbox) GetReport(id string, filePath string) error {
-	return GetFile(id, filePath, s.analyzer.GetPDFReport)
+func (s *DDAnSandbox) GetReport(ctx context.Context, id string, filePath string) error {
+	return GetFile(ctx, id, filePath, s.analyzer.GetPDFReport)
 }
 
-func (s *DDAnSandbox) GetInvestigation(id string, filePath string) error {
-	return GetFile(id, filePath, s.analyzer.GetPackage)
+func (s *DDAnSandbox) GetInvestigation(ctx context.Context, id string, filePath string) error {
+	return GetFile(ctx, id, filePath, s.analyzer.GetPackage)
 }
 
-func GetFile(id string, filePath string, apiCall func(context.Context, string) (io.Reader, error)) error {
+func GetFile(ctx context.Context, id string, filePath string, apiCall func(context.Context, string) (io.Reader, error)) error {
 	f, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
-	reader, err := apiCall(context.TODO(), id)
+	reader, err := apiCall(ctx, id)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,432 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+mock.go
+
+Sandbox that does not need any service: verdicts are chosen by rules
+*/
+package sandbox
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// EICAR - antivirus test file signature
+const EICAR = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// eicarSearchSize - how many bytes of file are searched for EICAR signature
+const eicarSearchSize = 4096
+
+type MockStage int
+
+const (
+	MockStageNone MockStage = iota
+	MockStageSubmit
+	MockStageResult
+	MockStageReport
+	MockStageInvestigation
+)
+
+var mockStageString = [...]string{
+	"",
+	"submit",
+	"result",
+	"report",
+	"investigation",
+}
+
+func (s MockStage) String() string {
+	return mockStageString[s]
+}
+
+var ErrUnknownMockStage = errors.New("unknown stage")
+
+func ParseMockStage(v string) (MockStage, error) {
+	for i, s := range mockStageString {
+		if strings.EqualFold(s, strings.TrimSpace(v)) {
+			return MockStage(i), nil
+		}
+	}
+	return MockStageNone, fmt.Errorf("%w: %s", ErrUnknownMockStage, v)
+}
+
+// ErrMock - error returned on stage requested by rule
+var ErrMock = errors.New("simulated error")
+
+// MockRule - verdict for objects matching all non empty conditions
+type MockRule struct {
+	Hash      string // MD5, SHA1 or SHA256 of file
+	Pattern   string // filepath.Match pattern for file name or URL
+	EICAR     bool   // file contains EICAR signature
+	RiskLevel RiskLevel
+	Threat    string
+	Fail      MockStage // stage to return error on
+	Transient bool      // error on Fail stage is transient
+}
+
+// ParseMockRule - create rule from its configuration file representation
+func ParseMockRule(hash, pattern string, eicar bool, verdict, threat, fail string) (MockRule, error) {
+	riskLevel, err := ParseRiskLevel(verdict)
+	if err != nil {
+		return MockRule{}, err
+	}
+	stage, err := ParseMockStage(fail)
+	if err != nil {
+		return MockRule{}, err
+	}
+	return MockRule{
+		Hash:      hash,
+		Pattern:   pattern,
+		EICAR:     eicar,
+		RiskLevel: riskLevel,
+		Threat:    threat,
+		Fail:      stage,
+	}, nil
+}
+
+func (r *MockRule) Match(name string, hashes []string, eicar bool) bool {
+	if r.Hash == "" && r.Pattern == "" && !r.EICAR {
+		return false
+	}
+	if r.EICAR && !eicar {
+		return false
+	}
+	if r.Pattern != "" {
+		match, err := filepath.Match(r.Pattern, name)
+		if err != nil || !match {
+			return false
+		}
+	}
+	if r.Hash != "" {
+		found := false
+		for _, h := range hashes {
+			if strings.EqualFold(h, r.Hash) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type MockSandbox struct {
+	rules            []MockRule
+	defaultRiskLevel RiskLevel
+	latency          time.Duration
+	analysisTime     time.Duration
+}
+
+var _ Sandbox = &MockSandbox{}
+var _ Estimate = &MockSandbox{}
+var _ BatchSandbox = &MockSandbox{}
+
+func NewMockSandbox(defaultRiskLevel RiskLevel, latency, analysisTime time.Duration, rules []MockRule) *MockSandbox {
+	return &MockSandbox{
+		rules:            rules,
+		defaultRiskLevel: defaultRiskLevel,
+		latency:          latency,
+		analysisTime:     analysisTime,
+	}
+}
+
+// mockID - whole state of submission is kept in its ID, so results can be
+// requested from other MockSandbox object, even after restart
+type mockID struct {
+	Name      string    `json:"n"`
+	RiskLevel RiskLevel `json:"r"`
+	Threat    string    `json:"t,omitempty"`
+	Ready     int64     `json:"d"`
+	Fail      MockStage `json:"f,omitempty"`
+	Transient bool      `json:"x,omitempty"`
+}
+
+func (m *mockID) String() string {
+	data, _ := json.Marshal(m)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// err - simulated error for Fail stage
+func (m *mockID) err() error {
+	err := fmt.Errorf("%s: %w", m.Name, ErrMock)
+	if m.Transient {
+		return Transient(err)
+	}
+	return err
+}
+
+func parseMockID(id string) (*mockID, error) {
+	data, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+	}
+	var m mockID
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", id, ErrNotFound)
+	}
+	return &m, nil
+}
+
+func (s *MockSandbox) SubmitURL(ctx context.Context, url string) (string, error) {
+	if err := s.wait(ctx); err != nil {
+		return "", err
+	}
+	return s.submit(url, nil, false)
+}
+
+func (s *MockSandbox) SubmitFile(ctx context.Context, filePath string) (string, error) {
+	if err := s.wait(ctx); err != nil {
+		return "", err
+	}
+	hashes, eicar, err := s.inspect(filePath)
+	if err != nil {
+		return "", err
+	}
+	return s.submit(filepath.Base(filePath), hashes, eicar)
+}
+
+func (s *MockSandbox) submit(name string, hashes []string, eicar bool) (string, error) {
+	id := &mockID{
+		Name:      name,
+		RiskLevel: s.defaultRiskLevel,
+		Ready:     time.Now().Add(s.analysisTime).Unix(),
+	}
+	for _, rule := range s.rules {
+		if !rule.Match(name, hashes, eicar) {
+			continue
+		}
+		id.RiskLevel = rule.RiskLevel
+		id.Threat = rule.Threat
+		id.Fail = rule.Fail
+		id.Transient = rule.Transient
+		break
+	}
+	if id.Fail == MockStageSubmit {
+		return "", id.err()
+	}
+	return id.String(), nil
+}
+
+func (s *MockSandbox) inspect(filePath string) ([]string, bool, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	return Inspect(f)
+}
+
+// Inspect - calculate MD5, SHA1 and SHA256 (in this order) of the content
+// and check it for EICAR signature
+func Inspect(r io.Reader) ([]string, bool, error) {
+	hashers := []hash.Hash{md5.New(), sha1.New(), sha256.New()}
+	head := &bytes.Buffer{}
+	writers := []io.Writer{&limitedWriter{head, eicarSearchSize}}
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, false, err
+	}
+	var hashes []string
+	for _, h := range hashers {
+		hashes = append(hashes, hex.EncodeToString(h.Sum(nil)))
+	}
+	return hashes, bytes.Contains(head.Bytes(), []byte(EICAR)), nil
+}
+
+func (s *MockSandbox) GetResult(ctx context.Context, id string) (RiskLevel, string, error) {
+	if err := s.wait(ctx); err != nil {
+		return RiskLevelUnknown, "", err
+	}
+	return s.result(id)
+}
+
+func (s *MockSandbox) result(id string) (RiskLevel, string, error) {
+	m, err := parseMockID(id)
+	if err != nil {
+		return RiskLevelUnknown, "", err
+	}
+	if time.Now().Unix() < m.Ready {
+		return RiskLevelNotReady, "", nil
+	}
+	if m.Fail == MockStageResult {
+		return RiskLevelError, "", m.err()
+	}
+	return m.RiskLevel, m.Threat, nil
+}
+
+// GetResults - results of several submissions with single latency
+func (s *MockSandbox) GetResults(ctx context.Context, ids []string, since time.Time) (map[string]Result, error) {
+	if err := s.wait(ctx); err != nil {
+		return nil, err
+	}
+	results := make(map[string]Result, len(ids))
+	for _, id := range ids {
+		riskLevel, threat, err := s.result(id)
+		results[id] = Result{RiskLevel: riskLevel, Threat: threat, Err: err}
+	}
+	return results, nil
+}
+
+func (s *MockSandbox) GetReport(ctx context.Context, id string, filePath string) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	m, err := parseMockID(id)
+	if err != nil {
+		return err
+	}
+	if m.Fail == MockStageReport {
+		return m.err()
+	}
+	return os.WriteFile(filePath, PlaceholderPDF(m.lines()...), 0644)
+}
+
+func (s *MockSandbox) GetInvestigation(ctx context.Context, id string, filePath string) error {
+	if err := s.wait(ctx); err != nil {
+		return err
+	}
+	m, err := parseMockID(id)
+	if err != nil {
+		return err
+	}
+	if m.Fail == MockStageInvestigation {
+		return m.err()
+	}
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return PlaceholderZIP(f, m.lines()...)
+}
+
+// AnalysisTime - mock analysis always takes the same time
+func (s *MockSandbox) AnalysisTime(ctx context.Context) (time.Duration, error) {
+	if err := s.wait(ctx); err != nil {
+		return 0, err
+	}
+	return s.analysisTime, nil
+}
+
+// wait - emulate network latency. Returns error if ctx is done meanwhile
+func (s *MockSandbox) wait(ctx context.Context) error {
+	if s.latency <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(s.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (m *mockID) lines() []string {
+	return ReportLines(m.Name, m.RiskLevel, m.Threat)
+}
+
+// ReportLines - text of placeholder report
+func ReportLines(name string, riskLevel RiskLevel, threat string) []string {
+	lines := []string{
+		"Sandboxer Mock Sandbox Report",
+		"Object: " + name,
+		"Risk Level: " + riskLevel.String(),
+	}
+	if threat != "" {
+		lines = append(lines, "Threat: "+threat)
+	}
+	return lines
+}
+
+// PlaceholderPDF - single page PDF with given lines of text
+func PlaceholderPDF(lines ...string) []byte {
+	var content strings.Builder
+	content.WriteString("BT /F1 14 Tf 72 760 Td 20 TL\n")
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) '\n", pdfEscape(line))
+	}
+	content.WriteString("ET\n")
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 4 0 R >> >> /Contents 5 0 R >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = pdf.Len()
+		fmt.Fprintf(&pdf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := pdf.Len()
+	fmt.Fprintf(&pdf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&pdf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&pdf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return pdf.Bytes()
+}
+
+func pdfEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`).Replace(s)
+}
+
+// PlaceholderZIP - investigation package with single text file. Unlike
+// real one, it is not encrypted
+func PlaceholderZIP(w io.Writer, lines ...string) error {
+	archive := zip.NewWriter(w)
+	f, err := archive.Create("report.txt")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, strings.Join(lines, "\n")+"\n"); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+// limitedWriter - keeps only first limit bytes
+type limitedWriter struct {
+	w     io.Writer
+	limit int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if l.limit <= 0 {
+		return n, nil
+	}
+	if len(p) > l.limit {
+		p = p[:l.limit]
+	}
+	l.limit -= len(p)
+	if _, err := l.w.Write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
@@ -0,0 +1,131 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+mock_test.go
+
+Test mock sandbox
+*/
+package sandbox
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMockSandbox(t *testing.T) {
+	folder := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	rules := []MockRule{
+		{EICAR: true, RiskLevel: RiskLevelHigh, Threat: "Eicar_test_file"},
+		// SHA1 of "Hello World!"
+		{Hash: "2EF7BDE608CE5404E97D5F042F95F89F1C232871", RiskLevel: RiskLevelMedium, Threat: "Hello"},
+		{Pattern: "*.txt", RiskLevel: RiskLevelUnsupported},
+		{Pattern: "*.bad", RiskLevel: RiskLevelLow, Fail: MockStageReport},
+		{Pattern: "*.fail", Fail: MockStageSubmit},
+		{Pattern: "http://low.*", RiskLevel: RiskLevelLow},
+	}
+	mock := NewMockSandbox(RiskLevelNoRisk, 0, 0, rules)
+	testCases := []struct {
+		name      string
+		submit    func() (string, error)
+		riskLevel RiskLevel
+		threat    string
+	}{
+		{"eicar", func() (string, error) { return mock.SubmitFile(context.TODO(), write("eicar.com", EICAR)) }, RiskLevelHigh, "Eicar_test_file"},
+		{"hash", func() (string, error) { return mock.SubmitFile(context.TODO(), write("hello.exe", "Hello World!")) }, RiskLevelMedium, "Hello"},
+		{"pattern", func() (string, error) { return mock.SubmitFile(context.TODO(), write("readme.txt", "text")) }, RiskLevelUnsupported, ""},
+		{"default", func() (string, error) { return mock.SubmitFile(context.TODO(), write("clean.exe", "MZ")) }, RiskLevelNoRisk, ""},
+		{"url", func() (string, error) { return mock.SubmitURL(context.TODO(), "http://low.example.com") }, RiskLevelLow, ""},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			id, err := tCase.submit()
+			if err != nil {
+				t.Fatal(err)
+			}
+			riskLevel, threat, err := mock.GetResult(context.TODO(), id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if riskLevel != tCase.riskLevel || threat != tCase.threat {
+				t.Errorf("Expected %v (%s), but got %v (%s)", tCase.riskLevel, tCase.threat, riskLevel, threat)
+			}
+		})
+	}
+	t.Run("submit error", func(t *testing.T) {
+		_, err := mock.SubmitFile(context.TODO(), write("sample.fail", "MZ"))
+		if !errors.Is(err, ErrMock) {
+			t.Errorf("Expected ErrMock, but got %v", err)
+		}
+	})
+	t.Run("report error", func(t *testing.T) {
+		id, err := mock.SubmitFile(context.TODO(), write("sample.bad", "MZ"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := mock.GetReport(context.TODO(), id, filepath.Join(folder, "bad.pdf")); !errors.Is(err, ErrMock) {
+			t.Errorf("Expected ErrMock, but got %v", err)
+		}
+	})
+	t.Run("not ready", func(t *testing.T) {
+		slow := NewMockSandbox(RiskLevelNoRisk, 0, time.Hour, nil)
+		id, err := slow.SubmitURL(context.TODO(), "http://www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		riskLevel, _, err := slow.GetResult(context.TODO(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != RiskLevelNotReady {
+			t.Errorf("Expected %v, but got %v", RiskLevelNotReady, riskLevel)
+		}
+	})
+	t.Run("wrong id", func(t *testing.T) {
+		if _, _, err := mock.GetResult(context.TODO(), "!"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, but got %v", err)
+		}
+	})
+	t.Run("report", func(t *testing.T) {
+		id, err := mock.SubmitURL(context.TODO(), "http://www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		reportPath := filepath.Join(folder, "report.pdf")
+		if err := mock.GetReport(context.TODO(), id, reportPath); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(data, []byte("%PDF-")) || !bytes.Contains(data, []byte("www.example.com")) {
+			t.Errorf("Wrong report: %s", data)
+		}
+		investigationPath := filepath.Join(folder, "investigation.zip")
+		if err := mock.GetInvestigation(context.TODO(), id, investigationPath); err != nil {
+			t.Fatal(err)
+		}
+		archive, err := zip.OpenReader(investigationPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer archive.Close()
+		if len(archive.File) != 1 {
+			t.Errorf("Expected 1 file, but got %d", len(archive.File))
+		}
+	})
+}
@@ -65,24 +65,38 @@ func (r RiskLevel) Color() color.Color {
 
 var ErrUnknownRiskLevel = errors.New("unknown risk level")
 
+// ParseRiskLevel - parse risk level name. " Risk" suffix can be omitted and
+// "Unsupported" can be used instead of "Not Analyzed"
+func ParseRiskLevel(v string) (RiskLevel, error) {
+	v = strings.TrimSpace(v)
+	if strings.EqualFold(v, "unsupported") {
+		return RiskLevelUnsupported, nil
+	}
+	for i, s := range RiskLevelString {
+		if strings.EqualFold(s, v) || strings.EqualFold(s, v+" Risk") {
+			return RiskLevel(i), nil
+		}
+	}
+	return RiskLevelUnknown, fmt.Errorf("%w: %s", ErrUnknownRiskLevel, v)
+}
+
 // UnmarshalJSON implements the Unmarshaler interface of the json package for RiskLevel.
 func (r *RiskLevel) UnmarshalJSON(data []byte) error {
 	var v string
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	for i, s := range RiskLevelString {
-		if strings.EqualFold(s, v) {
-			*r = RiskLevel(i)
-			return nil
-		}
+	result, err := ParseRiskLevel(v)
+	if err != nil {
+		return err
 	}
-	return fmt.Errorf("%w: %s", ErrUnknownRiskLevel, v)
+	*r = result
+	return nil
 }
 
 // MarshalJSON implements the Marshaler interface of the json package for RiskLevel.
 func (r RiskLevel) MarshalJSON() ([]byte, error) {
-	if r < 0 || r >= RiskLevelError {
+	if r < 0 || r > RiskLevelError {
 		return nil, ErrUnknownRiskLevel
 	}
 	return []byte(fmt.Sprintf("\"%s\"", r.String())), nil
@@ -8,7 +8,11 @@ Unified sandbox interface
 */
 package sandbox
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"time"
+)
 
 var (
 	ErrUnsupported = errors.New("unsupported")
@@ -20,10 +24,43 @@ var (
 	ErrError    = errors.New("error")
 )
 
+// Sandbox - sandbox service. All calls are aborted when ctx is done
 type Sandbox interface {
-	SubmitURL(url string) (string, error)
-	SubmitFile(filePath string) (string, error)
-	GetResult(id string) (RiskLevel, string, error)
-	GetReport(id string, filePath string) error
-	GetInvestigation(id string, filePath string) error
+	SubmitURL(ctx context.Context, url string) (string, error)
+	SubmitFile(ctx context.Context, filePath string) (string, error)
+	GetResult(ctx context.Context, id string) (RiskLevel, string, error)
+	GetReport(ctx context.Context, id string, filePath string) error
+	GetInvestigation(ctx context.Context, id string, filePath string) error
+}
+
+// Lookup - sandbox that can find previous analysis of the same object.
+// Returns ID of found submission or empty string
+type Lookup interface {
+	Lookup(ctx context.Context, sha1, sha256 string, since time.Time) (string, error)
+}
+
+// Quota - sandbox that limits number of submissions per day. Returns number
+// of submissions that are left till daily reset
+type Quota interface {
+	Quota(ctx context.Context) (int, error)
+}
+
+// Estimate - sandbox that knows how long analysis usually takes. Returns
+// zero if there is no statistics
+type Estimate interface {
+	AnalysisTime(ctx context.Context) (time.Duration, error)
+}
+
+// Result - verdict of one submission. Err is set if this particular
+// submission has failed
+type Result struct {
+	RiskLevel RiskLevel
+	Threat    string
+	Err       error
+}
+
+// BatchSandbox - sandbox that can check many submissions in one request.
+// Submissions are uploaded not earlier than since
+type BatchSandbox interface {
+	GetResults(ctx context.Context, ids []string, since time.Time) (map[string]Result, error)
 }
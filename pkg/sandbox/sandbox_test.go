@@ -131,9 +131,9 @@ func SubmitTest(file bool, filePath string, expectedRisk RiskLevel, sandbox Sand
 	var id string
 	var err error
 	if file {
-		id, err = sandbox.SubmitFile(filePath)
+		id, err = sandbox.SubmitFile(context.TODO(), filePath)
 	} else {
-		id, err = sandbox.SubmitURL(filePath)
+		id, err = sandbox.SubmitURL(context.TODO(), filePath)
 	}
 	//t.Logf("%p.Submit(%s): %s, %v", sandbox, filePath, id, err)
 	if err != nil {
@@ -141,7 +141,7 @@ func SubmitTest(file bool, filePath string, expectedRisk RiskLevel, sandbox Sand
 	}
 	for i := 0; i < 240; i++ {
 		time.Sleep(5 * time.Second)
-		riskeLevel, virusName, err := sandbox.GetResult(id)
+		riskeLevel, virusName, err := sandbox.GetResult(context.TODO(), id)
 		if err != nil {
 			t.Fatal(err)
 		}
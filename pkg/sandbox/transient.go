@@ -0,0 +1,107 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+transient.go
+
+Tell errors worth retrying from permanent ones
+*/
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/mpkondrashin/vone"
+)
+
+// TransientError - error that can go away if request is repeated later
+type TransientError struct {
+	Err error
+}
+
+// Transient - mark err as transient
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// transientStatuses - HTTP statuses of overloaded or temporarily
+// unavailable server
+var transientStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// IsTransient - check whether request that returned err should be retried.
+// Timeouts, reset or refused connections, connections closed before
+// response and HTTP statuses of overloaded or unavailable server (429 and
+// 5xx) are transient. Other network errors, like failed certificate
+// verification or unknown host, cancelled requests and errors reported by
+// sandbox for particular object are permanent
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	var transientErr *TransientError
+	if errors.As(err, &transientErr) {
+		return true
+	}
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	if transientStatus(err) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// transientStatus - check whether err reports transient HTTP status.
+// Vision One SDK keeps status as error code of response body. Statuses it
+// has no code for, like 502, 503 and 504, fail to parse as unknown error
+// code. DDAn SDK reports status line of response it did not expect
+func transientStatus(err error) bool {
+	var vOneErr *vone.Error
+	if errors.As(err, &vOneErr) {
+		switch vOneErr.ErrorData.Code {
+		case vone.ErrorCodeTooManyRequests, vone.ErrorCodeInternalServerError:
+			return true
+		}
+		return false
+	}
+	if errors.Is(err, vone.ErrUnknownErrorCode) {
+		return true
+	}
+	message := err.Error()
+	for _, code := range transientStatuses {
+		if strings.Contains(message, strconv.Itoa(code)+" "+http.StatusText(code)) {
+			return true
+		}
+	}
+	return false
+}
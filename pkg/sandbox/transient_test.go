@@ -0,0 +1,86 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+transient_test.go
+
+Test errors classification
+*/
+package sandbox
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mpkondrashin/ddan"
+	"github.com/mpkondrashin/vone"
+)
+
+func TestIsTransient(t *testing.T) {
+	tooMany := new(vone.Error)
+	tooMany.ErrorData.Code = vone.ErrorCodeTooManyRequests
+	internal := new(vone.Error)
+	internal.ErrorData.Code = vone.ErrorCodeInternalServerError
+	badRequest := new(vone.Error)
+	badRequest.ErrorData.Code = vone.ErrorCodeBadRequest
+	// Vision One SDK parses body of response with status it has no code for
+	vOneStatus := func(code string) error {
+		body := `{"error":{"code":"` + code + `","message":"Try again later"}}`
+		if err := json.Unmarshal([]byte(body), new(vone.Error)); err != nil {
+			return fmt.Errorf("parse error: %w", err)
+		}
+		return fmt.Errorf("request error: %s", code)
+	}
+	ddanStatus := func(status int) error {
+		return fmt.Errorf("GetBriefReport: %w", &ddan.APIError{Response: ddan.Response(fmt.Sprintf("%d %s", status, http.StatusText(status)))})
+	}
+	testCases := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"nil", nil, false},
+		{"plain", errors.New("error"), false},
+		{"marked", Transient(errors.New("error")), true},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), true},
+		{"cancelled", fmt.Errorf("call: %w", context.Canceled), false},
+		{"refused", fmt.Errorf("HTTP request: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), true},
+		{"reset", fmt.Errorf("HTTP request: %w", &net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), true},
+		{"eof", &url.Error{Op: "Post", URL: "https://host", Err: io.EOF}, true},
+		{"timeout", &url.Error{Op: "Get", URL: "https://host", Err: &net.DNSError{Err: "timeout", IsTimeout: true}}, true},
+		{"unknown authority", &url.Error{Op: "Get", URL: "https://host", Err: x509.UnknownAuthorityError{}}, false},
+		{"unknown host", &url.Error{Op: "Get", URL: "https://host", Err: &net.DNSError{Err: "no such host", Name: "host", IsNotFound: true}}, false},
+		{"network", fmt.Errorf("HTTP request: %w", &net.OpError{Op: "dial", Err: errors.New("network is unreachable")}), false},
+		{"internal server error", fmt.Errorf("request error: %w", internal), true},
+		{"too many requests", fmt.Errorf("request error: %w", tooMany), true},
+		{"bad request", fmt.Errorf("request error: %w", badRequest), false},
+		{"vone 502", vOneStatus("BadGateway"), true},
+		{"vone 503", vOneStatus("ServiceUnavailable"), true},
+		{"vone 504", vOneStatus("GatewayTimeout"), true},
+		{"ddan 500", ddanStatus(http.StatusInternalServerError), true},
+		{"ddan 502", ddanStatus(http.StatusBadGateway), true},
+		{"ddan 503", ddanStatus(http.StatusServiceUnavailable), true},
+		{"ddan 504", ddanStatus(http.StatusGatewayTimeout), true},
+		{"ddan 429", fmt.Errorf("upload: unexpected status %s", "429 Too Many Requests"), true},
+		{"ddan 404", ddanStatus(http.StatusNotFound), false},
+		{"ddan not registered", &ddan.APIError{Response: ddan.ResponseNotRegistered}, false},
+		{"not found", ErrNotFound, false},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			if got := IsTransient(tCase.err); got != tCase.transient {
+				t.Errorf("%v: expected %v, got %v", tCase.err, tCase.transient, got)
+			}
+		})
+	}
+}
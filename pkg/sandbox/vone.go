@@ -10,8 +10,10 @@ package sandbox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/mpkondrashin/vone"
 )
@@ -21,6 +23,9 @@ type VOneSandbox struct {
 }
 
 var _ Sandbox = &VOneSandbox{}
+var _ Lookup = &VOneSandbox{}
+var _ Quota = &VOneSandbox{}
+var _ BatchSandbox = &VOneSandbox{}
 
 func NewVOneSandbox(vOne *vone.VOne) *VOneSandbox {
 	return &VOneSandbox{
@@ -28,9 +33,9 @@ func NewVOneSandbox(vOne *vone.VOne) *VOneSandbox {
 	}
 }
 
-func (s *VOneSandbox) SubmitURL(url string) (string, error) {
+func (s *VOneSandbox) SubmitURL(ctx context.Context, url string) (string, error) {
 	f := s.vOne.SandboxSubmitURLs().AddURL(url)
-	response, _, err := f.Do(context.TODO())
+	response, _, err := f.Do(ctx)
 	if err != nil {
 		return "", err
 	}
@@ -40,36 +45,76 @@ func (s *VOneSandbox) SubmitURL(url string) (string, error) {
 	}
 	return response[0].Body.ID, nil
 }
-func (s *VOneSandbox) SubmitFile(filePath string) (string, error) {
+func (s *VOneSandbox) SubmitFile(ctx context.Context, filePath string) (string, error) {
 	f, err := s.vOne.SandboxSubmitFile().SetFilePath(filePath)
 	if err != nil {
-		return "", fmt.Errorf("Vision One ", err)
+		return "", fmt.Errorf("Vision One: %w", err)
 	}
-	response, _, err := f.Do(context.TODO())
+	response, _, err := f.Do(ctx)
 	if err != nil {
 		return "", err
 	}
 	return response.ID, nil
 }
 
-func (s *VOneSandbox) GetResult(id string) (RiskLevel, string, error) {
-	status, err := s.vOne.SandboxSubmissionStatus(id).Do(context.TODO())
+func (s *VOneSandbox) GetResult(ctx context.Context, id string) (RiskLevel, string, error) {
+	status, err := s.vOne.SandboxSubmissionStatus(id).Do(ctx)
 	if err != nil {
 		return RiskLevelUnknown, "", fmt.Errorf("SandboxSubmissionStatus(%s): %w", id, err)
 	}
-	switch status.Status {
+	return s.result(ctx, id, status.Status, status.Error.Code, status.Error.Message)
+}
+
+// GetResults - get statuses of all submissions from submissions list, so
+// analysis results are requested only for finished ones. Submissions that
+// are missing in the list are checked one by one
+func (s *VOneSandbox) GetResults(ctx context.Context, ids []string, since time.Time) (map[string]Result, error) {
+	pending := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		pending[id] = true
+	}
+	results := make(map[string]Result, len(ids))
+	f := s.vOne.SandboxListSubmissions()
+	if !since.IsZero() {
+		f.StartDateTime(since.UTC())
+	}
+	err := f.IterateListSubmissions(ctx, func(item *vone.ListSubmissionsItem) error {
+		if !pending[item.ID] {
+			return nil
+		}
+		delete(pending, item.ID)
+		riskLevel, threat, err := s.result(ctx, item.ID, item.Status, item.Error.Code, item.Error.Message)
+		results[item.ID] = Result{RiskLevel: riskLevel, Threat: threat, Err: err}
+		if len(pending) == 0 {
+			return errFound
+		}
+		return ctx.Err()
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return nil, fmt.Errorf("SandboxListSubmissions: %w", err)
+	}
+	for id := range pending {
+		riskLevel, threat, err := s.GetResult(ctx, id)
+		results[id] = Result{RiskLevel: riskLevel, Threat: threat, Err: err}
+	}
+	return results, nil
+}
+
+// result - verdict of submission with given status
+func (s *VOneSandbox) result(ctx context.Context, id string, status vone.Status, code, message string) (RiskLevel, string, error) {
+	switch status {
 	case vone.StatusSucceeded:
 	case vone.StatusRunning:
 		return RiskLevelNotReady, "", nil
 	case vone.StatusFailed:
-		if status.Error.Code == "Unsupported" {
-			return RiskLevelUnsupported, "", fmt.Errorf("%w: %s", ErrUnsupported, status.Error.Message)
+		if code == "Unsupported" {
+			return RiskLevelUnsupported, "", fmt.Errorf("%w: %s", ErrUnsupported, message)
 		}
-		return RiskLevelError, "", fmt.Errorf("%s: %w: %s %s", id, ErrError, status.Error.Code, status.Error.Message)
+		return RiskLevelError, "", fmt.Errorf("%s: %w: %s %s", id, ErrError, code, message)
 	default:
-		return RiskLevelError, "", fmt.Errorf("%v: %w", status, ErrUnknownRiskLevel)
+		return RiskLevelError, "", fmt.Errorf("%s: %v: %w", id, status, ErrUnknownRiskLevel)
 	}
-	results, err := s.vOne.SandboxAnalysisResults(id).Do(context.TODO())
+	results, err := s.vOne.SandboxAnalysisResults(id).Do(ctx)
 	if err != nil {
 		return RiskLevelUnknown, "", fmt.Errorf("SandboxAnalysisResults(%s): %w", id, err)
 	}
@@ -91,9 +136,46 @@ func (s *VOneSandbox) GetResult(id string) (RiskLevel, string, error) {
 	}
 }
 
-func (s *VOneSandbox) GetReport(id string, filePath string) error {
-	return s.vOne.SandboxDownloadResults(id).Store(context.TODO(), filePath)
+func (s *VOneSandbox) GetReport(ctx context.Context, id string, filePath string) error {
+	return s.vOne.SandboxDownloadResults(id).Store(ctx, filePath)
 }
-func (s *VOneSandbox) GetInvestigation(id string, filePath string) error {
-	return s.vOne.SandboxInvestigationPackage(id).Store(context.TODO(), filePath)
+func (s *VOneSandbox) GetInvestigation(ctx context.Context, id string, filePath string) error {
+	return s.vOne.SandboxInvestigationPackage(id).Store(ctx, filePath)
+}
+
+var errFound = errors.New("found")
+
+// Lookup - find successful submission with the same SHA256. Submissions
+// are filtered by Vision One, so only ones of this sample are listed.
+// Does not use daily reserve
+func (s *VOneSandbox) Lookup(ctx context.Context, sha1, sha256 string, since time.Time) (string, error) {
+	id := ""
+	err := s.vOne.SandboxListSubmissions().
+		StartDateTime(since.UTC()).
+		Filter(SHA256Filter(sha256)).
+		IterateListSubmissions(ctx, func(item *vone.ListSubmissionsItem) error {
+			if item.Status != vone.StatusSucceeded || !strings.EqualFold(item.Digest.SHA256, sha256) {
+				return nil
+			}
+			id = item.ID
+			return errFound
+		})
+	if err != nil && !errors.Is(err, errFound) {
+		return "", fmt.Errorf("SandboxListSubmissions: %w", err)
+	}
+	return id, nil
+}
+
+// SHA256Filter - list submissions filter that matches given SHA256
+func SHA256Filter(sha256 string) string {
+	return "sha256 eq '" + strings.ToLower(sha256) + "'"
+}
+
+// Quota - remaining count of daily reserve
+func (s *VOneSandbox) Quota(ctx context.Context) (int, error) {
+	reserve, err := s.vOne.SandboxDailyReserve().Do(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("SandboxDailyReserve: %w", err)
+	}
+	return reserve.SubmissionRemainingCount, nil
 }
@@ -16,52 +16,68 @@ import (
 	"strings"
 )
 
-const (
-	ChannelSize = 1000
-)
-
 type Channel int
 
 const (
 	ChPrefilter Channel = iota
+	ChLookup
+	ChQuota
 	ChSubmit
 	//	ChWait
 	ChResult
 	ChReport
 	ChInvestigation
+	ChMembers
 	ChDone
+	ChPaused
+	ChCancelled
 )
 
 var ChannelString = [...]string{
 	"Prefilter",
+	"Known Verdict Lookup",
+	"Waiting for quota",
 	"Submission",
 	//	"Wait",
 	"Wait For Result",
 	"Get Report",
 	"Get Investigation",
+	"Wait For Members",
 	"Done",
+	"Paused",
+	"Cancelled",
 }
 
 // String - return string representation for State value
 func (c Channel) String() string {
-	if c < 0 || c > ChDone {
+	if c < 0 || int(c) >= len(ChannelString) {
 		return "Channel(" + strconv.FormatInt(int64(c), 10) + ")"
 	}
 	return ChannelString[c]
 }
 
+// Queued - task with this channel waits for one of dispatchers
+func (c Channel) Queued() bool {
+	return c >= ChPrefilter && c < ChDone
+}
+
 // ErrUnknownState - will be returned wrapped when parsing string
 // containing unrecognized value.
 var ErrUnknownChannel = errors.New("unknown Channel")
 
 var mapChannelFromString = map[string]Channel{
 	ChannelString[ChPrefilter]: ChPrefilter,
+	ChannelString[ChLookup]:    ChLookup,
+	ChannelString[ChQuota]:     ChQuota,
 	ChannelString[ChSubmit]:    ChSubmit,
 	//	ChannelString[ChWait]:          ChWait,
 	ChannelString[ChResult]:        ChResult,
 	ChannelString[ChReport]:        ChReport,
 	ChannelString[ChInvestigation]: ChInvestigation,
+	ChannelString[ChMembers]:       ChMembers,
 	ChannelString[ChDone]:          ChDone,
+	ChannelString[ChPaused]:        ChPaused,
+	ChannelString[ChCancelled]:     ChCancelled,
 	ChannelString[ChPrefilter]:     ChPrefilter,
 }
 
@@ -84,30 +100,39 @@ func (c Channel) MarshalJSON() ([]byte, error) {
 	return []byte(fmt.Sprintf("\"%v\"", c)), nil
 }
 
-type IDChannel chan ID
-
+// Channels - queue of each dispatcher stage
 type Channels struct {
-	TaskChannel [ChDone]chan ID
+	TaskChannel [ChDone]*Queue
 }
 
 func NewChannels() *Channels {
 	c := &Channels{}
 	for i := ChPrefilter; i < ChDone; i++ {
-		c.TaskChannel[i] = make(chan ID, ChannelSize)
+		c.TaskChannel[i] = NewQueue()
 	}
 	return c
 }
 
+// Push - queue task for dispatchers of given channel
+func (c *Channels) Push(ch Channel, id ID, priority Priority) {
+	c.TaskChannel[ch].Push(id, priority)
+}
+
+// Pop - wait for task with highest priority in given channel
+func (c *Channels) Pop(ch Channel, stop <-chan struct{}) (ID, bool) {
+	return c.TaskChannel[ch].Pop(stop)
+}
+
 func (c *Channels) Close() {
 	for i := ChPrefilter; i < ChDone; i++ {
-		close(c.TaskChannel[i])
+		c.TaskChannel[i].Close()
 	}
 }
 
 func (c *Channels) String() string {
 	var sb strings.Builder
 	for i := ChPrefilter; i < ChDone; i++ {
-		fmt.Fprintf(&sb, "%d - ", len(c.TaskChannel[i]))
+		fmt.Fprintf(&sb, "%d - ", c.TaskChannel[i].Len())
 	}
 	return sb.String()
 }
@@ -0,0 +1,197 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+control.go
+
+Cancel, pause and resume tasks
+*/
+package task
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"sandboxer/pkg/sandbox"
+)
+
+var (
+	ErrCancelled  = errors.New("cancelled")
+	ErrPaused     = errors.New("paused")
+	ErrWrongState = errors.New("wrong task state")
+	ErrTimeout    = errors.New("timeout")
+)
+
+// Context - context for sandbox calls made for this task. It is done
+// when task is cancelled or paused or when stage deadline is exceeded
+func (t *Task) Context() context.Context {
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	if t.stage != nil {
+		return t.stage
+	}
+	return t.baseContext()
+}
+
+func (t *Task) baseContext() context.Context {
+	if t.ctx == nil {
+		t.ctx, t.cancel = context.WithCancelCause(context.Background())
+	}
+	return t.ctx
+}
+
+// StartStage - limit time dispatcher can spend on this task. Zero timeout
+// means no limit
+func (t *Task) StartStage(timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	t.stage, t.stageCancel = context.WithTimeoutCause(t.baseContext(), timeout, ErrTimeout)
+}
+
+// EndStage - drop stage deadline. Returns true if it was exceeded
+func (t *Task) EndStage() bool {
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	if t.stage == nil {
+		return false
+	}
+	exceeded := errors.Is(context.Cause(t.stage), ErrTimeout)
+	t.stageCancel()
+	t.stage = nil
+	t.stageCancel = nil
+	return exceeded
+}
+
+// Generation - number that changes each time task is stopped or gets new
+// context, so delayed actions can tell that task was controlled meanwhile
+func (t *Task) Generation() uint64 {
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	return t.generation
+}
+
+func (t *Task) stop(cause error) {
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	t.generation++
+	t.baseContext()
+	t.cancel(cause)
+}
+
+// resetContext - new context for task that is processed again
+func (t *Task) resetContext() {
+	t.cmx.Lock()
+	defer t.cmx.Unlock()
+	t.generation++
+	if t.cancel != nil {
+		t.cancel(context.Canceled)
+	}
+	t.ctx = nil
+	t.cancel = nil
+}
+
+// Cancel - stop processing task for good. Dispatcher busy with this task
+// aborts sandbox call it waits for
+func (t *Task) Cancel() error {
+	if channel, _ := t.Status(); !channel.Queued() && channel != ChPaused {
+		return fmt.Errorf("task #%d: %w: %v", t.Number, ErrWrongState, channel)
+	}
+	t.stop(ErrCancelled)
+	t.SetRiskLevel(sandbox.RiskLevelUnknown)
+	t.SetMessage("Cancelled")
+	t.SetChannel(ChCancelled)
+	return nil
+}
+
+// Pause - stop processing task till Resume. Task continues from the same stage
+func (t *Task) Pause() error {
+	channel, _ := t.Status()
+	if !channel.Queued() {
+		return fmt.Errorf("task #%d: %w: %v", t.Number, ErrWrongState, channel)
+	}
+	t.stop(ErrPaused)
+	t.pause(channel)
+	return nil
+}
+
+// pause - move task to paused channel to resume it in given one later
+func (t *Task) pause(resume Channel) {
+	t.mx.Lock()
+	t.ResumeChannel = resume
+	t.mx.Unlock()
+	t.SetChannel(ChPaused)
+}
+
+// Resume - continue processing of paused task. Task should be pushed to its channel
+func (t *Task) Resume() error {
+	if channel, _ := t.Status(); channel != ChPaused {
+		return fmt.Errorf("task #%d: %w: %v", t.Number, ErrWrongState, channel)
+	}
+	t.resetContext()
+	t.mx.RLock()
+	resume := t.ResumeChannel
+	t.mx.RUnlock()
+	t.SetChannel(resume)
+	return nil
+}
+
+// Interrupted - check whether task was cancelled or paused while dispatcher
+// was processing it. If dispatcher has moved task to the next stage,
+// paused task is resumed from that stage
+func (t *Task) Interrupted() bool {
+	t.cmx.Lock()
+	cause := context.Cause(t.baseContext())
+	t.cmx.Unlock()
+	channel, _ := t.Status()
+	switch {
+	case errors.Is(cause, ErrCancelled):
+		if channel.Queued() || channel == ChCancelled {
+			t.SetRiskLevel(sandbox.RiskLevelUnknown)
+			t.SetChannel(ChCancelled)
+		}
+		return true
+	case errors.Is(cause, ErrPaused):
+		if channel.Queued() {
+			t.pause(channel)
+		}
+		return true
+	}
+	return false
+}
+
+// Cancel - cancel task with given ID
+func (l *TaskList) Cancel(id ID) error {
+	return l.control(id, (*Task).Cancel)
+}
+
+// Pause - pause task with given ID
+func (l *TaskList) Pause(id ID) error {
+	return l.control(id, (*Task).Pause)
+}
+
+// Resume - resume paused task with given ID and push it to the channel it was paused in
+func (l *TaskList) Resume(id ID, channels *Channels) error {
+	return l.control(id, func(tsk *Task) error {
+		if err := tsk.Resume(); err != nil {
+			return err
+		}
+		channel, _ := tsk.Status()
+		channels.TaskChannel[channel].Remove(tsk.Number)
+		channels.Push(channel, tsk.Number, tsk.Priority)
+		return nil
+	})
+}
+
+func (l *TaskList) control(id ID, action func(tsk *Task) error) error {
+	err := l.Task(l.Original(id), action)
+	if err != nil {
+		return err
+	}
+	l.Updated()
+	return nil
+}
@@ -0,0 +1,101 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+control_test.go
+
+Test cancel, pause and resume of tasks
+*/
+package task
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/logging"
+)
+
+func TestPauseResume(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	tsk := NewTask(0, FileTask, "file.exe")
+	tsk.Channel = ChResult
+	ctx := tsk.Context()
+	if err := tsk.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	if tsk.Channel != ChPaused || tsk.ResumeChannel != ChResult {
+		t.Errorf("wrong state: %v, %v", tsk.Channel, tsk.ResumeChannel)
+	}
+	if !errors.Is(context.Cause(ctx), ErrPaused) {
+		t.Errorf("context is not paused: %v", context.Cause(ctx))
+	}
+	if err := tsk.Pause(); !errors.Is(err, ErrWrongState) {
+		t.Errorf("expected %v, got %v", ErrWrongState, err)
+	}
+	if err := tsk.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if tsk.Channel != ChResult || tsk.Context().Err() != nil {
+		t.Errorf("wrong state after resume: %v, %v", tsk.Channel, tsk.Context().Err())
+	}
+	t.Run("interrupted", func(t *testing.T) {
+		tsk.Activate()
+		if err := tsk.Pause(); err != nil {
+			t.Fatal(err)
+		}
+		tsk.SetChannel(ChReport) // dispatcher has finished its stage meanwhile
+		tsk.Deactivate()
+		if !tsk.Interrupted() {
+			t.Error("paused task is not interrupted")
+		}
+		if tsk.Channel != ChPaused || tsk.ResumeChannel != ChReport {
+			t.Errorf("wrong state: %v, %v", tsk.Channel, tsk.ResumeChannel)
+		}
+	})
+}
+
+func TestCancel(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	tsk := NewTask(0, FileTask, "file.exe")
+	ctx := tsk.Context()
+	if tsk.Interrupted() {
+		t.Error("new task is interrupted")
+	}
+	if err := tsk.Cancel(); err != nil {
+		t.Fatal(err)
+	}
+	if tsk.Channel != ChCancelled || !errors.Is(context.Cause(ctx), ErrCancelled) {
+		t.Errorf("wrong state: %v, %v", tsk.Channel, context.Cause(ctx))
+	}
+	if err := tsk.Cancel(); !errors.Is(err, ErrWrongState) {
+		t.Errorf("expected %v, got %v", ErrWrongState, err)
+	}
+	if err := tsk.Resume(); !errors.Is(err, ErrWrongState) {
+		t.Errorf("expected %v, got %v", ErrWrongState, err)
+	}
+	t.Run("recheck", func(t *testing.T) {
+		tsk.Recheck()
+		if tsk.Channel != ChPrefilter || tsk.Context().Err() != nil {
+			t.Errorf("wrong state after recheck: %v, %v", tsk.Channel, tsk.Context().Err())
+		}
+	})
+}
+
+func TestStage(t *testing.T) {
+	tsk := NewTask(0, FileTask, "file.exe")
+	tsk.StartStage(10 * time.Millisecond)
+	<-tsk.Context().Done()
+	if !tsk.EndStage() {
+		t.Error("stage deadline is not exceeded")
+	}
+	if tsk.Context().Err() != nil || tsk.Interrupted() {
+		t.Errorf("task context is done after stage: %v", tsk.Context().Err())
+	}
+	tsk.StartStage(0)
+	if tsk.EndStage() {
+		t.Error("stage without timeout exceeded deadline")
+	}
+}
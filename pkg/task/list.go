@@ -11,14 +11,15 @@ package task
 import (
 	"errors"
 	"fmt"
-	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"sandboxer/pkg/globals"
 	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
 )
 
 type TaskListInterface interface {
@@ -32,12 +33,16 @@ type TaskList struct {
 	changed    chan struct{}
 	Tasks      *Map[ID, *Task]
 	TasksCount ID
+	merged     Map[ID, ID]
+	owners     map[string]ID
+	store      *Store
 }
 
 func NewList() *TaskList {
 	l := &TaskList{
 		Tasks:   new(Map[ID, *Task]),
 		changed: make(chan struct{}, 1000),
+		owners:  make(map[string]ID),
 	}
 	l.changed <- struct{}{}
 	return l
@@ -63,7 +68,7 @@ var ErrAlreadyExists = errors.New("task already exist")
 
 func (l *TaskList) NewTask(taskType TaskType, path string) (ID, error) {
 	defer l.lockUnlock()()
-	tsk := l.FindTask(path)
+	tsk := l.findTask(path)
 	if tsk != nil {
 		tsk.SubmitTime = time.Now()
 		l.Updated()
@@ -71,6 +76,7 @@ func (l *TaskList) NewTask(taskType TaskType, path string) (ID, error) {
 	}
 	logging.Debugf("NewTask %d, %s", l.TasksCount, path)
 	tsk = NewTask(l.TasksCount, taskType, path)
+	tsk.store = l.store
 	//l.Tasks[tsk.Number] = tsk
 	l.Tasks.Store(tsk.Number, tsk)
 	l.Updated()
@@ -78,11 +84,18 @@ func (l *TaskList) NewTask(taskType TaskType, path string) (ID, error) {
 	return tsk.Number, nil
 }
 
-func (l *TaskList) FindTask(path string) (result *Task) {
-	// More sophisticated paths comparison algoritm could be used or
-	// os.SameFile function
+// FindTask - task for given path or alias. Paths are compared in canonical
+// form, so symbolic links and case differences do not create duplicates
+func (l *TaskList) FindTask(path string) *Task {
+	l.mx.RLock()
+	defer l.mx.RUnlock()
+	return l.findTask(path)
+}
+
+func (l *TaskList) findTask(path string) (result *Task) {
+	canonical := CanonicalPath(path)
 	l.Tasks.Range(func(id ID, tsk *Task) bool {
-		if path == tsk.Path {
+		if tsk.SamePath(path, canonical) {
 			result = tsk
 			return false
 		}
@@ -91,18 +104,131 @@ func (l *TaskList) FindTask(path string) (result *Task) {
 	return
 }
 
+// ClaimContent - make task the owner of its content. If other task already
+// owns the same content, that task is returned and task should be merged
+// into it. Exactly one task owns each content, so tasks of the same content
+// never share task folder
+func (l *TaskList) ClaimContent(tsk *Task) *Task {
+	if tsk.SHA256 == "" {
+		return nil
+	}
+	defer l.lockUnlock()()
+	key := contentKey(tsk.Type, tsk.SHA256)
+	if id, ok := l.owners[key]; ok && id != tsk.Number {
+		if owner, ok := l.Tasks.Load(id); ok {
+			return owner
+		}
+	}
+	l.releaseContent(tsk.Number)
+	l.owners[key] = tsk.Number
+	return nil
+}
+
+// ReleaseContent - task does not own its content anymore, so next task of
+// the same content becomes its owner
+func (l *TaskList) ReleaseContent(id ID) {
+	defer l.lockUnlock()()
+	l.releaseContent(id)
+}
+
+func (l *TaskList) releaseContent(id ID) {
+	for key, owner := range l.owners {
+		if owner == id {
+			delete(l.owners, key)
+		}
+	}
+}
+
+func contentKey(taskType TaskType, sha256 string) string {
+	return fmt.Sprintf("%v:%s", taskType, sha256)
+}
+
+// Members - tasks of files extracted from archive task. Member merged with
+// other task of the same content is represented by it
+func (l *TaskList) Members(archive *Task) (result []*Task) {
+	hashes := make(map[string]bool, len(archive.Members))
+	for _, hash := range archive.Members {
+		hashes[hash] = true
+	}
+	l.Tasks.Range(func(id ID, t *Task) bool {
+		if t.Type == FileTask && hashes[t.SHA256] {
+			result = append(result, t)
+			delete(hashes, t.SHA256)
+		}
+		return true
+	})
+	return
+}
+
+// Inside - file tasks with paths inside folder
+func (l *TaskList) Inside(folder string) (result []*Task) {
+	prefix := filepath.Clean(folder) + string(filepath.Separator)
+	l.Tasks.Range(func(id ID, t *Task) bool {
+		if t.Type == FileTask && strings.HasPrefix(t.Path, prefix) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return
+}
+
+// Merge - link task to original one with the same content: its path becomes
+// an alias of original and its ID refers to original from now on
+func (l *TaskList) Merge(tsk, original *Task) error {
+	defer l.lockUnlock()()
+	original.AddAlias(tsk.Path)
+	original.SubmitTime = time.Now()
+	l.merged.Store(tsk.Number, original.Number)
+	l.releaseContent(tsk.Number)
+	l.Tasks.Delete(tsk.Number)
+	l.Updated()
+	if l.store != nil {
+		if err := l.store.Delete(tsk.Number); err != nil {
+			return err
+		}
+		if err := l.store.PutMerged(tsk.Number, original.Number); err != nil {
+			return err
+		}
+	}
+	return original.Save()
+}
+
+// Original - ID of task that task with given ID is merged to or ID itself
+func (l *TaskList) Original(id ID) ID {
+	if original, ok := l.merged.Load(id); ok {
+		return original
+	}
+	return id
+}
+
+// Awaiting - submissions to the sandbox with given name that wait for
+// result, with upload time of their tasks
+func (l *TaskList) Awaiting(sandboxName string) map[string]time.Time {
+	result := make(map[string]time.Time)
+	l.Tasks.Range(func(id ID, t *Task) bool {
+		ids, uploadTime := t.Awaiting(sandboxName)
+		for _, sandboxID := range ids {
+			result[sandboxID] = uploadTime
+		}
+		return true
+	})
+	return result
+}
+
 func (l *TaskList) DelByID(id ID) {
 	//defer l.lockUnlock()() //mx.Lock()
 	//logging.Debugf("DelByID, id = %d, len = %d", id, len(l.Tasks))
+	l.ReleaseContent(id)
 	l.Tasks.Delete(id)
 	l.Updated()
 }
 
+// Get - task with given ID. For merged task its original is returned
 func (l *TaskList) Get(num ID) *Task {
 	//
 	// It was here:
 	//defer l.lockUnlock()()
-	tsk, _ := l.Tasks.Load(num)
+	tsk, _ := l.Tasks.Load(l.Original(num))
 	return tsk
 }
 
@@ -179,34 +305,98 @@ func (l *TaskList) CountActiveTasks() (count int) {
 	return
 }
 
-func (l *TaskList) LoadTasks(keepDays int) error {
+var ErrStoreClosed = errors.New("task store is not open")
+
+// Open - open task store in user data folder. Tasks saved by previous
+// versions in task folders are imported into it
+func (l *TaskList) Open() error {
+	defer l.lockUnlock()()
+	path, err := globals.StoreFilePath()
+	if err != nil {
+		return err
+	}
+	store, err := OpenStore(path)
+	if err != nil {
+		return err
+	}
 	folder, err := globals.TasksFolder()
 	if err != nil {
+		store.Close()
 		return err
 	}
-	dir, err := os.ReadDir(folder)
+	count, err := store.Import(folder)
 	if err != nil {
+		store.Close()
 		return err
 	}
+	if count > 0 {
+		logging.Infof("Imported %d tasks from %s", count, folder)
+	}
+	next, err := store.NextID()
+	if err != nil {
+		store.Close()
+		return err
+	}
+	l.store = store
+	l.TasksCount = max(l.TasksCount, next)
+	return nil
+}
+
+// Close - close task store. Tasks are not saved afterwards
+func (l *TaskList) Close() error {
+	defer l.lockUnlock()()
+	if l.store == nil {
+		return nil
+	}
+	err := l.store.Close()
+	l.store = nil
+	return err
+}
+
+// LoadTasks - read tasks from the store. Tasks older than keepDays are deleted
+func (l *TaskList) LoadTasks(keepDays int) error {
+	defer l.lockUnlock()()
+	if l.store == nil {
+		return ErrStoreClosed
+	}
 	keepDuration := time.Duration(keepDays) * time.Hour * 60
 	oldest := time.Now().Add(-keepDuration)
-	for _, d := range dir {
-		if !d.IsDir() {
+	ids, err := l.store.Between(IndexDate, "", DateValue(oldest))
+	if err != nil {
+		return err
+	}
+	expired := make(map[ID]bool, len(ids))
+	for _, id := range ids {
+		expired[id] = true
+	}
+	tasks, err := l.store.Load()
+	if err != nil {
+		return err
+	}
+	var expiredTasks []*Task
+	for _, tsk := range tasks {
+		tsk.store = l.store
+		if expired[tsk.Number] {
+			expiredTasks = append(expiredTasks, tsk)
 			continue
 		}
-		path := filepath.Join(folder, d.Name(), taskFileName)
-		tsk, err := LoadTask(path)
-		if err != nil {
-			logging.LogError(err)
-			continue
+		l.Tasks.Store(tsk.Number, tsk)
+		if tsk.SHA256 != "" && tsk.RiskLevel != sandbox.RiskLevelUnsupported {
+			l.owners[contentKey(tsk.Type, tsk.SHA256)] = tsk.Number
 		}
-		if tsk.SubmitTime.Before(oldest) {
-			logging.Debugf("To delete %v", tsk)
-			logging.LogError(tsk.Delete())
+	}
+	for _, tsk := range expiredTasks {
+		logging.Debugf("To delete %v", tsk)
+		logging.LogError(tsk.delete(l.ownsFolder(tsk)))
+	}
+	merged, err := l.store.LoadMerged()
+	if err != nil {
+		return err
+	}
+	for id, original := range merged {
+		if _, ok := l.Tasks.Load(original); ok {
+			l.merged.Store(id, original)
 		}
-		tsk.Number = l.TasksCount
-		l.TasksCount++
-		l.Tasks.Store(tsk.Number, tsk)
 	}
 	return nil
 }
@@ -221,13 +411,52 @@ func (l *TaskList) Save(filePath string) error {
 }
 */
 
-func (l *TaskList) DeleteTask(tsk *Task) error {
-	err := tsk.Delete()
+// delete - remove task files. Task folder is kept while other task of the
+// same content is left
+func (l *TaskList) delete(tsk *Task) error {
+	l.mx.RLock()
+	removeFolder := l.ownsFolder(tsk)
+	l.mx.RUnlock()
+	return tsk.delete(removeFolder)
+}
+
+// ownsFolder - task folder can be removed along with task. Ignored or
+// unsupported copy of analyzed file has the same SHA256, so its folder
+// belongs to owner of the content or other task of the same content
+func (l *TaskList) ownsFolder(tsk *Task) bool {
+	if tsk.SHA256 == "" {
+		return true
+	}
+	if owner, ok := l.owners[contentKey(tsk.Type, tsk.SHA256)]; ok && owner != tsk.Number {
+		return false
+	}
+	shared := false
+	l.Tasks.Range(func(id ID, t *Task) bool {
+		shared = id != tsk.Number && t.SHA256 == tsk.SHA256
+		return !shared
+	})
+	return !shared
+}
+
+// DeleteTask - delete task along with tasks of files extracted from it,
+// as these files are kept in its folder
+func (l *TaskList) DeleteTask(tsk *Task) (err error) {
+	err = l.delete(tsk)
 	if err != nil {
 		return err
 	}
 	l.DelByID(tsk.Number)
-	return nil
+	if tsk.SHA256 == "" {
+		return nil
+	}
+	l.Tasks.Range(func(id ID, t *Task) bool {
+		if t.Archive != tsk.SHA256 {
+			return true
+		}
+		err = l.DeleteTask(t)
+		return err == nil
+	})
+	return
 }
 
 func (l *TaskList) DeleteSameTasks(tsk *Task) (err error) {
@@ -238,7 +467,7 @@ func (l *TaskList) DeleteSameTasks(tsk *Task) (err error) {
 		if t.RiskLevel != tsk.RiskLevel {
 			return true
 		}
-		err = t.Delete()
+		err = l.delete(t)
 		if err != nil {
 			return false
 		}
@@ -250,7 +479,7 @@ func (l *TaskList) DeleteSameTasks(tsk *Task) (err error) {
 
 func (l *TaskList) DeleteAllTasks() (err error) {
 	l.Tasks.Range(func(id ID, t *Task) bool {
-		err = t.Delete()
+		err = l.delete(t)
 		if err != nil {
 			return false
 		}
@@ -0,0 +1,246 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+list_test.go
+
+Test tasks identity
+*/
+package task
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+)
+
+func TestListFindTask(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	filePath := filepath.Join(folder, "file.txt")
+	if err := os.WriteFile(filePath, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list := NewList()
+	id, err := list.NewTask(FileTask, filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("symlink", func(t *testing.T) {
+		link := filepath.Join(folder, "link.txt")
+		if err := os.Symlink(filePath, link); err != nil {
+			t.Skip(err)
+		}
+		if tsk := list.FindTask(link); tsk == nil || tsk.Number != id {
+			t.Errorf("task is not found by symlink: %v", tsk)
+		}
+	})
+	t.Run("relative", func(t *testing.T) {
+		wd, err := os.Getwd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := filepath.Rel(wd, filePath)
+		if err != nil {
+			t.Skip(err)
+		}
+		if _, err := list.NewTask(FileTask, rel); !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("expected %v, but got %v", ErrAlreadyExists, err)
+		}
+	})
+	t.Run("case", func(t *testing.T) {
+		found := list.FindTask(strings.ToUpper(filePath)) != nil
+		if found != caseInsensitive {
+			t.Errorf("expected found %v, but got %v", caseInsensitive, found)
+		}
+	})
+}
+
+func TestListMerge(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	t.Setenv("HOME", folder)
+	t.Setenv("XDG_CONFIG_HOME", folder)
+	t.Setenv("APPDATA", folder)
+	list := NewList()
+	if err := list.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+	var tasks []*Task
+	for _, name := range []string{"first.txt", "second.txt", "other.txt"} {
+		filePath := filepath.Join(folder, name)
+		content := "same"
+		if name == "other.txt" {
+			content = "other"
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(FileTask, filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		if err := tsk.CalculateHash(); err != nil {
+			t.Fatal(err)
+		}
+		tasks = append(tasks, tsk)
+	}
+	first, second, other := tasks[0], tasks[1], tasks[2]
+	if list.ClaimContent(first) != nil {
+		t.Errorf("first task does not own its content")
+	}
+	if list.ClaimContent(other) != nil {
+		t.Errorf("other content is found")
+	}
+	if list.ClaimContent(first) != nil {
+		t.Errorf("owner claims its content again")
+	}
+	original := list.ClaimContent(second)
+	if original != first {
+		t.Fatalf("expected %v, but got %v", first, original)
+	}
+	if err := list.Merge(second, original); err != nil {
+		t.Fatal(err)
+	}
+	if list.Get(second.Number) != first {
+		t.Errorf("merged ID does not refer to original")
+	}
+	if list.Length() != 2 {
+		t.Errorf("expected 2 tasks, but got %d", list.Length())
+	}
+	if tsk := list.FindTask(second.Path); tsk != first {
+		t.Errorf("task is not found by alias: %v", tsk)
+	}
+	canonicals := []string{CanonicalPath(second.Path)}
+	if !slices.Equal(first.canonicals, canonicals) {
+		t.Errorf("expected canonical aliases %v, but got %v", canonicals, first.canonicals)
+	}
+	if err := list.Close(); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewList()
+	if err := loaded.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	if err := loaded.LoadTasks(1); err != nil {
+		t.Fatal(err)
+	}
+	tsk := loaded.FindTask(second.Path)
+	if tsk == nil || tsk.Path != first.Path || tsk.Number != first.Number {
+		t.Fatalf("alias is not loaded: %v", tsk)
+	}
+	if !slices.Equal(tsk.canonicals, canonicals) {
+		t.Errorf("expected loaded canonical aliases %v, but got %v", canonicals, tsk.canonicals)
+	}
+	if tsk := loaded.Get(second.Number); tsk == nil || tsk.Number != first.Number {
+		t.Errorf("merged ID does not refer to original after restart: %v", tsk)
+	}
+	third := NewTask(100, FileTask, filepath.Join(folder, "third.txt"))
+	third.SHA256 = first.SHA256
+	if owner := loaded.ClaimContent(third); owner == nil || owner.Number != first.Number {
+		t.Errorf("loaded task does not own its content: %v", owner)
+	}
+	loaded.ReleaseContent(first.Number)
+	if owner := loaded.ClaimContent(third); owner != nil {
+		t.Errorf("released content is owned by %v", owner)
+	}
+}
+
+func TestListDelete(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	t.Setenv("HOME", folder)
+	t.Setenv("XDG_CONFIG_HOME", folder)
+	t.Setenv("APPDATA", folder)
+	list := NewList()
+	if err := list.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer list.Close()
+	newTask := func(name string) *Task {
+		t.Helper()
+		filePath := filepath.Join(folder, name)
+		if err := os.WriteFile(filePath, []byte("same"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		id, err := list.NewTask(FileTask, filePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tsk := list.Get(id)
+		if err := tsk.CalculateHash(); err != nil {
+			t.Fatal(err)
+		}
+		return tsk
+	}
+	owner := newTask("owner.exe")
+	if list.ClaimContent(owner) != nil {
+		t.Fatal("owner does not own its content")
+	}
+	reportPath, err := owner.ReportPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(reportPath, []byte("%PDF-"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reportExists := func(t *testing.T) {
+		t.Helper()
+		if _, err := os.Stat(reportPath); err != nil {
+			t.Errorf("report of owner is deleted: %v", err)
+		}
+	}
+	t.Run("ignored copy", func(t *testing.T) {
+		ignored := newTask("ignored.exe")
+		ignored.SetRiskLevel(sandbox.RiskLevelUnsupported)
+		if err := list.DeleteTask(ignored); err != nil {
+			t.Fatal(err)
+		}
+		reportExists(t)
+	})
+	t.Run("expired copy", func(t *testing.T) {
+		expired := newTask("expired.exe")
+		expired.SetRiskLevel(sandbox.RiskLevelUnsupported)
+		expired.SubmitTime = time.Now().Add(-365 * 24 * time.Hour)
+		if err := expired.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if err := owner.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if err := list.Close(); err != nil {
+			t.Fatal(err)
+		}
+		loaded := NewList()
+		if err := loaded.Open(); err != nil {
+			t.Fatal(err)
+		}
+		defer loaded.Close()
+		if err := loaded.LoadTasks(1); err != nil {
+			t.Fatal(err)
+		}
+		if loaded.Get(expired.Number) != nil {
+			t.Error("expired task is loaded")
+		}
+		reportExists(t)
+		t.Run("owner", func(t *testing.T) {
+			if err := loaded.DeleteTask(loaded.Get(owner.Number)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(reportPath); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("report of deleted owner is left: %v", err)
+			}
+		})
+	})
+}
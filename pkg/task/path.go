@@ -0,0 +1,95 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+path.go
+
+Paths comparison for tasks identity
+*/
+package task
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// caseInsensitive - default file systems of these platforms ignore case of file names
+var caseInsensitive = runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+
+// CanonicalPath - absolute path with symbolic links resolved. On platforms
+// with case insensitive file systems it is lower cased
+func CanonicalPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if real, err := filepath.EvalSymlinks(path); err == nil {
+		path = real
+	}
+	if caseInsensitive {
+		path = strings.ToLower(path)
+	}
+	return path
+}
+
+// SamePath - path is the path of the task or one of its aliases.
+// canonical is result of CanonicalPath for path
+func (t *Task) SamePath(path, canonical string) bool {
+	if path == t.Path {
+		return true
+	}
+	if t.Type != FileTask {
+		return false
+	}
+	if canonical == t.canonicalPath() {
+		return true
+	}
+	for i, alias := range t.Aliases {
+		if path == alias || canonical == t.canonicalAlias(i) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Task) canonicalPath() string {
+	if t.canonical == "" {
+		return CanonicalPath(t.Path)
+	}
+	return t.canonical
+}
+
+func (t *Task) canonicalAlias(i int) string {
+	if i >= len(t.canonicals) {
+		return CanonicalPath(t.Aliases[i])
+	}
+	return t.canonicals[i]
+}
+
+// canonize - keep canonical paths of file task and its aliases, so they
+// are not resolved on each comparison
+func (t *Task) canonize() {
+	if t.Type != FileTask {
+		return
+	}
+	t.canonical = CanonicalPath(t.Path)
+	t.canonicals = make([]string, len(t.Aliases))
+	for i, alias := range t.Aliases {
+		t.canonicals[i] = CanonicalPath(alias)
+	}
+}
+
+// AddAlias - other path of the same content
+func (t *Task) AddAlias(path string) {
+	canonical := CanonicalPath(path)
+	if t.SamePath(path, canonical) {
+		return
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for i := len(t.canonicals); i < len(t.Aliases); i++ {
+		t.canonicals = append(t.canonicals, CanonicalPath(t.Aliases[i]))
+	}
+	t.canonicals = append(t.canonicals, canonical)
+	t.Aliases = append(t.Aliases, path)
+}
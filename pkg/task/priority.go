@@ -0,0 +1,97 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+priority.go
+
+Order in which dispatchers process tasks
+*/
+package task
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type Priority int
+
+const (
+	PriorityInteractive Priority = iota
+	PriorityFolderScan
+	PriorityRecheck
+	PriorityBackground
+	PriorityCount
+)
+
+var PriorityString = [...]string{
+	"Interactive",
+	"Folder Scan",
+	"Recheck",
+	"Background",
+}
+
+// String - return string representation for Priority value
+func (p Priority) String() string {
+	if p < 0 || p >= PriorityCount {
+		return "Priority(" + strconv.FormatInt(int64(p), 10) + ")"
+	}
+	return PriorityString[p]
+}
+
+// ErrUnknownPriority - will be returned wrapped when parsing string
+// containing unrecognized value.
+var ErrUnknownPriority = errors.New("unknown priority")
+
+var mapPriorityFromString = map[string]Priority{
+	"interactive": PriorityInteractive,
+	"folder scan": PriorityFolderScan,
+	"folder-scan": PriorityFolderScan,
+	"recheck":     PriorityRecheck,
+	"background":  PriorityBackground,
+}
+
+// ParsePriority - parse priority name. Case is ignored and dash can be
+// used instead of space. Empty string means interactive priority
+func ParsePriority(s string) (Priority, error) {
+	if s == "" {
+		return PriorityInteractive, nil
+	}
+	result, ok := mapPriorityFromString[strings.ToLower(s)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownPriority, s)
+	}
+	return result, nil
+}
+
+// UnmarshalJSON implements the Unmarshaler interface of the json package for Priority.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	result, err := ParsePriority(v)
+	if err != nil {
+		return err
+	}
+	*p = result
+	return nil
+}
+
+// MarshalJSON implements the Marshaler interface of the json package for Priority.
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf("\"%v\"", p)), nil
+}
+
+// SetPriority - change priority. It takes effect when task is pushed to the next channel
+func (t *Task) SetPriority(priority Priority) {
+	t.Priority = priority
+}
+
+// Bulk - task is created by folder scan or in background, so interactive
+// submissions go first when quota is low
+func (t *Task) Bulk() bool {
+	return t.Priority == PriorityFolderScan || t.Priority == PriorityBackground
+}
@@ -0,0 +1,121 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+queue.go
+
+Queue of tasks ordered by priority
+*/
+package task
+
+import (
+	"sync"
+)
+
+// Queue - tasks waiting for dispatcher. Task with higher priority goes
+// first, tasks of the same priority go in order they were pushed
+type Queue struct {
+	mx     sync.Mutex
+	items  [PriorityCount][]ID
+	length int
+	signal chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+func NewQueue() *Queue {
+	return &Queue{
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// Push - add task to the queue. Never blocks
+func (q *Queue) Push(id ID, priority Priority) {
+	if priority < 0 || priority >= PriorityCount {
+		priority = PriorityBackground
+	}
+	q.mx.Lock()
+	q.items[priority] = append(q.items[priority], id)
+	q.length++
+	q.mx.Unlock()
+	q.wakeUp()
+}
+
+// TryPop - take task with highest priority if queue is not empty
+func (q *Queue) TryPop() (ID, bool) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for p := range q.items {
+		if len(q.items[p]) == 0 {
+			continue
+		}
+		id := q.items[p][0]
+		q.items[p] = q.items[p][1:]
+		q.length--
+		if q.length > 0 {
+			q.wakeUp()
+		}
+		return id, true
+	}
+	return 0, false
+}
+
+// Remove - drop all entries of the task from the queue
+func (q *Queue) Remove(id ID) {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	for p := range q.items {
+		items := q.items[p][:0]
+		for _, item := range q.items[p] {
+			if item != id {
+				items = append(items, item)
+			}
+		}
+		q.length -= len(q.items[p]) - len(items)
+		q.items[p] = items
+	}
+}
+
+// Pop - wait for task. Returns false if stop is closed or queue is closed
+func (q *Queue) Pop(stop <-chan struct{}) (ID, bool) {
+	for {
+		if id, ok := q.TryPop(); ok {
+			return id, true
+		}
+		select {
+		case <-q.signal:
+		case <-q.done:
+			return 0, false
+		case <-stop:
+			return 0, false
+		}
+	}
+}
+
+// Len - number of queued tasks
+func (q *Queue) Len() int {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	return q.length
+}
+
+// Close - wake up all waiting dispatchers
+func (q *Queue) Close() {
+	q.mx.Lock()
+	defer q.mx.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	close(q.done)
+}
+
+// wakeUp - let one of waiting dispatchers check queue. It wakes up next
+// one after taking task, so all of them are busy while queue is not empty
+func (q *Queue) wakeUp() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
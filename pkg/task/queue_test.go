@@ -0,0 +1,98 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+queue_test.go
+
+Test priority queue
+*/
+package task
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueueOrder(t *testing.T) {
+	q := NewQueue()
+	q.Push(1, PriorityFolderScan)
+	q.Push(2, PriorityBackground)
+	q.Push(3, PriorityFolderScan)
+	q.Push(4, PriorityInteractive)
+	q.Push(5, PriorityRecheck)
+	if q.Len() != 5 {
+		t.Errorf("expected 5, got %d", q.Len())
+	}
+	for _, expected := range []ID{4, 1, 3, 5, 2} {
+		id, ok := q.TryPop()
+		if !ok || id != expected {
+			t.Errorf("expected #%d, got #%d (%v)", expected, id, ok)
+		}
+	}
+	if _, ok := q.TryPop(); ok {
+		t.Error("empty queue returned task")
+	}
+}
+
+func TestQueuePop(t *testing.T) {
+	q := NewQueue()
+	stop := make(chan struct{})
+	const count = 100
+	var wg sync.WaitGroup
+	var mx sync.Mutex
+	got := make(map[ID]bool)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				id, ok := q.Pop(stop)
+				if !ok {
+					return
+				}
+				mx.Lock()
+				got[id] = true
+				mx.Unlock()
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		q.Push(ID(i), Priority(i%int(PriorityCount)))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Len() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+	if len(got) != count {
+		t.Errorf("expected %d tasks, got %d", count, len(got))
+	}
+	t.Run("closed", func(t *testing.T) {
+		q.Close()
+		if _, ok := q.Pop(nil); ok {
+			t.Error("closed queue returned task")
+		}
+	})
+}
+
+func TestPriorityJSON(t *testing.T) {
+	tsk := NewTask(0, FileTask, "file.exe")
+	tsk.SetPriority(PriorityFolderScan)
+	data, err := json.Marshal(tsk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Task
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Priority != PriorityFolderScan || !loaded.Bulk() {
+		t.Errorf("wrong priority: %v", loaded.Priority)
+	}
+	if _, err := ParsePriority("urgent"); err == nil {
+		t.Error("unknown priority is parsed")
+	}
+}
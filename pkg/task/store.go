@@ -0,0 +1,329 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+store.go
+
+Persistent storage of tasks
+*/
+package task
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sandboxer/pkg/logging"
+
+	"go.etcd.io/bbolt"
+)
+
+// Index - task property that tasks can be found by in the store
+type Index string
+
+const (
+	IndexSHA256  Index = "sha256"
+	IndexVerdict Index = "verdict"
+	IndexDate    Index = "date"
+	IndexPath    Index = "path"
+)
+
+var indexes = []Index{IndexSHA256, IndexVerdict, IndexDate, IndexPath}
+
+var (
+	tasksBucket  = []byte("tasks")
+	keysBucket   = []byte("keys")
+	metaBucket   = []byte("meta")
+	mergedBucket = []byte("merged")
+	importedKey  = []byte("imported")
+)
+
+const dateFormat = "20060102150405.000000000"
+
+// DateValue - value of date index for given time. Later times have greater values
+func DateValue(t time.Time) string {
+	return t.UTC().Format(dateFormat)
+}
+
+// Store - tasks database. Each task is kept by its ID along with entries of
+// all indexes, which are updated in the same transaction
+type Store struct {
+	db *bbolt.DB
+}
+
+// OpenStore - open database file, creating it if needed
+func OpenStore(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		buckets := [][]byte{tasksBucket, keysBucket, metaBucket, mergedBucket}
+		for _, index := range indexes {
+			buckets = append(buckets, []byte(index))
+		}
+		for _, name := range buckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Put - write task replacing its previous version
+func (s *Store) Put(t *Task) error {
+	t.mx.RLock()
+	data, err := json.Marshal(t)
+	values := indexValues(t)
+	t.mx.RUnlock()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return put(tx, t.Number, data, values)
+	})
+}
+
+// Delete - remove task with given ID. Missing task is not an error
+func (s *Store) Delete(id ID) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		if err := unindex(tx, id); err != nil {
+			return err
+		}
+		return tx.Bucket(tasksBucket).Delete(idKey(id))
+	})
+}
+
+// PutMerged - remember that task with given ID is merged into original, so
+// its ID refers to original after restart as well
+func (s *Store) PutMerged(id, original ID) error {
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		tasks := tx.Bucket(tasksBucket)
+		if uint64(id) >= tasks.Sequence() {
+			if err := tasks.SetSequence(uint64(id) + 1); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(mergedBucket).Put(idKey(id), idKey(original))
+	})
+}
+
+// LoadMerged - IDs of merged tasks with IDs of their originals
+func (s *Store) LoadMerged() (merged map[ID]ID, err error) {
+	merged = make(map[ID]ID)
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(mergedBucket).ForEach(func(k, v []byte) error {
+			merged[keyID(k)] = keyID(v)
+			return nil
+		})
+	})
+	return
+}
+
+// Load - all stored tasks. Tasks that can not be decoded are skipped
+func (s *Store) Load() (tasks []*Task, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(tasksBucket).ForEach(func(k, v []byte) error {
+			tsk := &Task{}
+			if err := json.Unmarshal(v, tsk); err != nil {
+				logging.Errorf("task #%d: %v", keyID(k), err)
+				return nil
+			}
+			tsk.Number = keyID(k)
+			tasks = append(tasks, tsk)
+			return nil
+		})
+	})
+	for _, tsk := range tasks {
+		tsk.canonize()
+	}
+	return
+}
+
+// NextID - ID that is greater than ID of any task ever stored
+func (s *Store) NextID() (id ID, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		id = ID(tx.Bucket(tasksBucket).Sequence())
+		return nil
+	})
+	return
+}
+
+// Find - IDs of tasks with given value of index
+func (s *Store) Find(index Index, value string) (ids []ID, err error) {
+	prefix := append([]byte(value), 0)
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(index)).Cursor()
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			ids = append(ids, keyID(k))
+		}
+		return nil
+	})
+	return
+}
+
+// Between - IDs of tasks with values of index starting from "from" and less than "to"
+func (s *Store) Between(index Index, from, to string) (ids []ID, err error) {
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket([]byte(index)).Cursor()
+		for k, _ := c.Seek([]byte(from)); k != nil && bytes.Compare(k, []byte(to)) < 0; k, _ = c.Next() {
+			ids = append(ids, keyID(k))
+		}
+		return nil
+	})
+	return
+}
+
+// Import - move tasks saved by previous versions as task.json files of task
+// folders to the store. It is done once, as folders are still used for reports
+func (s *Store) Import(folder string) (int, error) {
+	dir, err := os.ReadDir(folder)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, err
+	}
+	var imported []string
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		meta := tx.Bucket(metaBucket)
+		if meta.Get(importedKey) != nil {
+			return nil
+		}
+		tasks := tx.Bucket(tasksBucket)
+		for _, d := range dir {
+			if !d.IsDir() {
+				continue
+			}
+			path := filepath.Join(folder, d.Name(), taskFileName)
+			tsk, err := LoadTask(path)
+			if err != nil {
+				if !errors.Is(err, fs.ErrNotExist) {
+					logging.LogError(err)
+				}
+				continue
+			}
+			tsk.Number = ID(tasks.Sequence())
+			data, err := json.Marshal(tsk)
+			if err != nil {
+				return err
+			}
+			if err := put(tx, tsk.Number, data, indexValues(tsk)); err != nil {
+				return err
+			}
+			imported = append(imported, path)
+		}
+		return meta.Put(importedKey, []byte(time.Now().Format(time.RFC3339)))
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, path := range imported {
+		logging.LogError(os.Remove(path))
+	}
+	return len(imported), nil
+}
+
+func put(tx *bbolt.Tx, id ID, data []byte, values map[Index][]string) error {
+	if err := unindex(tx, id); err != nil {
+		return err
+	}
+	key := idKey(id)
+	tasks := tx.Bucket(tasksBucket)
+	if err := tasks.Put(key, data); err != nil {
+		return err
+	}
+	if uint64(id) >= tasks.Sequence() {
+		if err := tasks.SetSequence(uint64(id) + 1); err != nil {
+			return err
+		}
+	}
+	for index, list := range values {
+		bucket := tx.Bucket([]byte(index))
+		for _, value := range list {
+			if err := bucket.Put(indexKey(value, id), []byte{}); err != nil {
+				return err
+			}
+		}
+	}
+	keys, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(keysBucket).Put(key, keys)
+}
+
+// unindex - remove index entries of stored version of the task
+func unindex(tx *bbolt.Tx, id ID) error {
+	keys := tx.Bucket(keysBucket)
+	key := idKey(id)
+	data := keys.Get(key)
+	if data == nil {
+		return nil
+	}
+	var values map[Index][]string
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	for index, list := range values {
+		bucket := tx.Bucket([]byte(index))
+		if bucket == nil {
+			continue
+		}
+		for _, value := range list {
+			if err := bucket.Delete(indexKey(value, id)); err != nil {
+				return err
+			}
+		}
+	}
+	return keys.Delete(key)
+}
+
+// indexValues - values of all indexes for the task. File paths are canonical
+func indexValues(t *Task) map[Index][]string {
+	values := map[Index][]string{
+		IndexVerdict: {t.RiskLevel.String()},
+		IndexDate:    {DateValue(t.SubmitTime)},
+	}
+	if t.SHA256 != "" {
+		values[IndexSHA256] = []string{t.SHA256}
+	}
+	if t.Type != FileTask {
+		values[IndexPath] = []string{t.Path}
+		return values
+	}
+	values[IndexPath] = []string{t.canonicalPath()}
+	for i := range t.Aliases {
+		values[IndexPath] = append(values[IndexPath], t.canonicalAlias(i))
+	}
+	return values
+}
+
+func idKey(id ID) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(id))
+}
+
+// keyID - ID from the end of tasks or index key
+func keyID(key []byte) ID {
+	return ID(binary.BigEndian.Uint64(key[len(key)-8:]))
+}
+
+func indexKey(value string, id ID) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(value), 0), uint64(id))
+}
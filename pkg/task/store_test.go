@@ -0,0 +1,140 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+store_test.go
+
+Test tasks database
+*/
+package task
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"sandboxer/pkg/logging"
+	"sandboxer/pkg/sandbox"
+)
+
+func TestStore(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	path := filepath.Join(folder, "tasks.db")
+	store, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	first := NewTask(0, FileTask, filepath.Join(folder, "first.exe"))
+	first.SHA256 = "aaa"
+	first.SubmitTime = now.Add(-time.Hour)
+	second := NewTask(1, URLTask, "http://example.com")
+	second.SHA256 = "bbb"
+	second.SubmitTime = now
+	for _, tsk := range []*Task{first, second} {
+		if err := store.Put(tsk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	find := func(index Index, value string, expected ...ID) {
+		t.Helper()
+		ids, err := store.Find(index, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, expected) {
+			t.Errorf("%s %s: expected %v, but got %v", index, value, expected, ids)
+		}
+	}
+	find(IndexSHA256, "aaa", 0)
+	find(IndexPath, "http://example.com", 1)
+	find(IndexPath, CanonicalPath(first.Path), 0)
+	find(IndexVerdict, sandbox.RiskLevelUnknown.String(), 0, 1)
+	ids, err := store.Between(IndexDate, "", DateValue(now.Add(-time.Minute)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ids, []ID{0}) {
+		t.Errorf("expected older task, but got %v", ids)
+	}
+
+	first.SetRiskLevel(sandbox.RiskLevelHigh)
+	if err := store.Put(first); err != nil {
+		t.Fatal(err)
+	}
+	find(IndexVerdict, sandbox.RiskLevelUnknown.String(), 1)
+	find(IndexVerdict, sandbox.RiskLevelHigh.String(), 0)
+	if err := store.Delete(second.Number); err != nil {
+		t.Fatal(err)
+	}
+	find(IndexSHA256, "bbb")
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	tasks, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Number != first.Number || tasks[0].RiskLevel != sandbox.RiskLevelHigh {
+		t.Errorf("wrong tasks: %v", tasks)
+	}
+	next, err := store.NextID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != 2 {
+		t.Errorf("expected next ID 2, but got %d", next)
+	}
+}
+
+func TestStoreImport(t *testing.T) {
+	logging.SetLogger(logging.NewFileLogger(io.Discard))
+	folder := t.TempDir()
+	tasksFolder := filepath.Join(folder, "tasks")
+	tsk := NewTask(0, URLTask, "http://example.com")
+	tsk.SHA256 = "ccc"
+	taskFolder := filepath.Join(tasksFolder, tsk.SHA256)
+	if err := os.MkdirAll(taskFolder, 0755); err != nil {
+		t.Fatal(err)
+	}
+	taskFile := filepath.Join(taskFolder, taskFileName)
+	if err := tsk.SaveToFile(taskFile); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenStore(filepath.Join(folder, "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, expected := range []int{1, 0} {
+		count, err := store.Import(tasksFolder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != expected {
+			t.Errorf("expected %d imported tasks, but got %d", expected, count)
+		}
+	}
+	if _, err := os.Stat(taskFile); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("%s is not removed: %v", taskFile, err)
+	}
+	ids, err := store.Find(IndexSHA256, tsk.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 {
+		t.Errorf("imported task is not found: %v", ids)
+	}
+}
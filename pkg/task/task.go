@@ -9,6 +9,7 @@ Inspection task
 package task
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -20,6 +21,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"sandboxer/pkg/globals"
@@ -30,15 +32,41 @@ import (
 type ID int64
 
 type Task struct {
+	// mx guards fields that dispatchers change while other goroutines read them
+	mx            sync.RWMutex
+	cmx           sync.Mutex
+	ctx           context.Context
+	cancel        context.CancelCauseFunc
+	stage         context.Context
+	stageCancel   context.CancelFunc
+	generation    uint64
+	postpone      time.Duration
+	canonical     string
+	canonicals    []string
+	store         *Store
 	Number        ID `json:"-"`
 	Type          TaskType
 	SubmitTime    time.Time
 	Path          string
+	Aliases       []string `json:",omitempty"`
+	Archive       string   `json:",omitempty"`
+	Members       []string `json:",omitempty"`
+	FileType      string   `json:",omitempty"`
 	Channel       Channel
+	ResumeChannel Channel `json:",omitempty"`
 	RiskLevel     sandbox.RiskLevel
 	Active        bool `json:"-"`
 	Message       string
+	Sandbox       string
 	SandboxID     string
+	Verdicts      []Verdict `json:",omitempty"`
+	ForceUpload   bool      `json:",omitempty"`
+	Priority      Priority
+	Retries       int       `json:",omitempty"`
+	LastError     string    `json:",omitempty"`
+	UploadTime    time.Time `json:",omitempty"`
+	VerdictTime   time.Time `json:",omitempty"`
+	Polls         int       `json:",omitempty"`
 	MD5           string
 	SHA1          string
 	SHA256        string
@@ -47,7 +75,7 @@ type Task struct {
 }
 
 func NewTask(id ID, taskType TaskType, path string) *Task {
-	return &Task{
+	t := &Task{
 		Number:     id,
 		Type:       taskType,
 		SubmitTime: time.Now(),
@@ -58,6 +86,8 @@ func NewTask(id ID, taskType TaskType, path string) *Task {
 		Message:    "",
 		SandboxID:  "",
 	}
+	t.canonize()
+	return t
 }
 
 func LoadTask(filePath string) (*Task, error) {
@@ -71,20 +101,36 @@ func LoadTask(filePath string) (*Task, error) {
 	if err != nil {
 		return nil, err
 	}
+	t.canonize()
 	return t, nil
 }
 
 func (t *Task) SetChannel(newChannel Channel) {
 	logging.Debugf("SetChannel(%v)", newChannel)
+	t.mx.Lock()
 	t.Channel = newChannel
+	if newChannel == ChDone {
+		t.VerdictTime = time.Now()
+	}
+	t.mx.Unlock()
 	logging.LogError(t.SaveIfNeeded())
 }
 
 func (t *Task) GetChannel() string {
-	if t.Channel == ChDone { //} && t.RiskLevel  != sandbox.RiskLevelUnknown {
-		return t.RiskLevel.String()
+	channel, riskLevel := t.Status()
+	if channel == ChDone { //} && t.RiskLevel  != sandbox.RiskLevelUnknown {
+		return riskLevel.String()
 	}
-	return t.Channel.String()
+	return channel.String()
+}
+
+// Status - channel and risk level of task. Safe to call while other
+// goroutine processes task. Fields changed by task owner before it moved
+// task to returned channel can be read afterwards
+func (t *Task) Status() (Channel, sandbox.RiskLevel) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return t.Channel, t.RiskLevel
 }
 
 func (t *Task) VOneID() string {
@@ -92,14 +138,33 @@ func (t *Task) VOneID() string {
 }
 
 func (t *Task) SetSandboxID(sandboxID string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.SandboxID = sandboxID
 }
 
+// SetFileType - type of file detected by its content
+func (t *Task) SetFileType(fileType string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.FileType = fileType
+}
+
+// SetSandbox - name of sandbox task is routed to
+func (t *Task) SetSandbox(sandbox string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.Sandbox = sandbox
+}
+
 func (t *Task) String() string {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
 	return fmt.Sprintf("Task %d; submitted on: %v; channel: %v; id: %s; message: %s, path: %s", t.Number, t.SubmitTime, t.Channel, t.SandboxID, t.Message, t.Path)
 }
 func (t *Task) SetRiskLevel(riskLevel sandbox.RiskLevel) {
-	//t.State = StateDone
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.RiskLevel = riskLevel
 }
 
@@ -112,31 +177,113 @@ func (t *Task) Title() string {
 }
 
 func (t *Task) SetError(err error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Channel = ChDone
+	t.VerdictTime = time.Now()
 	t.RiskLevel = sandbox.RiskLevelError
 	t.Message = err.Error()
 }
 
+// Recheck - analyze task once again with recheck priority. Known verdicts
+// are not used for it
+func (t *Task) Recheck() {
+	t.mx.Lock()
+	t.Message = ""
+	t.RiskLevel = sandbox.RiskLevelUnknown
+	t.ForceUpload = true
+	t.Priority = PriorityRecheck
+	t.Retries = 0
+	t.LastError = ""
+	t.Polls = 0
+	t.mx.Unlock()
+	t.resetContext()
+	t.SetChannel(ChPrefilter)
+}
+
+// SetRetry - count one more attempt to repeat stage failed with err
+func (t *Task) SetRetry(err error) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.Retries++
+	t.LastError = err.Error()
+}
+
+// ResetRetries - stage is done successfully, so next failure is counted from
+// scratch. Task that was not retried is left intact, as it can be done already
+func (t *Task) ResetRetries() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	if t.Retries != 0 {
+		t.Retries = 0
+	}
+}
+
+// SetUploaded - task is accepted by sandbox, so its result polling starts from scratch
+func (t *Task) SetUploaded(uploadTime time.Time) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.UploadTime = uploadTime
+	t.Polls = 0
+}
+
+// Postpone - ask launcher to push task to its channel after delay
+// instead of right away
+func (t *Task) Postpone(delay time.Duration) {
+	t.postpone = delay
+}
+
+// TakePostpone - get delay requested by Postpone and clear it
+func (t *Task) TakePostpone() time.Duration {
+	delay := t.postpone
+	t.postpone = 0
+	return delay
+}
+
+// CountPoll - one more check of result
+func (t *Task) CountPoll() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.Polls++
+}
+
 func (t *Task) SetMessage(message string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Message = message
 }
 
 func (t *Task) SetReport(report string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Report = report
 }
 
 func (t *Task) SetInvestigation(investigation string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Investigation = investigation
 }
 
 func (t *Task) Activate() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Active = true
 }
 
 func (t *Task) Deactivate() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
 	t.Active = false
 }
 
+// IsActive - task is busy with dispatcher
+func (t *Task) IsActive() bool {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	return t.Active
+}
+
 /*
 	func (t *Task) SetDigest(MD5, SHA1, SHA256 string) {
 		if MD5 != "" {
@@ -156,13 +303,13 @@ func (t *Task) SaveIfNeeded() error {
 
 const taskFileName = "task.json"
 
+// Save - write task to the store of its list. Tasks of list without
+// store are kept in memory only
 func (t *Task) Save() error {
-	tasksFolder, err := t.Folder()
-	if err != nil {
-		return err
+	if t.store == nil {
+		return nil
 	}
-	taskFilePath := filepath.Join(tasksFolder, taskFileName)
-	return t.SaveToFile(taskFilePath)
+	return t.store.Put(t)
 }
 
 func (t *Task) SaveToFile(filePath string) error {
@@ -220,25 +367,92 @@ func (t *Task) CalculateHash() error {
 	} else {
 		source = strings.NewReader(t.Path)
 	}
+	MD5, SHA1, SHA256, err := hashes(source)
+	if err != nil {
+		return err
+	}
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.SHA256 = SHA256
+	t.SHA1 = SHA1
+	t.MD5 = MD5
+	return nil
+}
+
+// FileHash - SHA256 of file content, the same as CalculateHash gives to
+// task of this file
+func FileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, _, SHA256, err := hashes(f)
+	return SHA256, err
+}
+
+// hashes - MD5, SHA1 and SHA256 of source content as hex strings
+func hashes(source io.Reader) (string, string, string, error) {
 	SHA256 := sha256.New()
 	srcWithSHA256 := io.TeeReader(source, SHA256)
 	SHA1 := sha1.New()
 	srcWithSHA256andSHA1 := io.TeeReader(srcWithSHA256, SHA1)
 	MD5 := md5.New()
 	if _, err := io.Copy(MD5, srcWithSHA256andSHA1); err != nil {
-		return err
+		return "", "", "", err
 	}
-	t.SHA256 = hex.EncodeToString(SHA256.Sum(nil))
-	t.SHA1 = hex.EncodeToString(SHA1.Sum(nil))
-	t.MD5 = hex.EncodeToString(MD5.Sum(nil))
-	return nil
+	return hex.EncodeToString(MD5.Sum(nil)),
+		hex.EncodeToString(SHA1.Sum(nil)),
+		hex.EncodeToString(SHA256.Sum(nil)), nil
 }
 
+// Delete - remove task files along with files uploaded for it through API.
+// Sandbox call made for this task is aborted
 func (t *Task) Delete() error {
+	return t.delete(true)
+}
+
+// delete - remove task. Task folder is kept if removeFolder is false, as
+// it is shared with other task of the same content
+func (t *Task) delete(removeFolder bool) error {
+	t.stop(ErrCancelled)
+	if t.store != nil {
+		if err := t.store.Delete(t.Number); err != nil {
+			return err
+		}
+	}
+	if err := t.removeUploads(); err != nil {
+		return err
+	}
+	if !removeFolder {
+		return nil
+	}
 	folder, err := t.Folder()
 	if err != nil {
 		return err
-
 	}
 	return os.RemoveAll(folder)
 }
+
+// removeUploads - delete upload folders of task path and aliases. Other
+// files are left as is
+func (t *Task) removeUploads() error {
+	if t.Type != FileTask {
+		return nil
+	}
+	uploads, err := globals.UploadsFolder()
+	if err != nil {
+		return err
+	}
+	for _, path := range append([]string{t.Path}, t.Aliases...) {
+		rel, err := filepath.Rel(uploads, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		folder := filepath.Join(uploads, strings.Split(rel, string(filepath.Separator))[0])
+		if err := os.RemoveAll(folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
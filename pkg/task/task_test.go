@@ -48,4 +48,12 @@ func TestHashes(t *testing.T) {
 	if expected != actual {
 		t.Errorf("Expected %s, but got %s", expected, actual)
 	}
+
+	actual, err := FileHash(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != tsk.SHA256 {
+		t.Errorf("Expected %s, but got %s", tsk.SHA256, actual)
+	}
 }
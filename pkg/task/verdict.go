@@ -0,0 +1,91 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+verdict.go
+
+Result of one of several sandboxes task is submitted to
+*/
+package task
+
+import (
+	"time"
+
+	"sandboxer/pkg/sandbox"
+)
+
+type Verdict struct {
+	Sandbox   string
+	SandboxID string
+	RiskLevel sandbox.RiskLevel
+	Message   string
+}
+
+// Final - sandbox is done with this object
+func (v *Verdict) Final() bool {
+	return v.RiskLevel != sandbox.RiskLevelUnknown && v.RiskLevel != sandbox.RiskLevelNotReady
+}
+
+// Conclusive - sandbox has analyzed object
+func (v *Verdict) Conclusive() bool {
+	return v.RiskLevel == sandbox.RiskLevelNoRisk || v.RiskLevel.IsThreat()
+}
+
+// SetFanOut - submit task to all given sandboxes. First one is primary
+func (t *Task) SetFanOut(sandboxes []string) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.Verdicts = nil
+	for _, name := range sandboxes {
+		t.Verdicts = append(t.Verdicts, Verdict{Sandbox: name})
+	}
+	t.Sandbox = sandboxes[0]
+}
+
+// FanOut - task is submitted to several sandboxes
+func (t *Task) FanOut() bool {
+	return len(t.Verdicts) > 0
+}
+
+// ResetVerdicts - forget results of all sandboxes to submit task again
+func (t *Task) ResetVerdicts() {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	for i := range t.Verdicts {
+		t.Verdicts[i] = Verdict{Sandbox: t.Verdicts[i].Sandbox}
+	}
+	if len(t.Verdicts) > 0 {
+		t.Sandbox = t.Verdicts[0].Sandbox
+		t.SandboxID = ""
+	}
+}
+
+// SetVerdict - change verdict of one of sandboxes
+func (t *Task) SetVerdict(i int, verdict Verdict) {
+	t.mx.Lock()
+	defer t.mx.Unlock()
+	t.Verdicts[i] = verdict
+}
+
+// Awaiting - IDs of submissions to sandbox with given name that wait for
+// result, along with upload time. Safe to call while other goroutine
+// processes task
+func (t *Task) Awaiting(sandboxName string) (ids []string, uploadTime time.Time) {
+	t.mx.RLock()
+	defer t.mx.RUnlock()
+	if t.Channel != ChResult {
+		return nil, time.Time{}
+	}
+	if len(t.Verdicts) == 0 {
+		if t.Sandbox == sandboxName && t.SandboxID != "" {
+			ids = append(ids, t.SandboxID)
+		}
+		return ids, t.UploadTime
+	}
+	for _, v := range t.Verdicts {
+		if v.Sandbox == sandboxName && v.SandboxID != "" && !v.Final() {
+			ids = append(ids, v.SandboxID)
+		}
+	}
+	return ids, t.UploadTime
+}
@@ -0,0 +1,502 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+vonesim.go
+
+Emulator of Vision One sandbox API endpoints used by Sandboxer
+*/
+package vonesim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"sandboxer/pkg/sandbox"
+)
+
+const (
+	apiPrefix           = "/v3.0"
+	submitFilePath      = apiPrefix + "/sandbox/files/analyze"
+	submitURLsPath      = apiPrefix + "/sandbox/urls/analyze"
+	listTasksPath       = apiPrefix + "/sandbox/tasks"
+	tasksPath           = listTasksPath + "/"
+	analysisPath        = apiPrefix + "/sandbox/analysisResults/"
+	submissionUsage     = apiPrefix + "/sandbox/submissionUsage"
+	connectivityPath    = apiPrefix + "/healthcheck/connectivity"
+	reportSuffix        = "/report"
+	investigationSuffix = "/investigationPackage"
+	timeFormat          = "2006-01-02T15:04:05Z"
+	maxUploadSize       = 60 << 20
+)
+
+// Error codes of Vision One API
+const (
+	CodeBadRequest          = "BadRequest"
+	CodeInvalidCredentials  = "InvalidCredentials"
+	CodeNotFound            = "NotFound"
+	CodeTooManyRequests     = "TooManyRequests"
+	CodeUnsupported         = "Unsupported"
+	CodeInternalServerError = "InternalServerError"
+)
+
+type Digest struct {
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+}
+
+type submission struct {
+	id        string
+	action    string
+	name      string
+	digest    Digest
+	created   time.Time
+	riskLevel sandbox.RiskLevel
+	threat    string
+	fail      sandbox.MockStage
+}
+
+// Server - Vision One emulator. Verdicts are chosen the same way as by mock sandbox
+type Server struct {
+	token            string
+	analysisTime     time.Duration
+	reserve          int
+	defaultRiskLevel sandbox.RiskLevel
+	rules            []sandbox.MockRule
+	mx               sync.Mutex
+	submissions      map[string]*submission
+	fileCount        int
+	urlCount         int
+	listed           int
+}
+
+func New(token string) *Server {
+	return &Server{
+		token:            token,
+		reserve:          10000,
+		defaultRiskLevel: sandbox.RiskLevelNoRisk,
+		rules: []sandbox.MockRule{
+			{EICAR: true, RiskLevel: sandbox.RiskLevelHigh, Threat: "Eicar_test_file"},
+		},
+		submissions: make(map[string]*submission),
+	}
+}
+
+// SetAnalysisTime - period after submission when task status is "running"
+func (s *Server) SetAnalysisTime(analysisTime time.Duration) *Server {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.analysisTime = analysisTime
+	return s
+}
+
+// SetDailyReserve - number of submissions allowed. When it is exhausted,
+// submissions are rejected with TooManyRequests error
+func (s *Server) SetDailyReserve(reserve int) *Server {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.reserve = reserve
+	return s
+}
+
+// ResetDailyReserve - emulate daily reset of submission counters
+func (s *Server) ResetDailyReserve() {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.fileCount = 0
+	s.urlCount = 0
+}
+
+func (s *Server) SetRules(defaultRiskLevel sandbox.RiskLevel, rules []sandbox.MockRule) *Server {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	s.defaultRiskLevel = defaultRiskLevel
+	s.rules = rules
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		s.Error(w, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid token")
+		return
+	}
+	path := r.URL.Path
+	switch {
+	case path == submitFilePath && r.Method == http.MethodPost:
+		s.SubmitFile(w, r)
+	case path == submitURLsPath && r.Method == http.MethodPost:
+		s.SubmitURLs(w, r)
+	case path == submissionUsage && r.Method == http.MethodGet:
+		s.DailyReserve(w)
+	case path == connectivityPath && r.Method == http.MethodGet:
+		s.JSON(w, http.StatusOK, map[string]string{"status": "available"})
+	case path == listTasksPath && r.Method == http.MethodGet:
+		s.ListSubmissions(w, r)
+	case strings.HasPrefix(path, tasksPath) && r.Method == http.MethodGet:
+		s.SubmissionStatus(w, strings.TrimPrefix(path, tasksPath))
+	case strings.HasPrefix(path, analysisPath) && r.Method == http.MethodGet:
+		id := strings.TrimPrefix(path, analysisPath)
+		switch {
+		case strings.HasSuffix(id, reportSuffix):
+			s.Download(w, strings.TrimSuffix(id, reportSuffix), sandbox.MockStageReport)
+		case strings.HasSuffix(id, investigationSuffix):
+			s.Download(w, strings.TrimSuffix(id, investigationSuffix), sandbox.MockStageInvestigation)
+		default:
+			s.AnalysisResults(w, id)
+		}
+	default:
+		s.Error(w, http.StatusNotFound, CodeNotFound, "Unknown endpoint "+r.Method+" "+path)
+	}
+}
+
+func (s *Server) SubmitFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		s.Error(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+	defer file.Close()
+	hashes, eicar, err := sandbox.Inspect(file)
+	if err != nil {
+		s.Error(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+	digest := Digest{MD5: hashes[0], SHA1: hashes[1], SHA256: hashes[2]}
+	sub, code := s.submit("analyzeFile", header.Filename, hashes, eicar, digest)
+	if code != "" {
+		s.Error(w, http.StatusTooManyRequests, code, "Daily reserve is exhausted")
+		return
+	}
+	s.usageHeaders(w)
+	s.JSON(w, http.StatusAccepted, map[string]any{
+		"id":        sub.id,
+		"digest":    sub.digest,
+		"arguments": "",
+	})
+}
+
+func (s *Server) SubmitURLs(w http.ResponseWriter, r *http.Request) {
+	var request []struct {
+		URL string `json:"url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.Error(w, http.StatusBadRequest, CodeBadRequest, err.Error())
+		return
+	}
+	type body struct {
+		URL    string `json:"url"`
+		ID     string `json:"id,omitempty"`
+		Digest Digest `json:"digest"`
+	}
+	type result struct {
+		Status int  `json:"status"`
+		Body   body `json:"body"`
+	}
+	var response []result
+	for _, u := range request {
+		hashes, _, _ := sandbox.Inspect(strings.NewReader(u.URL))
+		digest := Digest{MD5: hashes[0], SHA1: hashes[1], SHA256: hashes[2]}
+		sub, code := s.submit("analyzeUrl", u.URL, hashes, false, digest)
+		if code != "" {
+			response = append(response, result{Status: http.StatusTooManyRequests, Body: body{URL: u.URL}})
+			continue
+		}
+		response = append(response, result{Status: http.StatusAccepted, Body: body{URL: u.URL, ID: sub.id, Digest: digest}})
+	}
+	s.usageHeaders(w)
+	s.JSON(w, http.StatusMultiStatus, response)
+}
+
+// submit - register new submission. Returns error code if reserve is exhausted
+func (s *Server) submit(action, name string, hashes []string, eicar bool, digest Digest) (*submission, string) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if s.fileCount+s.urlCount >= s.reserve {
+		return nil, CodeTooManyRequests
+	}
+	sub := &submission{
+		id:        uuid.NewString(),
+		action:    action,
+		name:      name,
+		digest:    digest,
+		created:   time.Now().UTC(),
+		riskLevel: s.defaultRiskLevel,
+	}
+	for _, rule := range s.rules {
+		if !rule.Match(name, hashes, eicar) {
+			continue
+		}
+		sub.riskLevel = rule.RiskLevel
+		sub.threat = rule.Threat
+		sub.fail = rule.Fail
+		break
+	}
+	if action == "analyzeUrl" {
+		s.urlCount++
+	} else {
+		s.fileCount++
+	}
+	s.submissions[sub.id] = sub
+	return sub, ""
+}
+
+func (s *Server) getAnalysisTime() time.Duration {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.analysisTime
+}
+
+func (s *Server) get(id string) *submission {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.submissions[id]
+}
+
+func (s *Server) status(sub *submission) string {
+	if time.Since(sub.created) < s.getAnalysisTime() {
+		return "running"
+	}
+	if sub.riskLevel == sandbox.RiskLevelUnsupported || sub.fail == sandbox.MockStageResult {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+func (s *Server) SubmissionStatus(w http.ResponseWriter, id string) {
+	sub := s.get(id)
+	if sub == nil {
+		s.Error(w, http.StatusNotFound, CodeNotFound, "Task "+id+" is not found")
+		return
+	}
+	s.JSON(w, http.StatusOK, s.statusResponse(sub))
+}
+
+func (s *Server) statusResponse(sub *submission) map[string]any {
+	status := s.status(sub)
+	response := map[string]any{
+		"id":                 sub.id,
+		"action":             sub.action,
+		"status":             status,
+		"createdDateTime":    sub.created.Format(timeFormat),
+		"lastActionDateTime": sub.created.Add(s.getAnalysisTime()).Format(timeFormat),
+		"isCached":           false,
+		"digest":             sub.digest,
+		"arguments":          "",
+	}
+	switch {
+	case status == "running":
+		response["lastActionDateTime"] = sub.created.Format(timeFormat)
+	case sub.riskLevel == sandbox.RiskLevelUnsupported:
+		response["error"] = map[string]string{"code": CodeUnsupported, "message": "Unsupported file type"}
+	case sub.fail == sandbox.MockStageResult:
+		response["error"] = map[string]string{"code": CodeInternalServerError, "message": "Simulated error"}
+	default:
+		response["resourceLocation"] = "https://localhost" + analysisPath + sub.id
+	}
+	return response
+}
+
+// ListSubmissions - submissions created after startDateTime (30 days
+// ago by default) and matching filter, newest first. All items are
+// returned in one page
+func (s *Server) ListSubmissions(w http.ResponseWriter, r *http.Request) {
+	start := time.Now().UTC().Add(-30 * 24 * time.Hour)
+	if v := r.URL.Query().Get("startDateTime"); v != "" {
+		t, err := time.Parse(timeFormat, v)
+		if err != nil {
+			s.Error(w, http.StatusBadRequest, CodeBadRequest, "startDateTime: "+err.Error())
+			return
+		}
+		start = t
+	}
+	match, err := parseFilter(r.URL.Query().Get("filter"))
+	if err != nil {
+		s.Error(w, http.StatusBadRequest, CodeBadRequest, "filter: "+err.Error())
+		return
+	}
+	s.mx.Lock()
+	var subs []*submission
+	for _, sub := range s.submissions {
+		if !sub.created.Before(start) && match(sub) {
+			subs = append(subs, sub)
+		}
+	}
+	s.listed += len(subs)
+	s.mx.Unlock()
+	sort.Slice(subs, func(i, j int) bool {
+		return subs[i].created.After(subs[j].created)
+	})
+	items := make([]map[string]any, 0, len(subs))
+	for _, sub := range subs {
+		items = append(items, s.statusResponse(sub))
+	}
+	s.JSON(w, http.StatusOK, map[string]any{"items": items})
+}
+
+// parseFilter - supports single "<digest> eq '<value>'" condition, where
+// digest is md5, sha1 or sha256. Empty filter matches all submissions
+func parseFilter(filter string) (func(*submission) bool, error) {
+	if filter == "" {
+		return func(*submission) bool { return true }, nil
+	}
+	fields := strings.Fields(filter)
+	if len(fields) != 3 || !strings.EqualFold(fields[1], "eq") ||
+		len(fields[2]) < 2 || !strings.HasPrefix(fields[2], "'") || !strings.HasSuffix(fields[2], "'") {
+		return nil, fmt.Errorf("unsupported filter: %s", filter)
+	}
+	value := strings.Trim(fields[2], "'")
+	var digest func(*submission) string
+	switch strings.ToLower(fields[0]) {
+	case "md5":
+		digest = func(sub *submission) string { return sub.digest.MD5 }
+	case "sha1":
+		digest = func(sub *submission) string { return sub.digest.SHA1 }
+	case "sha256":
+		digest = func(sub *submission) string { return sub.digest.SHA256 }
+	default:
+		return nil, fmt.Errorf("unsupported field: %s", fields[0])
+	}
+	return func(sub *submission) bool {
+		return strings.EqualFold(digest(sub), value)
+	}, nil
+}
+
+// Listed - number of items returned by list submissions requests
+func (s *Server) Listed() int {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	return s.listed
+}
+
+// riskLevel - Vision One representation of risk level
+func riskLevel(r sandbox.RiskLevel) string {
+	switch r {
+	case sandbox.RiskLevelLow:
+		return "low"
+	case sandbox.RiskLevelMedium:
+		return "medium"
+	case sandbox.RiskLevelHigh:
+		return "high"
+	}
+	return "noRisk"
+}
+
+// ready - return finished submission or write error response
+func (s *Server) ready(w http.ResponseWriter, id string) *submission {
+	sub := s.get(id)
+	if sub == nil {
+		s.Error(w, http.StatusNotFound, CodeNotFound, "Analysis result "+id+" is not found")
+		return nil
+	}
+	if s.status(sub) != "succeeded" {
+		s.Error(w, http.StatusNotFound, CodeNotFound, "Analysis result "+id+" is not ready")
+		return nil
+	}
+	return sub
+}
+
+func (s *Server) AnalysisResults(w http.ResponseWriter, id string) {
+	sub := s.ready(w, id)
+	if sub == nil {
+		return
+	}
+	response := map[string]any{
+		"id":                         sub.id,
+		"type":                       "file",
+		"digest":                     sub.digest,
+		"arguments":                  "",
+		"analysisCompletionDateTime": sub.created.Add(s.getAnalysisTime()).Format(timeFormat),
+		"riskLevel":                  riskLevel(sub.riskLevel),
+		"detectionNames":             []string{},
+		"threatTypes":                []string{},
+		"trueFileType":               "exe",
+	}
+	if sub.action == "analyzeUrl" {
+		response["type"] = "url"
+		response["trueFileType"] = "url"
+	}
+	if sub.threat != "" {
+		response["detectionNames"] = []string{sub.threat}
+	}
+	s.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) Download(w http.ResponseWriter, id string, stage sandbox.MockStage) {
+	sub := s.ready(w, id)
+	if sub == nil {
+		return
+	}
+	if sub.fail == stage {
+		s.Error(w, http.StatusInternalServerError, CodeInternalServerError, "Simulated error")
+		return
+	}
+	lines := sandbox.ReportLines(sub.name, sub.riskLevel, sub.threat)
+	if stage == sandbox.MockStageReport {
+		w.Header().Set("Content-Type", "application/pdf")
+		_, _ = w.Write(sandbox.PlaceholderPDF(lines...))
+		return
+	}
+	var data bytes.Buffer
+	if err := sandbox.PlaceholderZIP(&data, lines...); err != nil {
+		s.Error(w, http.StatusInternalServerError, CodeInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	_, _ = io.Copy(w, &data)
+}
+
+func (s *Server) DailyReserve(w http.ResponseWriter) {
+	s.mx.Lock()
+	count := s.fileCount + s.urlCount
+	response := map[string]any{
+		"submissionReserveCount":   s.reserve,
+		"submissionRemainingCount": s.reserve - count,
+		"submissionCount":          count,
+		"submissionExemptionCount": 0,
+		"submissionCountDetail": map[string]int{
+			"fileCount":          s.fileCount,
+			"fileExemptionCount": 0,
+			"urlCount":           s.urlCount,
+			"urlExemptionCount":  0,
+		},
+	}
+	s.mx.Unlock()
+	s.JSON(w, http.StatusOK, response)
+}
+
+func (s *Server) usageHeaders(w http.ResponseWriter) {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	count := s.fileCount + s.urlCount
+	w.Header().Set("TMV1-Submission-Reserve-Count", strconv.Itoa(s.reserve))
+	w.Header().Set("TMV1-Submission-Remaining-Count", strconv.Itoa(s.reserve-count))
+	w.Header().Set("TMV1-Submission-Count", strconv.Itoa(count))
+	w.Header().Set("TMV1-Submission-Exemption-Count", "0")
+}
+
+func (s *Server) JSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+func (s *Server) Error(w http.ResponseWriter, status int, code, message string) {
+	s.JSON(w, status, map[string]any{
+		"error": map[string]string{
+			"code":    code,
+			"message": fmt.Sprintf("%s (%d)", message, status),
+		},
+	})
+}
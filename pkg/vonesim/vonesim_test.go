@@ -0,0 +1,253 @@
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+vonesim_test.go
+
+Run Vision One sandbox against emulator
+*/
+package vonesim
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpkondrashin/vone"
+
+	"sandboxer/pkg/sandbox"
+)
+
+const testToken = "token"
+
+func startEmulator(t *testing.T, sim *Server) (string, func(*http.Transport)) {
+	t.Helper()
+	ts := httptest.NewTLSServer(sim)
+	t.Cleanup(ts.Close)
+	tlsConfig := ts.Client().Transport.(*http.Transport).TLSClientConfig
+	modifier := func(transport *http.Transport) {
+		transport.TLSClientConfig = tlsConfig.Clone()
+	}
+	return strings.TrimPrefix(ts.URL, "https://"), modifier
+}
+
+func TestVOneSandbox(t *testing.T) {
+	sim := New(testToken).SetDailyReserve(3)
+	domain, modifier := startEmulator(t, sim)
+	vOne := vone.NewVOne(domain, testToken)
+	vOne.AddTransportModifier(modifier)
+	sb := sandbox.NewVOneSandbox(vOne)
+	folder := t.TempDir()
+	eicarPath := filepath.Join(folder, "eicar.com")
+	if err := os.WriteFile(eicarPath, []byte(sandbox.EICAR), 0644); err != nil {
+		t.Fatal(err)
+	}
+	fileID, err := sb.SubmitFile(context.TODO(), eicarPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urlID, err := sb.SubmitURL(context.TODO(), "http://www.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("result", func(t *testing.T) {
+		riskLevel, threat, err := sb.GetResult(context.TODO(), fileID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != sandbox.RiskLevelHigh || threat != "Eicar_test_file" {
+			t.Errorf("Expected %v, but got %v (%s)", sandbox.RiskLevelHigh, riskLevel, threat)
+		}
+		riskLevel, _, err = sb.GetResult(context.TODO(), urlID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != sandbox.RiskLevelNoRisk {
+			t.Errorf("Expected %v, but got %v", sandbox.RiskLevelNoRisk, riskLevel)
+		}
+	})
+	t.Run("batch result", func(t *testing.T) {
+		unknownID := "00000000-0000-0000-0000-000000000000"
+		results, err := sb.GetResults(context.TODO(), []string{fileID, urlID, unknownID}, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := results[fileID]; r.Err != nil || r.RiskLevel != sandbox.RiskLevelHigh || r.Threat != "Eicar_test_file" {
+			t.Errorf("Expected %v, but got %+v", sandbox.RiskLevelHigh, r)
+		}
+		if r := results[urlID]; r.Err != nil || r.RiskLevel != sandbox.RiskLevelNoRisk {
+			t.Errorf("Expected %v, but got %+v", sandbox.RiskLevelNoRisk, r)
+		}
+		if r, ok := results[unknownID]; !ok || r.Err == nil {
+			t.Errorf("Unknown submission has no error: %+v", r)
+		}
+	})
+	t.Run("lookup", func(t *testing.T) {
+		hashes, _, err := sandbox.Inspect(strings.NewReader(sandbox.EICAR))
+		if err != nil {
+			t.Fatal(err)
+		}
+		since := time.Now().Add(-time.Hour)
+		listed := sim.Listed()
+		id, err := sb.Lookup(context.TODO(), hashes[1], strings.ToUpper(hashes[2]), since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != fileID {
+			t.Errorf("Expected %s, but got %s", fileID, id)
+		}
+		if n := sim.Listed() - listed; n != 1 {
+			t.Errorf("Submissions are not filtered by SHA256: %d listed", n)
+		}
+		id, err = sb.Lookup(context.TODO(), hashes[1], strings.Repeat("0", 64), since)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != "" {
+			t.Errorf("Unknown sample is found: %s", id)
+		}
+	})
+	t.Run("wrong filter", func(t *testing.T) {
+		_, err := vOne.SandboxListSubmissions().Filter("name eq 'eicar.com'").Do(context.TODO())
+		if err == nil {
+			t.Error("Unsupported filter is accepted")
+		}
+	})
+	t.Run("report", func(t *testing.T) {
+		reportPath := filepath.Join(folder, "report.pdf")
+		if err := sb.GetReport(context.TODO(), fileID, reportPath); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(data), "%PDF-") {
+			t.Errorf("Wrong report: %s", data)
+		}
+		investigationPath := filepath.Join(folder, "investigation.zip")
+		if err := sb.GetInvestigation(context.TODO(), fileID, investigationPath); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(investigationPath); err != nil || info.Size() == 0 {
+			t.Errorf("Investigation package is not downloaded: %v", err)
+		}
+	})
+	t.Run("daily reserve", func(t *testing.T) {
+		reserve, err := vOne.SandboxDailyReserve().Do(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reserve.SubmissionCount != 2 || reserve.SubmissionRemainingCount != 1 {
+			t.Errorf("Wrong reserve: %+v", reserve)
+		}
+	})
+	t.Run("reserve exhausted", func(t *testing.T) {
+		if _, err := sb.SubmitFile(context.TODO(), eicarPath); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sb.SubmitFile(context.TODO(), eicarPath); err == nil {
+			t.Error("Submission over reserve is accepted")
+		}
+	})
+	t.Run("quota", func(t *testing.T) {
+		remaining, err := sb.Quota(context.TODO())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if remaining != 0 {
+			t.Errorf("Expected 0, got %d", remaining)
+		}
+		sim.ResetDailyReserve()
+		if remaining, err = sb.Quota(context.TODO()); err != nil || remaining != 3 {
+			t.Errorf("Expected 3 after reset, got %d (%v)", remaining, err)
+		}
+	})
+	t.Run("not found", func(t *testing.T) {
+		if _, _, err := sb.GetResult(context.TODO(), "missing"); err == nil {
+			t.Error("Missing task is found")
+		}
+	})
+}
+
+func TestStatus(t *testing.T) {
+	sim := New(testToken).SetRules(sandbox.RiskLevelLow, []sandbox.MockRule{
+		{Pattern: "*.txt", RiskLevel: sandbox.RiskLevelUnsupported},
+		{Pattern: "*.fail", Fail: sandbox.MockStageResult},
+	})
+	domain, modifier := startEmulator(t, sim)
+	vOne := vone.NewVOne(domain, testToken)
+	vOne.AddTransportModifier(modifier)
+	sb := sandbox.NewVOneSandbox(vOne)
+	folder := t.TempDir()
+	testCases := []struct {
+		name      string
+		riskLevel sandbox.RiskLevel
+		isError   bool
+	}{
+		{"sample.exe", sandbox.RiskLevelLow, false},
+		{"readme.txt", sandbox.RiskLevelUnsupported, true},
+		{"sample.fail", sandbox.RiskLevelError, true},
+	}
+	for _, tCase := range testCases {
+		t.Run(tCase.name, func(t *testing.T) {
+			path := filepath.Join(folder, tCase.name)
+			if err := os.WriteFile(path, []byte("MZ"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			id, err := sb.SubmitFile(context.TODO(), path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			riskLevel, _, err := sb.GetResult(context.TODO(), id)
+			if (err != nil) != tCase.isError {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if riskLevel != tCase.riskLevel {
+				t.Errorf("Expected %v, but got %v", tCase.riskLevel, riskLevel)
+			}
+		})
+	}
+	t.Run("running", func(t *testing.T) {
+		sim.SetAnalysisTime(time.Hour)
+		id, err := sb.SubmitURL(context.TODO(), "http://www.example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		riskLevel, _, err := sb.GetResult(context.TODO(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if riskLevel != sandbox.RiskLevelNotReady {
+			t.Errorf("Expected %v, but got %v", sandbox.RiskLevelNotReady, riskLevel)
+		}
+	})
+}
+
+func TestDetectDomain(t *testing.T) {
+	domain, modifier := startEmulator(t, New(testToken))
+	regionalDomains := vone.RegionalDomains
+	defer func() {
+		vone.RegionalDomains = regionalDomains
+	}()
+	vone.RegionalDomains = []vone.RegionalDomain{{Region: "Emulator", Domain: domain}}
+	result, err := vone.DetectVisionOneDomain(context.TODO(), testToken, modifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != domain {
+		t.Errorf("Expected %s, but got %s", domain, result)
+	}
+	result, err = vone.DetectVisionOneDomain(context.TODO(), "wrong token", modifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != "" {
+		t.Errorf("Domain detected for wrong token: %s", result)
+	}
+}
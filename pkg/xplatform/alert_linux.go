@@ -0,0 +1,29 @@
+//go:build linux
+
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+alert_linux.go
+
+Show alert on Linux
+*/
+
+package xplatform
+
+import (
+	"os/exec"
+)
+
+func Alert(title, subtitle, message, iconPath string) error {
+	notifySend, err := exec.LookPath("notify-send")
+	if err != nil {
+		return err
+	}
+	args := []string{"--app-name", title}
+	if iconPath != "" {
+		args = append(args, "--icon", iconPath)
+	}
+	args = append(args, subtitle, message)
+	return exec.Command(notifySend, args...).Run()
+}
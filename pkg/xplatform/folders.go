@@ -39,6 +39,12 @@ func UserDataFolder(appID string) (string, error) {
 	if runtime.GOOS == "darwin" {
 		return userDataFolder("HOME", "Library/Application Support", appID)
 	}
+	if runtime.GOOS == "linux" {
+		if os.Getenv("XDG_CONFIG_HOME") != "" {
+			return userDataFolder("XDG_CONFIG_HOME", appID, "")
+		}
+		return userDataFolder("HOME", ".config", appID)
+	}
 	return "", fmt.Errorf("%s: %w", runtime.GOOS, ErrUnsupportedOS)
 }
 
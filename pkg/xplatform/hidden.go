@@ -0,0 +1,23 @@
+//go:build !windows
+
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+hidden.go
+
+Check whether file is hidden on Linux and macOS
+*/
+
+package xplatform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsHidden - file name starts with dot
+func IsHidden(path string, _ os.FileInfo) bool {
+	return strings.HasPrefix(filepath.Base(path), ".")
+}
@@ -0,0 +1,31 @@
+//go:build windows
+
+/*
+Sandboxer (c) 2024 by Mikhail Kondrashin (mkondrashin@gmail.com)
+Software is distributed under MIT license as stated in LICENSE file
+
+hidden_windows.go
+
+Check whether file is hidden on Windows
+*/
+
+package xplatform
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// IsHidden - file has hidden attribute or its name starts with dot
+func IsHidden(path string, info os.FileInfo) bool {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return true
+	}
+	if info == nil {
+		return false
+	}
+	data, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	return ok && data.FileAttributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0
+}